@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/prashant1k99/compactor/compactor"
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// archiveMember is a file or directory found while walking the input tree.
+type archiveMember struct {
+	path string
+	// name is the slash separated path relative to the archived directory.
+	name string
+	info fs.FileInfo
+}
+
+// CompressDir archives the directory tree at dirPath into outputPath, each
+// file compressed with opts. Files other than regular files and directories,
+// such as symlinks and sockets, are skipped. An outputPath of "-" writes to
+// stdout. With keys the whole archive is encrypted and signed.
+func CompressDir(dirPath string, outputPath string, opts compactor.Options, keys *outputKeys) (err error) {
+	status := statusOutput(outputPath)
+	bar := newProgressBar(status)
+
+	// Open a output file for streaming
+	outputFile, removeOutput, err := createOutput(outputPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if outputFile != os.Stdout {
+			outputFile.Close()
+		}
+		if err != nil {
+			removeOutput()
+		}
+	}()
+	outputInfo, err := outputFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	bar.Describe("Scanning Directory")
+	members, totalSize, err := walkArchiveMembers(dirPath, outputInfo, status)
+	if err != nil {
+		return err
+	}
+
+	if keys != nil && len(keys.recipients) > 0 {
+		bar.Describe("Deriving Key")
+	}
+	output, finishOutput, err := keys.protect(outputFile)
+	if err != nil {
+		return err
+	}
+
+	bar.Describe("Compressing Files")
+	aw := compactor.NewArchiveWriter(output, opts)
+	// Only the default mode needs the frequency up front, the others read
+	// every file once anyway.
+	countFrequency := opts.BlockSize == 0 && !opts.Adaptive && !opts.LZ && !opts.Seekable
+	reader := &progressReader{bar: bar, totalSize: totalSize, span: 98}
+	for _, m := range members {
+		if m.info.IsDir() {
+			if err = aw.AddDir(m.name, m.info.Mode(), m.info.ModTime()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = addArchiveFile(aw, m, reader, countFrequency); err != nil {
+			return err
+		}
+	}
+	if err = aw.Close(); err != nil {
+		return err
+	}
+	if err = finishOutput(); err != nil {
+		return err
+	}
+	bar.Set(100)
+
+	if outputPath != stdioPath {
+		fmt.Fprintf(status, "\nDirectory archived successfully: %s (%d files and directories)\n", outputPath, len(members))
+	} else {
+		fmt.Fprintln(status)
+	}
+	return nil
+}
+
+// walkArchiveMembers lists the tree below dirPath in lexical order, skipping
+// the file described by output so an archive written inside the tree does not
+// include itself.
+func walkArchiveMembers(dirPath string, output fs.FileInfo, status io.Writer) ([]archiveMember, int64, error) {
+	var members []archiveMember
+	var totalSize int64
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dirPath {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			fmt.Fprintf(status, "Skipping %s: not a regular file\n", path)
+			return nil
+		}
+		if os.SameFile(info, output) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return err
+		}
+		members = append(members, archiveMember{path: path, name: filepath.ToSlash(rel), info: info})
+		if info.Mode().IsRegular() {
+			totalSize += info.Size()
+		}
+		return nil
+	})
+	return members, totalSize, err
+}
+
+// addArchiveFile adds the file of m to aw. With countFrequency the bytes of
+// the file are counted first, like CompressFile does, so the file is
+// streamed into the archive instead of being held in memory.
+func addArchiveFile(aw *compactor.ArchiveWriter, m archiveMember, reader *progressReader, countFrequency bool) error {
+	file, err := os.Open(m.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var frequency compressutils.Frequency
+	if countFrequency {
+		fileFrequency, err := compressutils.GetFrequencyForReader(file)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		frequency = *fileFrequency
+	}
+
+	reader.reader = file
+	if err := aw.AddFileWithFrequency(m.name, m.info.Mode(), m.info.ModTime(), reader, frequency); err != nil {
+		return fmt.Errorf("%s: %w", m.path, err)
+	}
+	return nil
+}
+
+// isArchiveInput reports whether the input behind br is an archive.
+func isArchiveInput(br *bufio.Reader) bool {
+	magic, _ := br.Peek(len(compactor.ArchiveMagic))
+	return compactor.IsArchive(magic)
+}
+
+// openArchive reads the index of the size bytes of the archive in file. br
+// reads the same bytes and may already have consumed part of them. When file
+// is nil, as for a pipe or a decrypted archive, br is spooled into a
+// temporary file, which the returned cleanup function removes.
+func openArchive(file io.ReaderAt, size int64, br *bufio.Reader) (*compactor.ArchiveReader, func(), error) {
+	cleanup := func() {}
+	if file == nil {
+		spooled, remove, err := spoolToTempFile(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		info, err := spooled.Stat()
+		if err != nil {
+			remove()
+			return nil, nil, err
+		}
+		file, size, cleanup = spooled, info.Size(), remove
+	}
+
+	ar, err := compactor.OpenArchive(file, size)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return ar, cleanup, nil
+}
+
+// extractArchive restores the archive ar into the directory outputDir.
+// Directories get their mode and modification time once all files are
+// written, so read-only directories can still be filled.
+func extractArchive(ar *compactor.ArchiveReader, outputDir string, bar *progressReader) error {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	var dirs []*compactor.ArchiveEntry
+	for i := range ar.Entries {
+		entry := &ar.Entries[i]
+		name := filepath.FromSlash(entry.Path)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("%w: invalid path %q", compactor.ErrCorruptArchive, entry.Path)
+		}
+		target := filepath.Join(outputDir, name)
+
+		if entry.IsDir() {
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, entry)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := extractArchiveFile(ar, entry, target, bar); err != nil {
+			return err
+		}
+	}
+
+	// Children come after their parent, so going backwards sets the time of
+	// a directory after everything in it is done.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		target := filepath.Join(outputDir, filepath.FromSlash(dirs[i].Path))
+		if err := os.Chmod(target, dirs[i].Mode.Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(target, dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractArchiveFile(ar *compactor.ArchiveReader, entry *compactor.ArchiveEntry, target string, bar *progressReader) (err error) {
+	reader, err := ar.Open(entry)
+	if err != nil {
+		return fmt.Errorf("%s: %w", entry.Path, err)
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, entry.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		file.Close()
+		// Don't leave a half written or corrupt file behind.
+		if err != nil {
+			os.Remove(target)
+		}
+	}()
+
+	bar.reader = reader
+	if _, err = io.Copy(file, bar); err != nil {
+		return fmt.Errorf("%s: %w", entry.Path, err)
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	// The mode given to OpenFile is reduced by the umask and ignored for a
+	// file that already exists.
+	if err = os.Chmod(target, entry.Mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(target, entry.ModTime, entry.ModTime)
+}
+
+// archiveSize returns the total original size of the files in ar.
+func archiveSize(ar *compactor.ArchiveReader) int64 {
+	var size int64
+	for _, entry := range ar.Entries {
+		size += entry.Size
+	}
+	return size
+}
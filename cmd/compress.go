@@ -4,112 +4,58 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strconv"
+	"path/filepath"
 
+	"github.com/prashant1k99/compactor/compactor"
 	compressutils "github.com/prashant1k99/compactor/compress-utils"
-
-	"github.com/schollz/progressbar/v3"
-)
-
-const batchSize = 1024
-
-var (
-	huffmanCodes = make(compressutils.HuffmanCodeTable)
-	paddingBits  = 0
+	"github.com/prashant1k99/compactor/deflate"
 )
 
-func writeCompressedFileMetadata(file *os.File) {
-	fmt.Fprintf(file, "PaddingBits:%d\n", paddingBits)
-	for key, val := range huffmanCodes {
-		fmt.Fprintf(file, "%c:%s\n", key, val)
+// CompressFile compresses filePath into outputPath. A filePath of "-" reads
+// stdin and an outputPath of "-" writes to stdout. With opts.BlockSize,
+// opts.Adaptive, opts.LZ or opts.Seekable set the input is compressed in a
+// single pass, otherwise the frequency of the whole input is counted first
+// and opts.Frequency is filled in from it. With keys the compressed data is
+// encrypted and signed.
+func CompressFile(filePath string, outputPath string, opts compactor.Options, keys *outputKeys) error {
+	var frequency *compressutils.Frequency
+	if opts.BlockSize == 0 && !opts.Adaptive && !opts.LZ && !opts.Seekable {
+		frequency = &opts.Frequency
 	}
-	fmt.Fprintf(file, "DATA_STARTS:\n")
+	return compress(filePath, outputPath, frequency, keys, func(w io.Writer, input os.FileInfo) io.WriteCloser {
+		return compactor.NewWriter(w, opts)
+	})
 }
 
-func updatePaddingBitsInMetadata(file *os.File) error {
-	// Calculate the position to write
-	// Assuming "PaddingBits:" is at the start of the file and we're updating the first digit after it
-	position := int64(len("PaddingBits:"))
-
-	// Seek to the position where we want to write
-	_, err := file.Seek(position, 0)
-	if err != nil {
-		return fmt.Errorf("error seeking to position %d: %w", position, err)
-	}
-
-	// Convert PaddingBits to a string and get the first character
-	paddingBitsStr := strconv.Itoa(paddingBits)
-	if len(paddingBitsStr) == 0 {
-		return fmt.Errorf("PaddingBits value is invalid")
-	}
-	byteToWrite := paddingBitsStr[0]
-
-	// Write the single byte
-	_, err = file.Write([]byte{byteToWrite})
-	if err != nil {
-		return fmt.Errorf("error writing byte: %w", err)
-	}
-
-	return nil
+// CompressGzipFile compresses filePath into a gzip file at outputPath, which
+// any gzip tool can decompress. The file name and modification time of the
+// input are stored in the gzip header.
+func CompressGzipFile(filePath string, outputPath string) error {
+	return compress(filePath, outputPath, nil, nil, func(w io.Writer, input os.FileInfo) io.WriteCloser {
+		return newGzipWriter(w, filePath, input)
+	})
 }
 
-func convertBytesToBinary(data []byte) string {
-	var binaryString string
-	for _, bar := range data {
-		binaryString += huffmanCodes[rune(bar)]
+// newGzipWriter returns a gzip writer that records the name and modification
+// time of the regular file filePath.
+func newGzipWriter(w io.Writer, filePath string, input os.FileInfo) *deflate.GzipWriter {
+	zw := deflate.NewGzipWriter(w)
+	if input.Mode().IsRegular() {
+		zw.Name = filepath.Base(filePath)
+		zw.ModTime = input.ModTime()
 	}
-	return binaryString
+	return zw
 }
 
-func convertBinaryToBytes(binaryString string, isLastBatch bool) ([]byte, string) {
-	// Convert all the things to bytes if something is not wrapping it up, return it so that next batch can pick it up
-	var handledBytes []byte
-
-	// step 1 find the extra bits from binaryString and remove them from here and set them as return param
-	binaryStringLen := len(binaryString)
-	unprocessableBitsCount := binaryStringLen % 8
-	unprocessableBits := binaryString[binaryStringLen-unprocessableBitsCount:]
-	binaryString = binaryString[:binaryStringLen-unprocessableBitsCount]
-	binaryStringLen = len(binaryString)
-
-	// Step 2 Run in loop until the loop ends and make the batch of 8bits from binaryString
-	for i := 0; i < binaryStringLen; i += 8 {
-		chunkEndIdx := i + 8
-		byteChunk := binaryString[i:chunkEndIdx]
-		// Step 3: Convert string bits to processable bits
-		byteVal, _ := strconv.ParseUint(byteChunk, 2, 8)
-		handledBytes = append(handledBytes, byte(byteVal))
-	}
+// compress copies filePath through the writer from newWriter into
+// outputPath, encrypted and signed with keys if they are not nil. When
+// frequency is not nil the byte frequency of the input is counted into it
+// before newWriter is called.
+func compress(filePath, outputPath string, frequency *compressutils.Frequency, keys *outputKeys, newWriter func(w io.Writer, input os.FileInfo) io.WriteCloser) (err error) {
+	status := statusOutput(outputPath)
+	bar := newProgressBar(status)
 
-	// Step4: it it's last batch, then pad the unprocessableBits to process them with additional bits and save them
-	if isLastBatch && unprocessableBitsCount > 0 {
-		for unprocessableBitsCount < 8 {
-			paddingBits++
-			unprocessableBits += "0"
-			unprocessableBitsCount++
-		}
-		byteVal, _ := strconv.ParseUint(unprocessableBits, 2, 8)
-		handledBytes = append(handledBytes, byte(byteVal))
-	}
-
-	return handledBytes, unprocessableBits
-}
-
-func CompressFile(filePath string, outputPath string) error {
-	bar := progressbar.NewOptions(100,
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionSetWidth(50),
-		progressbar.OptionSetDescription("Initializing..."),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]█[reset]",
-			SaucerHead:    "[green]█[reset]",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}),
-	)
-
-	file, err := os.Open(filePath)
+	file, err := openInput(filePath)
 	if err != nil {
 		return err
 	}
@@ -117,84 +63,97 @@ func CompressFile(filePath string, outputPath string) error {
 
 	readFileStat, err := file.Stat()
 	if err != nil {
-		fmt.Println("Error while reading input file stats:")
-		return err
-	}
-	readFileSize := readFileStat.Size()
-
-	bar.Describe("Generating Frequency Map")
-	// First get frequency of the CompressFile
-	frequncyForFile, err := compressutils.GetFrequencyForFile(filePath)
-	if err != nil {
+		fmt.Fprintln(status, "Error while reading input file stats:")
 		return err
 	}
-	bar.Add(5)
-
-	bar.Describe("Creating B-Tree")
 
-	// Generate b tree and then geenrate huffman code HuffmanCodeTable
-	rootNode := compressutils.CreateBTreeFromFrequency(*frequncyForFile)
-	bar.Add(5)
+	// The frequency pass needs to read the input twice, so anything that
+	// cannot seek back, like a pipe, is spooled into a temporary file first.
+	// Block and LZ mode build a table per block and adaptive mode needs none,
+	// they read the input only once.
+	frequencyPass := frequency != nil
+	if frequencyPass && !readFileStat.Mode().IsRegular() {
+		bar.Describe("Spooling Input")
+		spooled, cleanup, err := spoolToTempFile(file)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
 
-	bar.Describe("Extracting Huffman Codes")
+		file = spooled
+		if readFileStat, err = file.Stat(); err != nil {
+			return err
+		}
+	}
+	readFileSize := readFileStat.Size()
 
-	totalCodeCount := len((*frequncyForFile))
-	huffmanCodes, err = compressutils.TraverseBTreeToGenerateHuffmanCodes(rootNode, totalCodeCount)
-	if err != nil {
-		return err
+	progressStart := 0
+	if frequencyPass {
+		bar.Describe("Generating Frequency Map")
+		// First get frequency of the CompressFile
+		frequncyForFile, err := compressutils.GetFrequencyForReader(file)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		*frequency = *frequncyForFile
+		progressStart = 15
+		bar.Add(15)
 	}
-	bar.Add(5)
 
 	// Open a output file for streaming
-	outputFile, err := os.OpenFile(outputPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	outputFile, removeOutput, err := createOutput(outputPath)
 	if err != nil {
 		return err
 	}
-	defer outputFile.Close()
-
-	bar.Describe("Writing File Metadata")
-
-	writeCompressedFileMetadata(outputFile)
-	bar.Add(3)
-
-	totalBytesRead := 0
-	remainingBytes := ""
-
-	bar.Describe("Compressing File")
-
-	for {
-		buffer := make([]byte, batchSize)
-		byteRead, err := file.Read(buffer)
+	defer func() {
+		if outputFile != os.Stdout {
+			outputFile.Close()
+		}
 		if err != nil {
-			if err != io.EOF {
-				return err
-			} else {
-				break
-			}
+			removeOutput()
 		}
+	}()
 
-		if byteRead > 0 {
-			// Process the batch
-			totalBytesRead += byteRead
-			isLastBatch := totalBytesRead == int(readFileSize)
-			binaryString := convertBytesToBinary(buffer[:byteRead])
-			binaryString += remainingBytes
-			compressedData, remaining := convertBinaryToBytes(binaryString, isLastBatch)
-			remainingBytes = remaining
+	if keys != nil && len(keys.recipients) > 0 {
+		bar.Describe("Deriving Key")
+	}
+	output, finishOutput, err := keys.protect(outputFile)
+	if err != nil {
+		return err
+	}
 
-			outputFile.Write(compressedData)
-			// Update the CompressedPercentage variable so it can be used to show status in CLI
+	bar.Describe("Compressing File")
 
-			progress := int(float64(totalBytesRead) / float64(readFileSize) * 82)
-			bar.Set(18 + progress)
-		}
+	// With the frequency known up front, or in block, adaptive or gzip mode,
+	// the writer emits the header and streams the encoded data without
+	// buffering the whole file.
+	writer := newWriter(output, readFileStat)
+	reader := &progressReader{
+		reader:    file,
+		bar:       bar,
+		totalSize: readFileSize,
+		start:     progressStart,
+		span:      98 - progressStart,
 	}
+	if _, err = io.Copy(writer, reader); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	if err = finishOutput(); err != nil {
+		return err
+	}
+	bar.Set(100)
 
-	// Once the padding bits is updated as per the code requirement update the metadata
-	err = updatePaddingBitsInMetadata(outputFile)
-	bar.Add(2)
-
-	fmt.Printf("\nFile Compressed successfully: %s\n", outputPath)
+	if outputPath != stdioPath {
+		fmt.Fprintf(status, "\nFile Compressed successfully: %s\n", outputPath)
+	} else {
+		fmt.Fprintln(status)
+	}
 
-	return err
+	return nil
 }
@@ -2,174 +2,243 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/prashant1k99/compactor/compactor"
 	"github.com/schollz/progressbar/v3"
 )
 
-// Step 1: Extract metadata from the inputFile
-// Step 2: Generate ReverseHuffmanCode using metadata
-// Step 3: Convert the bytes from the file to binaryString
-// Step 4: Using the ReverseHuffmanCode, convert the binaryString to correct bytes
-// Step 5: For last batch of the decoded, subtract the Padding Bits
-
-type ReverseHuffmanCode map[string]rune
-
-var reverseHuffmanCode = make(ReverseHuffmanCode)
+// DecompressFile decompresses inputFile into outputFilePath. An inputFile of
+// "-" reads stdin and an outputFilePath of "-" writes to stdout. An archive is
+// restored into the directory outputFilePath instead, which cannot be stdout.
+// Encrypted input is decrypted with keys first, and when keys trusts any
+// signing keys the input is refused unless it is signed by one of them.
+// tables are the trained tables the input may have been compressed with.
+func DecompressFile(inputFile, outputFilePath string, keys *keySource, tables []*compactor.Table) (err error) {
+	status := statusOutput(outputFilePath)
+	bar := newProgressBar(status)
+
+	file, err := openInput(inputFile)
+	if err != nil {
+		fmt.Fprintln(status, "Error while opening compressed file:")
+		return err
+	}
+	defer file.Close()
 
-func ExtractMetadataFromFile(file *os.File) int {
-	scanner := bufio.NewScanner(file)
-	dataOffsetInt := 0
+	if len(keys.trustedKeys) > 0 {
+		bar.Describe("Checking Signature")
+	}
+	signed, err := openVerifiedInput(file, keys)
+	if err != nil {
+		fmt.Fprintln(status, "Error while checking signature:")
+		return err
+	}
+	defer signed.cleanup()
+
+	// The progress follows the compressed, or encrypted, bytes read.
+	input := bufio.NewReader(&progressReader{
+		reader:    signed,
+		bar:       bar,
+		totalSize: signed.size,
+		start:     10,
+		span:      90,
+	})
+	input, decrypted, err := openDecrypted(input, keys)
+	if err != nil {
+		fmt.Fprintln(status, "Error while decrypting file:")
+		return err
+	}
+	if isArchiveInput(input) {
+		if decrypted {
+			// The archive is only in the decrypted stream, not in file.
+			signed.file = nil
+		}
+		return decompressArchive(signed.file, signed.size, input, tables, outputFilePath, status, bar)
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		line = strings.TrimRight(line, "\r\n")
-		dataOffsetInt += len(line) + 1
+	bar.Describe("Extracting Metadata")
+	reader, err := openDecompressor(input, tables)
+	if err != nil {
+		fmt.Fprintln(status, "Error while reading metadata:")
+		return err
+	}
 
-		if line == "DATA_STARTS:" {
-			break
+	// Create Output File
+	outputFile, removeOutput, err := createOutput(outputFilePath)
+	if err != nil {
+		fmt.Fprintln(status, "Error while creating decompressedFile:")
+		return err
+	}
+	defer func() {
+		if outputFile != os.Stdout {
+			outputFile.Close()
 		}
-		if strings.HasPrefix(line, "PaddingBits:") {
-			paddingBits, _ = strconv.Atoi(strings.TrimPrefix(line, "PaddingBits:"))
-		} else {
-			parts := strings.SplitN(line, ":", 2)
-			if strings.Count(line, ":") == 2 {
-				parts = strings.SplitN(parts[1], ":", 2)
-				reverseHuffmanCode[parts[1]] = ':'
-				continue
-			}
-			if len(parts) == 2 {
-				var key rune
-				switch parts[0] {
-				case "":
-					key = '\n'
-				case "SPACE":
-					key = ' '
-				default:
-					key = []rune(parts[0])[0]
-				}
-				reverseHuffmanCode[parts[1]] = key
-			}
+		// Don't leave a half written or corrupt file behind.
+		if err != nil {
+			removeOutput()
 		}
-	}
+	}()
 
-	return dataOffsetInt
-}
+	bar.Describe("Decompressing File")
 
-func convertBytesToBinaryString(compressedData []byte, paddingBits int) string {
-	var binaryString string
-	for _, b := range compressedData {
-		binaryString += fmt.Sprintf("%08b", b)
+	if _, err = io.Copy(outputFile, reader); err != nil {
+		fmt.Fprintln(status, "\nError while decompressing file:")
+		return err
 	}
-	if paddingBits > 0 {
-		binaryString = binaryString[:len(binaryString)-paddingBits]
+
+	if outputFilePath != stdioPath {
+		fmt.Fprintf(status, "\nDecompressed File Successfully: %s\n", outputFilePath)
+	} else {
+		fmt.Fprintln(status)
 	}
-	return binaryString
+
+	return nil
 }
 
-func decompressContentInBatch(batch []byte, remainingBits string, isLastBatch bool) ([]byte, string) {
-	binaryString := remainingBits + convertBytesToBinaryString(batch, 0)
-	var decodedData []byte
-	var currentCode string
+func decompressArchive(file io.ReaderAt, size int64, input *bufio.Reader, tables []*compactor.Table, outputDir string, status io.Writer, bar *progressbar.ProgressBar) error {
+	if outputDir == stdioPath {
+		return errors.New("an archive holds a directory tree and cannot be written to stdout, pass -o with a directory")
+	}
 
-	if isLastBatch {
-		binaryString = binaryString[:len(binaryString)-(paddingBits)]
+	bar.Describe("Reading Archive Index")
+	ar, cleanup, err := openArchive(file, size, input)
+	if err != nil {
+		fmt.Fprintln(status, "Error while reading archive index:")
+		return err
 	}
-	for _, bit := range binaryString {
-		currentCode += string(bit)
-		if char, exists := reverseHuffmanCode[currentCode]; exists {
-			decodedData = append(decodedData, byte(char))
-			currentCode = ""
-		}
+	defer cleanup()
+	ar.Tables = tables
+
+	bar.Describe("Extracting Files")
+	reader := &progressReader{bar: bar, totalSize: archiveSize(ar), span: 100}
+	if err := extractArchive(ar, outputDir, reader); err != nil {
+		fmt.Fprintln(status, "\nError while extracting archive:")
+		return err
 	}
+	bar.Set(100)
 
-	return decodedData, currentCode
+	fmt.Fprintf(status, "\nExtracted %d files and directories into %s\n", len(ar.Entries), outputDir)
+	return nil
 }
 
-func DecompressFile(inputFile, outputFilePath string) error {
-	bar := progressbar.NewOptions(100,
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionSetWidth(50),
-		progressbar.OptionSetDescription("Initializing..."),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]█[reset]",
-			SaucerHead:    "[green]█[reset]",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}),
-	)
+// DecompressRange writes length bytes of the decompressed data of inputFile,
+// starting at start, to outputFilePath. A negative length reads to the end.
+// Only the blocks holding the range are decoded, which needs a file written
+// with --seekable that can be read at random, so not stdin.
+func DecompressRange(inputFile, outputFilePath string, start, length int64, keys *keySource) (err error) {
+	status := statusOutput(outputFilePath)
 
+	if isStdio(inputFile) {
+		return errors.New("--range needs a compressed file to seek in, it cannot read stdin")
+	}
 	file, err := os.Open(inputFile)
 	if err != nil {
-		fmt.Println("Error while opening compressed file:")
 		return err
 	}
 	defer file.Close()
 
-	bar.Describe("Extracting Metadata")
-	offsetBits := ExtractMetadataFromFile(file)
-	bar.Add(10)
-
-	compressedFileStats, err := file.Stat()
+	signed, err := openVerifiedInput(file, keys)
 	if err != nil {
-		fmt.Println("Error while reading file stats")
 		return err
 	}
-	compressedFileSize := compressedFileStats.Size() - int64(offsetBits)
-
-	// Create Output File
-	outputFile, err := os.Create(outputFilePath)
+	defer signed.cleanup()
+	reader, err := compactor.NewSeekableReader(signed.file, signed.size)
+	if errors.Is(err, compactor.ErrNotCompactor) && isEncryptedFile(file) {
+		return fmt.Errorf("%s is encrypted, ranges can only be read from unencrypted files", inputFile)
+	}
+	if errors.Is(err, compactor.ErrNotSeekable) {
+		return fmt.Errorf("%s has no block index, compress it with --seekable to read ranges", inputFile)
+	}
 	if err != nil {
-		fmt.Println("Error while creating decompressedFile:")
 		return err
 	}
-	defer outputFile.Close()
 
-	_, err = file.Seek(int64(offsetBits), 0) // Seek to byte 100 from the beginning of the file (whence = 0)
+	if start > reader.Size() {
+		return fmt.Errorf("range starts at byte %d, after the end of the %d decompressed bytes", start, reader.Size())
+	}
+	if length < 0 || length > reader.Size()-start {
+		length = reader.Size() - start
+	}
+
+	outputFile, removeOutput, err := createOutput(outputFilePath)
 	if err != nil {
-		fmt.Println("Error seeking in file:")
 		return err
 	}
-
-	bar.Describe("Decompressing File")
-
-	buffer := make([]byte, 1024)
-	remainingBits := ""
-	totalBytesRead := 0
-	for {
-		n, err := file.Read(buffer)
-		if err != nil && err != io.EOF {
-			return err
+	defer func() {
+		if outputFile != os.Stdout {
+			outputFile.Close()
 		}
-
-		if n == 0 {
-			break
+		// Don't leave a half written or corrupt file behind.
+		if err != nil {
+			removeOutput()
 		}
+	}()
 
-		totalBytesRead += n
-		isLastBatch := totalBytesRead == int(compressedFileSize)
-		var decodedData []byte
+	if _, err = io.Copy(outputFile, io.NewSectionReader(reader, start, length)); err != nil {
+		return err
+	}
 
-		decodedData, remainingBits = decompressContentInBatch(buffer[:n], remainingBits, isLastBatch)
+	if outputFilePath != stdioPath {
+		fmt.Fprintf(status, "Decompressed bytes %d to %d into %s\n", start, start+length, outputFilePath)
+	}
+	return nil
+}
 
-		_, err = outputFile.Write(decodedData)
-		if err != nil {
-			return err
+// parseByteRange parses START:END or START:+LENGTH, where either size can
+// carry a K, M or G suffix. An empty START is 0 and an empty END reads to
+// the end, which parseByteRange returns as a negative length.
+func parseByteRange(s string) (start, length int64, err error) {
+	from, to, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid range %q, use START:END or START:+LENGTH", s)
+	}
+	if from != "" {
+		if start, err = parseSize(from); err != nil {
+			return 0, 0, err
 		}
+	}
 
-		if err == io.EOF {
-			break
-		}
-		progress := int(float64(totalBytesRead) / float64(compressedFileSize) * 90)
-		bar.Set(10 + progress)
+	switch {
+	case to == "":
+		return start, -1, nil
+	case strings.HasPrefix(to, "+"):
+		length, err = parseSize(to[1:])
+		return start, length, err
 	}
+	end, err := parseSize(to)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("invalid range %q, the end is before the start", s)
+	}
+	return start, end - start, nil
+}
 
-	fmt.Printf("\nDecompressed File Successfully: %s\n", outputFilePath)
+// parseSize parses a byte count with an optional binary K, M or G suffix.
+func parseSize(s string) (int64, error) {
+	digits, shift := s, 0
+	switch strings.ToUpper(s[len(s)-min(len(s), 1):]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	}
+	if shift > 0 {
+		digits = s[:len(s)-1]
+	}
 
-	return nil
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("invalid size %q, use a byte count with an optional K, M or G suffix", s)
+	}
+	return n << shift, nil
 }
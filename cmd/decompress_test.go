@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		input         string
+		start, length int64
+		expectedErr   bool
+	}{
+		{input: "100:200", start: 100, length: 100},
+		{input: "100:+50", start: 100, length: 50},
+		{input: "100:", start: 100, length: -1},
+		{input: ":", start: 0, length: -1},
+		{input: ":1K", start: 0, length: 1024},
+		{input: "1k:+2m", start: 1024, length: 2 << 20},
+		{input: "1G:2G", start: 1 << 30, length: 1 << 30},
+		{input: "7:7", start: 7, length: 0},
+		{input: "", expectedErr: true},
+		{input: "100", expectedErr: true},
+		{input: "200:100", expectedErr: true},
+		{input: "100:+", expectedErr: true},
+		{input: "100:+-5", expectedErr: true},
+		{input: "-1:10", expectedErr: true},
+		{input: "x:10", expectedErr: true},
+		{input: "0:9999999999999G", expectedErr: true},
+		{input: "0:9223372036854775808", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			start, length, err := parseByteRange(tt.input)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("parseByteRange(%q) error = %v, expectedErr %v", tt.input, err, tt.expectedErr)
+			}
+			if err == nil && (start != tt.start || length != tt.length) {
+				t.Errorf("parseByteRange(%q) = %d, %d, want %d, %d", tt.input, start, length, tt.start, tt.length)
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    int64
+		expectedErr bool
+	}{
+		{input: "0", expected: 0},
+		{input: "512", expected: 512},
+		{input: "4K", expected: 4 << 10},
+		{input: "4k", expected: 4 << 10},
+		{input: "3M", expected: 3 << 20},
+		{input: "2g", expected: 2 << 30},
+		{input: "9223372036854775807", expected: math.MaxInt64},
+		{input: "8589934591G", expected: 8589934591 << 30},
+		{input: "", expectedErr: true},
+		{input: "K", expectedErr: true},
+		{input: "-1", expectedErr: true},
+		{input: "1.5M", expectedErr: true},
+		{input: "10T", expectedErr: true},
+		{input: "9223372036854775808", expectedErr: true},
+		{input: "8589934592G", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSize(tt.input)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("parseSize(%q) error = %v, expectedErr %v", tt.input, err, tt.expectedErr)
+			}
+			if err == nil && got != tt.expected {
+				t.Errorf("parseSize(%q) = %d, want %d", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/prashant1k99/compactor/compactor"
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// dryRunResult is what --dry-run reports.
+type dryRunResult struct {
+	OriginalSize   int64
+	CompressedSize int64
+	// Stats is set for the default mode, whose size follows from the
+	// frequency table alone.
+	Stats    *compactor.Stats
+	Duration time.Duration
+}
+
+// countingWriter discards what is written to it and counts the bytes.
+type countingWriter struct {
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
+
+// DryRunFile works out the exact size filePath compresses to without opening
+// any output. The default mode only counts the bytes and builds the code,
+// the size of every other mode, and with a trained or preset table, depends
+// on the encoded data, so the input is compressed into a writer that
+// discards it. With a table the bytes are still counted first, like
+// CompressFile does, so the input is streamed instead of held in memory.
+func DryRunFile(filePath string, opts compactor.Options, format string) (*dryRunResult, error) {
+	start := time.Now()
+
+	file, err := openInput(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	input, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dryRunResult{}
+	if format == formatCrypt && opts.BlockSize == 0 && !opts.Adaptive && !opts.LZ && !opts.Seekable {
+		// The input is encoded after counting, which needs it spooled if
+		// it cannot seek back.
+		encoded := opts.Table != nil || opts.AutoPreset
+		if encoded && !input.Mode().IsRegular() {
+			spooled, cleanup, err := spoolToTempFile(file)
+			if err != nil {
+				return nil, err
+			}
+			defer cleanup()
+			file = spooled
+		}
+
+		frequency, err := compressutils.GetFrequencyForReader(file)
+		if err != nil {
+			return nil, err
+		}
+		if !encoded {
+			if result.Stats, err = compactor.NewStats(*frequency, opts.MaxCodeLength); err != nil {
+				return nil, err
+			}
+			result.OriginalSize = result.Stats.OriginalSize
+			result.CompressedSize = result.Stats.CompressedSize
+			result.Duration = time.Since(start)
+			return result, nil
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		opts.Frequency = *frequency
+	}
+
+	counter := &countingWriter{}
+	var writer io.WriteCloser = compactor.NewWriter(counter, opts)
+	if format == formatGzip {
+		writer = newGzipWriter(counter, filePath, input)
+	}
+	if result.OriginalSize, err = io.Copy(writer, file); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	result.CompressedSize = counter.n
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+func printDryRun(w io.Writer, name string, result *dryRunResult) {
+	fmt.Fprintf(w, "Dry run, nothing was written: %s\n", name)
+	fmt.Fprintf(w, "Original size:   %d bytes\n", result.OriginalSize)
+	fmt.Fprintf(w, "Compressed size: %d bytes\n", result.CompressedSize)
+	if stats := result.Stats; stats != nil {
+		padding := stats.PayloadSize*8 - stats.PayloadBits
+		fmt.Fprintf(w, "  header %d bytes, payload %d bits + %d bits padding, checksum %d bytes\n", stats.HeaderSize, stats.PayloadBits, padding, stats.TrailerSize)
+	}
+	if result.OriginalSize > 0 {
+		ratio := float64(result.CompressedSize) / float64(result.OriginalSize)
+		fmt.Fprintf(w, "Ratio:           %.2f%% of the original (%.2f%% saved)\n", ratio*100, (1-ratio)*100)
+	}
+	fmt.Fprintf(w, "Time:            %s\n", result.Duration.Round(time.Microsecond))
+}
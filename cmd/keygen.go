@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/prashant1k99/compactor/crypt"
+	"github.com/spf13/cobra"
+)
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Create a key pair for encrypting files to public keys, or for signing them.",
+	Run:   keygen,
+}
+
+var keygenCmdHelpTemplate = `{{with .Short}}{{. | trimTrailingWhitespaces}}{{end}}
+
+Usage:
+  {{.UseLine}}
+
+Flags:
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}
+
+Description:
+  This command writes a new secret key, with its public key in a comment, to the output file or stdout.
+  Hand the public key to whoever encrypts files for you, they pass it to "compactor -r", and keep the secret key to decrypt with "compactor dec --identity".
+  A key file is created readable by its owner only and never overwritten.
+  With --signing an Ed25519 signing key is created instead, for "compactor --sign". Its public key is what "compactor verify --pubkey" and "compactor dec --pubkey" trust.
+  With --public the public keys of an existing key file are printed instead, ready to be collected into a recipients file or handed out to check signatures.
+
+Examples:
+  # Create a key pair
+  compactor keygen -o ~/.compactor/key.txt
+
+  # Add the public key to the recipients file of the team
+  compactor keygen --public -i ~/.compactor/key.txt >> recipients.txt
+
+  # Create a release signing key and publish its public key
+  compactor keygen --signing -o signing-key.txt
+  compactor keygen --public -i signing-key.txt > release.pub
+
+`
+
+// secretKey is a generated key pair: a *crypt.X25519Identity or a
+// *crypt.SigningKey.
+type secretKey interface {
+	String() string
+}
+
+// publicKey returns the public half of key.
+func publicKey(key secretKey) fmt.Stringer {
+	switch key := key.(type) {
+	case *crypt.X25519Identity:
+		return key.Recipient()
+	case *crypt.SigningKey:
+		return key.VerifyingKey()
+	}
+	panic("compactor: unknown key type")
+}
+
+// GenerateKeyFile creates an X25519 key pair for encryption, or an Ed25519
+// one when signing is set, and writes it to outputPath, or to stdout for
+// "-". It returns the public key.
+func GenerateKeyFile(outputPath string, signing bool) (fmt.Stringer, error) {
+	var key secretKey
+	var err error
+	if signing {
+		key, err = crypt.GenerateSigningKey()
+	} else {
+		key, err = crypt.GenerateX25519Identity()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", time.Now().Format(time.RFC3339), publicKey(key), key)
+	if isStdio(outputPath) {
+		if _, err := io.WriteString(os.Stdout, content); err != nil {
+			return nil, err
+		}
+		return publicKey(key), nil
+	}
+
+	file, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("%s already exists, a key file is never overwritten", outputPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// A truncated key file is worse than none.
+		os.Remove(outputPath)
+		return nil, err
+	}
+	return publicKey(key), nil
+}
+
+// PublicKeys writes the public key of every secret key in keyFile to w. The
+// file holds either encryption or signing keys.
+func PublicKeys(keyFile string, w io.Writer) error {
+	file, err := openInput(keyFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	var keys []secretKey
+	if bytes.Contains(data, []byte(crypt.SigningKeyPrefix)) {
+		signingKeys, err := crypt.ParseSigningKeys(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for _, key := range signingKeys {
+			keys = append(keys, key)
+		}
+	} else {
+		identities, err := crypt.ParseIdentities(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for _, identity := range identities {
+			keys = append(keys, identity.(*crypt.X25519Identity))
+		}
+	}
+	for _, key := range keys {
+		fmt.Fprintln(w, publicKey(key))
+	}
+	return nil
+}
+
+func keygen(cmd *cobra.Command, args []string) {
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	printPublic, err := cmd.Flags().GetBool("public")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if printPublic {
+		inputFile, err := cmd.Flags().GetString("input")
+		if err != nil {
+			os.Exit(1)
+		}
+		if err := PublicKeys(inputFile, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	signing, err := cmd.Flags().GetBool("signing")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	public, err := GenerateKeyFile(outputPath, signing)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if !isStdio(outputPath) {
+		fmt.Printf("Public key: %s\n", public)
+	}
+}
+
+func init() {
+	keygenCmd.Flags().StringP("output", "o", "", "Enter the path of the new key file (\"-\" or omitted writes to stdout)")
+	keygenCmd.Flags().Bool("signing", false, "Create an Ed25519 signing key for --sign instead of an encryption key")
+	keygenCmd.Flags().BoolP("public", "y", false, "Print the public keys of the key file given with -i instead of creating a key")
+	keygenCmd.Flags().StringP("input", "i", "", "Enter the path of the key file for --public (\"-\" or omitted reads stdin)")
+	keygenCmd.Flags().BoolP("help", "h", false, "Show help for all the options")
+	keygenCmd.SetHelpTemplate(keygenCmdHelpTemplate)
+
+	rootCmd.AddCommand(keygenCmd)
+}
@@ -0,0 +1,280 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/prashant1k99/compactor/crypt"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// keySource says which keys encrypt, decrypt and check a file. A password
+// comes from passwordFile when it is set, otherwise from the terminal. The
+// key files hold X25519 public keys to encrypt to, secret keys to decrypt
+// with, or Ed25519 public keys to check signatures with.
+type keySource struct {
+	passwordFile string
+	// withPassword encrypts with a password even without passwordFile.
+	withPassword   bool
+	recipientFiles []string
+	identityFiles  []string
+	// trustedKeys are files of public keys, or keys given directly, that
+	// input must be signed with.
+	trustedKeys []string
+}
+
+// readPasswordFile returns the first line of path.
+func readPasswordFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	password, _, _ := bytes.Cut(data, []byte("\n"))
+	password = bytes.TrimSuffix(password, []byte("\r"))
+	if len(password) == 0 {
+		return nil, fmt.Errorf("%s: the first line is empty, it must hold the password", path)
+	}
+	return password, nil
+}
+
+// promptPassword reads a password from the terminal without echoing it.
+// The terminal is opened directly, so stdin can carry the data.
+func promptPassword(prompt string, confirm bool) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.New("no terminal to ask for the password on, use --password-file")
+	}
+	defer tty.Close()
+
+	read := func(prompt string) ([]byte, error) {
+		fmt.Fprint(tty, prompt)
+		defer fmt.Fprintln(tty)
+		return term.ReadPassword(int(tty.Fd()))
+	}
+
+	password, err := read(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) == 0 {
+		return nil, errors.New("the password cannot be empty")
+	}
+	if confirm {
+		again, err := read("Confirm password: ")
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(password, again) {
+			return nil, errors.New("the passwords do not match")
+		}
+	}
+	return password, nil
+}
+
+func (k *keySource) password(confirm bool) ([]byte, error) {
+	if k.passwordFile != "" {
+		return readPasswordFile(k.passwordFile)
+	}
+	return promptPassword("Enter password: ", confirm)
+}
+
+// encrypts reports whether k holds anything to encrypt to.
+func (k *keySource) encrypts() bool {
+	return k.withPassword || k.passwordFile != "" || len(k.recipientFiles) > 0
+}
+
+// recipients returns who to encrypt to. An entry of recipientFiles may be a
+// public key itself instead of a file.
+func (k *keySource) recipients() ([]crypt.Recipient, error) {
+	var recipients []crypt.Recipient
+	if k.withPassword || k.passwordFile != "" {
+		password, err := k.password(true)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, crypt.NewScryptRecipient(password))
+	}
+	for _, name := range k.recipientFiles {
+		if strings.HasPrefix(name, crypt.PublicKeyPrefix) {
+			recipient, err := crypt.ParseX25519Recipient(name)
+			if err != nil {
+				return nil, err
+			}
+			recipients = append(recipients, recipient)
+			continue
+		}
+		parsed, err := parseKeyFile(name, crypt.ParseRecipients)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, parsed...)
+	}
+	return recipients, nil
+}
+
+// identities returns what to try decrypting with. It is only called for
+// encrypted input, so plain files never prompt. With identity files the
+// password is only used when it comes from passwordFile.
+func (k *keySource) identities() ([]crypt.Identity, error) {
+	var identities []crypt.Identity
+	for _, name := range k.identityFiles {
+		parsed, err := parseKeyFile(name, crypt.ParseIdentities)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, parsed...)
+	}
+	if len(k.identityFiles) == 0 || k.passwordFile != "" {
+		password, err := k.password(false)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, crypt.NewScryptIdentity(password))
+	}
+	return identities, nil
+}
+
+// trusted returns the keys input must be signed with, none when signatures
+// are not checked.
+func (k *keySource) trusted() ([]*crypt.VerifyingKey, error) {
+	var trusted []*crypt.VerifyingKey
+	for _, name := range k.trustedKeys {
+		if strings.HasPrefix(name, crypt.VerifyingKeyPrefix) {
+			key, err := crypt.ParseVerifyingKey(name)
+			if err != nil {
+				return nil, err
+			}
+			trusted = append(trusted, key)
+			continue
+		}
+		parsed, err := parseKeyFile(name, crypt.ParseVerifyingKeys)
+		if err != nil {
+			return nil, err
+		}
+		trusted = append(trusted, parsed...)
+	}
+	return trusted, nil
+}
+
+// parseKeyFile reads the keys in the file name with parse.
+func parseKeyFile[T any](name string, parse func(io.Reader) ([]T, error)) ([]T, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys, err := parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return keys, nil
+}
+
+// outputKeys are the keys the output of a compression is encrypted to and
+// signed with. Both are optional.
+type outputKeys struct {
+	recipients []crypt.Recipient
+	signingKey *crypt.SigningKey
+}
+
+// protect returns w, or a writer encrypting and signing to w. The signature
+// covers the file as stored, so it is checked without decrypting. finish
+// writes the last encrypted chunk and the signature.
+func (k *outputKeys) protect(w io.Writer) (out io.Writer, finish func() error, err error) {
+	if k == nil {
+		return w, func() error { return nil }, nil
+	}
+
+	var closers []io.Closer
+	if k.signingKey != nil {
+		sw := crypt.NewSigner(w, k.signingKey)
+		w = sw
+		closers = append(closers, sw)
+	}
+	if len(k.recipients) > 0 {
+		zw, err := crypt.Encrypt(w, k.recipients...)
+		if err != nil {
+			return nil, nil, err
+		}
+		w = zw
+		closers = append(closers, zw)
+	}
+	return w, func() error {
+		// The innermost writer is closed first.
+		for i := len(closers) - 1; i >= 0; i-- {
+			if err := closers[i].Close(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// readSigningKey reads the signing key for --sign from path, which must hold
+// exactly one.
+func readSigningKey(path string) (*crypt.SigningKey, error) {
+	keys, err := parseKeyFile(path, crypt.ParseSigningKeys)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 1 {
+		return nil, fmt.Errorf("%s holds %d signing keys, a file is signed with one", path, len(keys))
+	}
+	return keys[0], nil
+}
+
+// openDecrypted returns input, or a reader of the decrypted contents when
+// input is encrypted. decrypted tells which.
+func openDecrypted(input *bufio.Reader, keys *keySource) (_ *bufio.Reader, decrypted bool, err error) {
+	if magic, _ := input.Peek(len(crypt.Magic)); !crypt.IsEncrypted(magic) {
+		return input, false, nil
+	}
+	identities, err := keys.identities()
+	if err != nil {
+		return nil, false, err
+	}
+	r, err := crypt.Decrypt(input, identities...)
+	if err != nil {
+		return nil, false, err
+	}
+	return bufio.NewReader(r), true, nil
+}
+
+// trustedKeysEnv names the environment variable that configures the trusted
+// keys when --pubkey is not given, so every command checks signatures.
+const trustedKeysEnv = "COMPACTOR_PUBKEY"
+
+// decryptionKeys reads --password-file, --identity and --pubkey of cmd.
+func decryptionKeys(cmd *cobra.Command) (*keySource, error) {
+	passwordFile, err := cmd.Flags().GetString("password-file")
+	if err != nil {
+		return nil, err
+	}
+	identityFiles, err := cmd.Flags().GetStringArray("identity")
+	if err != nil {
+		return nil, err
+	}
+	trustedKeys, err := cmd.Flags().GetStringArray("pubkey")
+	if err != nil {
+		return nil, err
+	}
+	if configured := os.Getenv(trustedKeysEnv); len(trustedKeys) == 0 && configured != "" {
+		trustedKeys = []string{configured}
+	}
+	return &keySource{passwordFile: passwordFile, identityFiles: identityFiles, trustedKeys: trustedKeys}, nil
+}
+
+// isEncryptedFile reports whether file starts with the magic of an encrypted
+// file.
+func isEncryptedFile(file io.ReaderAt) bool {
+	magic := make([]byte, len(crypt.Magic))
+	n, _ := file.ReadAt(magic, 0)
+	return crypt.IsEncrypted(magic[:n])
+}
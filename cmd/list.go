@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print the files in an archive without decompressing them.",
+	Run:   listArchive,
+}
+
+var listCmdHelpTemplate = `{{with .Short}}{{. | trimTrailingWhitespaces}}{{end}}
+
+Usage:
+  {{.UseLine}}
+
+Flags:
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}
+
+Description:
+  This command reads the index at the end of an archive written from a directory and prints the mode, original size, compressed size, modification time and path of every entry.
+  No file data is decompressed.
+  The index of an encrypted archive is only readable after decrypting the whole archive.
+
+Examples:
+  # List an archive
+  compactor list -i logs.crypt
+
+`
+
+// ListArchive writes the index of the archive inputFile to w.
+func ListArchive(inputFile string, w io.Writer, keys *keySource) error {
+	file, err := openInput(inputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	signed, err := openVerifiedInput(file, keys)
+	if err != nil {
+		return err
+	}
+	defer signed.cleanup()
+
+	input, decrypted, err := openDecrypted(bufio.NewReader(signed), keys)
+	if err != nil {
+		return err
+	}
+	if !isArchiveInput(input) {
+		return errors.New("not an archive, only files compressed from a directory have an index")
+	}
+	if decrypted {
+		signed.file = nil
+	}
+	ar, cleanup, err := openArchive(signed.file, signed.size, input)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var files int
+	var size, compressedSize int64
+	for _, entry := range ar.Entries {
+		name := entry.Path
+		if entry.IsDir() {
+			name += "/"
+		} else {
+			files++
+		}
+		size += entry.Size
+		compressedSize += entry.CompressedSize
+		fmt.Fprintf(w, "%s %12d %12d %s %s\n", entry.Mode, entry.Size, entry.CompressedSize, entry.ModTime.Format("2006-01-02 15:04"), name)
+	}
+	fmt.Fprintf(w, "%d files, %d bytes, %d bytes compressed\n", files, size, compressedSize)
+	return nil
+}
+
+func listArchive(cmd *cobra.Command, args []string) {
+	inputFile, err := cmd.Flags().GetString("input")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	keys, err := decryptionKeys(cmd)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	if err := ListArchive(inputFile, os.Stdout, keys); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	listCmd.Flags().StringP("input", "i", "", "Enter file path of the archive (\"-\" or omitted reads stdin)")
+	listCmd.Flags().String("password-file", "", "Read the password of an encrypted file from the first line of this file")
+	listCmd.Flags().StringArray("identity", nil, "Decrypt with the X25519 secret keys in this file, as written by \"compactor keygen\" (repeatable)")
+	listCmd.Flags().StringArray("pubkey", nil, "Refuse archives that are not signed by one of the public keys in this file, or by a public key given directly (repeatable, default $"+trustedKeysEnv+")")
+	listCmd.Flags().BoolP("help", "h", false, "Show help for all the options")
+	listCmd.SetHelpTemplate(listCmdHelpTemplate)
+
+	rootCmd.AddCommand(listCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/schollz/progressbar/v3"
+)
+
+func newProgressBar(w io.Writer) *progressbar.ProgressBar {
+	return progressbar.NewOptions(100,
+		progressbar.OptionSetWriter(w),
+		progressbar.OptionEnableColorCodes(true),
+		progressbar.OptionSetWidth(50),
+		progressbar.OptionSetDescription("Initializing..."),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "[green]█[reset]",
+			SaucerHead:    "[green]█[reset]",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+	)
+}
+
+// progressReader moves the bar from start to start+span as the underlying
+// reader is consumed.
+type progressReader struct {
+	reader    io.Reader
+	bar       *progressbar.ProgressBar
+	totalSize int64
+	bytesRead int64
+	start     int
+	span      int
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.reader.Read(p)
+	pr.bytesRead += int64(n)
+	if pr.totalSize > 0 {
+		progress := int(float64(pr.bytesRead) / float64(pr.totalSize) * float64(pr.span))
+		pr.bar.Set(pr.start + progress)
+	}
+	return n, err
+}
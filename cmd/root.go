@@ -9,13 +9,36 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/prashant1k99/compactor/compactor"
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
 	"github.com/spf13/cobra"
 )
 
+// maxBlockSizeMiB is the largest --block-size accepted on the command line.
+const maxBlockSizeMiB = 16
+
+// presetAuto lets --preset pick the best preset for every file, or none.
+const presetAuto = "auto"
+
+// presetNames lists the values of --preset.
+func presetNames() string {
+	var names []string
+	for _, table := range compactor.Presets() {
+		names = append(names, fmt.Sprintf("%q", table.Name()))
+	}
+	return strings.Join(names, ", ")
+}
+
+// Output formats of --format.
+const (
+	formatCrypt = "crypt"
+	formatGzip  = "gzip"
+)
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "compactor",
-	Short: "Compress Single file using Huffmen Encoder",
+	Short: "Compress a file or directory using Huffmen Encoder",
 	Run:   compressFile,
 }
 
@@ -27,6 +50,18 @@ Usage:
   # Decompress a file
   compactor dec
 
+  # Check a compressed file for corruption
+  compactor verify
+
+  # List the files in an archive
+  compactor list
+
+  # Create a key pair for encryption to public keys
+  compactor keygen
+
+  # Train a shared Huffman table on a corpus of small files
+  compactor train
+
 
 Flags:
 {{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}
@@ -34,6 +69,19 @@ Flags:
 Description:
   This command compresses a single file using Huffman encoding. You need to provide the input file path and optionally the output file path.
   Default output path is whatever the folder path for input file
+  Without an input file (or with "-") the data is read from stdin and written to stdout.
+  With --block-size the input is split into blocks that each get their own Huffman table and are compressed and decompressed in parallel.
+  With --adaptive the Huffman tree is updated after every byte, so the input is read only once and nothing is buffered.
+  With --lz repeated strings are replaced by references to earlier occurrences before Huffman coding, which shrinks logs and JSON far more.
+  With --seekable a block index is appended, so "compactor dec --range" can decompress any byte range without decoding what comes before it.
+  With --format gzip the output is a standard gzip file (default name: input.gz) that gzip, zcat and most other tools can read.
+  With --dry-run the input is analysed and the exact compressed size, ratio and time are printed, but no output file is created. The default mode only needs the frequency table for that, the other modes compress into a discarding writer.
+  When the input is a directory the whole tree is written to one archive (default name: directory.crypt) with an index of paths, sizes, modes and modification times. Every file is compressed on its own with the chosen options.
+  With --encrypt the compressed output is encrypted with a password using scrypt and ChaCha20-Poly1305. The password is asked for on the terminal, or read from --password-file. Any change to the encrypted file, including truncation, is detected on decryption.
+  With --recipients the output is encrypted to X25519 public keys instead, created with "compactor keygen". Every recipient can decrypt the file with their own secret key, no password has to be shared. It can be combined with --encrypt to allow a password as well.
+  With --sign an Ed25519 signature over the whole output, after any encryption, is appended. "compactor verify --pubkey" and "compactor dec --pubkey" check it with the public key.
+  With --table the input is encoded with a table from "compactor train" and only the ID of the table is stored instead of a code table, which saves most of the header of small files. Decompressing needs the same table.
+  With --preset one of the tables built into compactor is used instead: "text" for English prose, "json", "source" for program code, "hex" and "base64". The header only records the one byte preset ID and decompressing needs nothing extra. With --preset auto the exact output size of every preset and of a table of its own is compared, and the smallest wins, so tiny inputs go without table overhead while larger ones keep their own table. For a directory every file makes its own choice.
 
 Examples:
   # Compress a file
@@ -42,6 +90,48 @@ Examples:
   # Compress a file with default output path
   compactor -i input.txt
 
+  # Compress from a pipe
+  cat input.txt | compactor -c > input.txt.crypt
+
+  # Compress a large file in 4 MiB blocks on all cores
+  compactor -i large.log -b 4
+
+  # Compress repetitive JSON logs with the LZ77 stage
+  compactor -i app.json -z
+
+  # Compress an endless stream as it arrives
+  tail -f app.log | compactor -a > app.log.crypt
+
+  # Compress a large dataset so ranges of it can be read later
+  compactor -i dataset.bin -s
+
+  # Write a gzip file for tools that only understand gzip
+  compactor -i input.txt -f gzip
+
+  # See how well a file would compress before writing anything
+  compactor -i large.log --dry-run
+
+  # Archive a directory into logs.crypt
+  compactor -i ./logs/ -o logs.crypt
+
+  # Compress and encrypt with a password typed on the terminal
+  compactor -i secrets.txt -e
+
+  # Encrypt with a password kept in a file
+  compactor -i secrets.txt --password-file ~/.compactor-pass
+
+  # Encrypt a directory to the public keys of the team
+  compactor -i ./data/ -r recipients.txt
+
+  # Sign a release artifact
+  compactor -i ./release/ -o release.crypt --sign signing-key.txt
+
+  # Compress a small JSON document with a table trained on similar ones
+  compactor -i doc.json --table json.table
+
+  # Archive many small files, each with the best built-in table or its own
+  compactor -i ./messages/ --preset auto
+
 `
 
 // Custom help template for decompressCmd
@@ -56,6 +146,14 @@ Flags:
 Description:
   This command decompresses a file that was compressed using Huffman encoding. You need to provide the input compressed file path and optionally the output file path.
   Default output path is whatever the folder path for input file
+  Without an input file (or with "-") the data is read from stdin and written to stdout.
+  gzip files are recognised by their header and decompressed as well.
+  With --range only that byte range of the decompressed data is written, decoding just the blocks that hold it. This needs a file compressed with --seekable.
+  Archives are restored into a directory (default: the input name without its extension) with the stored modes and modification times.
+  Encrypted files are recognised as well. The password is asked for on the terminal, or read from --password-file, and decryption fails on a wrong password or any modified or truncated data.
+  Files encrypted to public keys are decrypted with --identity and a secret key file from "compactor keygen". With --identity no password is asked for.
+  With --pubkey, or the trusted keys in $COMPACTOR_PUBKEY, the signature of the input is checked before anything is decompressed, and input that is unsigned, signed by another key or modified after signing is refused. Input from stdin is spooled to a temporary file for that.
+  Files compressed with --table need the same table file passed to --table, the file only stores the ID of its table.
 
 Examples:
   # Decompress a file
@@ -64,6 +162,30 @@ Examples:
   # Decompress a file with default output path
   compactor dec -i input.crypt
 
+  # Decompress into a pipe
+  compactor dec < input.crypt | grep error
+
+  # Decompress a gzip file
+  compactor dec -i input.txt.gz
+
+  # Decompress 4 MiB starting at 1 GiB
+  compactor dec -i dataset.bin.crypt --range 1G:+4M -o part.bin
+
+  # Restore an archive into ./restored
+  compactor dec -i logs.crypt -o restored
+
+  # Decrypt and decompress with a password kept in a file
+  compactor dec -i secrets.txt.crypt --password-file ~/.compactor-pass
+
+  # Decrypt with a secret key
+  compactor dec -i data.crypt --identity ~/.compactor/key.txt
+
+  # Only decompress what the release key signed
+  compactor dec -i release.crypt --pubkey release.pub
+
+  # Decompress a file compressed with a trained table
+  compactor dec -i doc.json.crypt --table json.table
+
 `
 
 func compressFile(cmd *cobra.Command, args []string) {
@@ -77,16 +199,223 @@ func compressFile(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if outputFilePath == "" {
-		inputDir := filepath.Dir(inputFile)
-		outputFilePath = inputDir
+	toStdout, err := cmd.Flags().GetBool("stdout")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		os.Exit(1)
+	}
+	// A directory is archived. Its path is cleaned first so that "logs/"
+	// and "." get an output named after the directory next to it.
+	archive := false
+	if !isStdio(inputFile) {
+		if info, err := os.Stat(inputFile); err == nil && info.IsDir() {
+			archive = true
+			inputFile = filepath.Clean(inputFile)
+			if base := filepath.Base(inputFile); base == "." || base == ".." {
+				if inputFile, err = filepath.Abs(inputFile); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(1)
+				}
+			}
+		}
+	}
+
+	nameFor := compressedFileName
+	switch format {
+	case formatCrypt:
+	case formatGzip:
+		nameFor = gzipFileName
+	default:
+		fmt.Fprintf(os.Stderr, "--format must be %q or %q\n", formatCrypt, formatGzip)
+		os.Exit(1)
+	}
+	outputFilePath = resolveOutputPath(inputFile, outputFilePath, toStdout, nameFor)
+
+	blockSize, err := cmd.Flags().GetInt("block-size")
+	if err != nil {
+		os.Exit(1)
+	}
+	if blockSize < 0 || blockSize > maxBlockSizeMiB {
+		fmt.Fprintf(os.Stderr, "--block-size must be between 1 and %d MiB, or 0 to disable block mode\n", maxBlockSizeMiB)
+		os.Exit(1)
+	}
+
+	jobs, err := cmd.Flags().GetInt("jobs")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	adaptive, err := cmd.Flags().GetBool("adaptive")
+	if err != nil {
+		os.Exit(1)
+	}
+	if adaptive && blockSize > 0 {
+		fmt.Fprintln(os.Stderr, "--adaptive cannot be combined with --block-size")
+		os.Exit(1)
+	}
+
+	lz, err := cmd.Flags().GetBool("lz")
+	if err != nil {
+		os.Exit(1)
+	}
+	if lz && adaptive {
+		fmt.Fprintln(os.Stderr, "--lz cannot be combined with --adaptive")
+		os.Exit(1)
+	}
+
+	maxCodeLength, err := cmd.Flags().GetInt("max-code-length")
+	if err != nil {
+		os.Exit(1)
+	}
+	if maxCodeLength < 0 || maxCodeLength > compressutils.MaxCodeLength {
+		fmt.Fprintf(os.Stderr, "--max-code-length must be between 1 and %d bits, or 0 for the default of %d\n", compressutils.MaxCodeLength, compressutils.DefaultMaxCodeLength)
+		os.Exit(1)
+	}
+	if maxCodeLength > 0 && adaptive {
+		fmt.Fprintln(os.Stderr, "--max-code-length cannot be combined with --adaptive")
+		os.Exit(1)
+	}
+
+	seekable, err := cmd.Flags().GetBool("seekable")
+	if err != nil {
+		os.Exit(1)
+	}
+	if seekable && adaptive {
+		fmt.Fprintln(os.Stderr, "--seekable cannot be combined with --adaptive")
+		os.Exit(1)
+	}
+
+	tableFile, err := cmd.Flags().GetString("table")
+	if err != nil {
+		os.Exit(1)
+	}
+	if tableFile != "" && (blockSize > 0 || adaptive || lz || maxCodeLength > 0 || seekable) {
+		fmt.Fprintln(os.Stderr, "--table cannot be combined with --block-size, --adaptive, --lz, --max-code-length or --seekable")
+		os.Exit(1)
 	}
-	inputFileName := filepath.Base(inputFile)
-	outputFilePath = filepath.Join(outputFilePath, inputFileName+".crypt")
 
-	err = CompressFile(inputFile, outputFilePath)
+	preset, err := cmd.Flags().GetString("preset")
 	if err != nil {
-		fmt.Println(err)
+		os.Exit(1)
+	}
+	if preset != "" && (blockSize > 0 || adaptive || lz || maxCodeLength > 0 || seekable || tableFile != "") {
+		fmt.Fprintln(os.Stderr, "--preset cannot be combined with --block-size, --adaptive, --lz, --max-code-length, --seekable or --table")
+		os.Exit(1)
+	}
+
+	if format == formatGzip && (blockSize > 0 || adaptive || lz || maxCodeLength > 0 || seekable || tableFile != "" || preset != "") {
+		fmt.Fprintln(os.Stderr, "--format gzip cannot be combined with --block-size, --adaptive, --lz, --max-code-length, --seekable, --table or --preset")
+		os.Exit(1)
+	}
+	if format == formatGzip && archive {
+		fmt.Fprintln(os.Stderr, "--format gzip compresses a single file, directories can only be archived in the crypt format")
+		os.Exit(1)
+	}
+
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		os.Exit(1)
+	}
+	if dryRun && archive {
+		fmt.Fprintln(os.Stderr, "--dry-run estimates a single file, not a directory")
+		os.Exit(1)
+	}
+
+	keys := &keySource{}
+	if keys.withPassword, err = cmd.Flags().GetBool("encrypt"); err != nil {
+		os.Exit(1)
+	}
+	if keys.passwordFile, err = cmd.Flags().GetString("password-file"); err != nil {
+		os.Exit(1)
+	}
+	if keys.recipientFiles, err = cmd.Flags().GetStringArray("recipients"); err != nil {
+		os.Exit(1)
+	}
+	if keys.encrypts() && format == formatGzip {
+		fmt.Fprintln(os.Stderr, "--encrypt and --recipients cannot be combined with --format gzip")
+		os.Exit(1)
+	}
+	if keys.encrypts() && dryRun {
+		fmt.Fprintln(os.Stderr, "--dry-run cannot be combined with --encrypt or --recipients")
+		os.Exit(1)
+	}
+	signingKeyFile, err := cmd.Flags().GetString("sign")
+	if err != nil {
+		os.Exit(1)
+	}
+	if signingKeyFile != "" && format == formatGzip {
+		fmt.Fprintln(os.Stderr, "--sign cannot be combined with --format gzip, gzip tools do not accept the appended signature")
+		os.Exit(1)
+	}
+	if signingKeyFile != "" && dryRun {
+		fmt.Fprintln(os.Stderr, "--dry-run cannot be combined with --sign")
+		os.Exit(1)
+	}
+
+	var output *outputKeys
+	if keys.encrypts() || signingKeyFile != "" {
+		output = &outputKeys{}
+	}
+	if keys.encrypts() {
+		if output.recipients, err = keys.recipients(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if signingKeyFile != "" {
+		if output.signingKey, err = readSigningKey(signingKeyFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	opts := compactor.Options{
+		BlockSize:     blockSize << 20,
+		Concurrency:   jobs,
+		Adaptive:      adaptive,
+		LZ:            lz,
+		MaxCodeLength: maxCodeLength,
+		Seekable:      seekable,
+	}
+	if tableFile != "" {
+		if opts.Table, err = readTable(tableFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	switch preset {
+	case "":
+	case presetAuto:
+		opts.AutoPreset = true
+	default:
+		if opts.Table, err = compactor.Preset(preset); err != nil {
+			fmt.Fprintf(os.Stderr, "--preset must be %q or one of %s\n", presetAuto, presetNames())
+			os.Exit(1)
+		}
+	}
+	switch {
+	case dryRun:
+		var result *dryRunResult
+		if result, err = DryRunFile(inputFile, opts, format); err == nil {
+			name := inputFile
+			if isStdio(inputFile) {
+				name = "stdin"
+			}
+			printDryRun(os.Stdout, name, result)
+		}
+	case archive:
+		err = CompressDir(inputFile, outputFilePath, opts, output)
+	case format == formatGzip:
+		err = CompressGzipFile(inputFile, outputFilePath)
+	default:
+		err = CompressFile(inputFile, outputFilePath, opts, output)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
@@ -115,30 +444,80 @@ func decompressFile(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	if outputFilePath == "" {
-		inputDir := filepath.Dir(inputFile)
-		outputFilePath = inputDir
+	toStdout, err := cmd.Flags().GetBool("stdout")
+	if err != nil {
+		os.Exit(1)
+	}
+	outputFilePath = resolveOutputPath(inputFile, outputFilePath, toStdout, decompressedFileName)
+
+	keys, err := decryptionKeys(cmd)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	tableFiles, err := cmd.Flags().GetStringArray("table")
+	if err != nil {
+		os.Exit(1)
+	}
+	tables, err := readTables(tableFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	byteRange, err := cmd.Flags().GetString("range")
+	if err != nil {
+		os.Exit(1)
+	}
+	if byteRange != "" {
+		start, length, err := parseByteRange(byteRange)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		err = DecompressRange(inputFile, outputFilePath, start, length, keys)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-	inputFileName := filepath.Base(inputFile)
-	inputFileNameWithoutExt := strings.TrimSuffix(inputFileName, filepath.Ext(inputFileName))
-	outputFilePath = filepath.Join(outputFilePath, inputFileNameWithoutExt)
 
-	err = DecompressFile(inputFile, outputFilePath)
+	err = DecompressFile(inputFile, outputFilePath, keys, tables)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
 func init() {
-	rootCmd.Flags().StringP("input", "i", "", "Enter the path of the file to be compressed")
-	rootCmd.Flags().StringP("output", "o", "", "Enter the path for the output compressed file")
+	rootCmd.Flags().StringP("input", "i", "", "Enter the path of the file or directory to be compressed (\"-\" or omitted reads stdin)")
+	rootCmd.Flags().StringP("output", "o", "", "Enter the path for the output compressed file (\"-\" writes to stdout)")
+	rootCmd.Flags().BoolP("stdout", "c", false, "Write the compressed data to stdout")
+	rootCmd.Flags().IntP("block-size", "b", 0, "Compress in independent blocks of this many MiB (1-16) in parallel, 0 uses one table for the whole file")
+	rootCmd.Flags().IntP("jobs", "j", 0, "Number of blocks compressed at the same time in block mode (default: number of CPUs)")
+	rootCmd.Flags().BoolP("adaptive", "a", false, "Use adaptive Huffman coding, which reads the input once and suits unbounded streams")
+	rootCmd.Flags().BoolP("lz", "z", false, "Find repeated strings with LZ77 before Huffman coding, in blocks of --block-size (default 4 MiB)")
+	rootCmd.Flags().IntP("max-code-length", "l", 0, "Longest Huffman code in bits (1-64), limited optimally with package-merge (default 15)")
+	rootCmd.Flags().BoolP("seekable", "s", false, "Append a block index so byte ranges can be decompressed on their own, implies block mode (default 4 MiB blocks)")
+	rootCmd.Flags().StringP("format", "f", formatCrypt, "Output format: \"crypt\" for the compactor format or \"gzip\" for a standard gzip file")
+	rootCmd.Flags().Bool("dry-run", false, "Report the exact compressed size, ratio and time without writing any output")
+	rootCmd.Flags().BoolP("encrypt", "e", false, "Encrypt the output with a password, asked for on the terminal unless --password-file is given")
+	rootCmd.Flags().String("password-file", "", "Read the password from the first line of this file (implies --encrypt)")
+	rootCmd.Flags().StringArrayP("recipients", "r", nil, "Encrypt to the X25519 public keys in this file, one per line, or to a single public key given directly (repeatable)")
+	rootCmd.Flags().String("sign", "", "Append an Ed25519 signature made with the signing key in this file, as written by \"compactor keygen --signing\"")
+	rootCmd.Flags().String("table", "", "Encode with this trained table from \"compactor train\" and store only its ID, for many small similar files")
+	rootCmd.Flags().String("preset", "", "Encode with the built-in table of this name, storing only its ID, or \"auto\" to pick the best for the input (one of "+presetNames()+")")
 	rootCmd.Flags().BoolP("help", "h", false, "Show help for all the options")
-	rootCmd.MarkFlagRequired("input")
 
-	decompressCmd.Flags().StringP("input", "i", "", "Enter file path of Compressed file")
-	decompressCmd.Flags().StringP("output", "o", "", "Enter path for decompressed file")
-	decompressCmd.MarkFlagRequired("input")
+	decompressCmd.Flags().StringP("input", "i", "", "Enter file path of Compressed file (\"-\" or omitted reads stdin)")
+	decompressCmd.Flags().StringP("output", "o", "", "Enter path for decompressed file, or the directory an archive is restored into (\"-\" writes to stdout)")
+	decompressCmd.Flags().BoolP("stdout", "c", false, "Write the decompressed data to stdout")
+	decompressCmd.Flags().StringP("range", "r", "", "Only decompress the bytes START:END or START:+LENGTH, sizes may end in K, M or G (needs a --seekable file)")
+	decompressCmd.Flags().String("password-file", "", "Read the password of an encrypted file from the first line of this file")
+	decompressCmd.Flags().StringArray("identity", nil, "Decrypt with the X25519 secret keys in this file, as written by \"compactor keygen\" (repeatable)")
+	decompressCmd.Flags().StringArray("pubkey", nil, "Refuse input that is not signed by one of the public keys in this file, or by a public key given directly (repeatable, default $"+trustedKeysEnv+")")
+	decompressCmd.Flags().StringArray("table", nil, "Decode with this trained table from \"compactor train\", needed for files compressed with --table (repeatable)")
 	decompressCmd.Flags().BoolP("help", "h", false, "Show help for all the options")
 
 	rootCmd.SetHelpTemplate(rootCmdHelpTemplate)
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/prashant1k99/compactor/crypt"
+)
+
+// signedInput is an input file without its signature trailer, if it has
+// one.
+type signedInput struct {
+	io.Reader
+	// file reads the same bytes at random. It is nil for a stream, whose
+	// size is unknown and 0.
+	file io.ReaderAt
+	size int64
+	// signer is the key the signature was checked with, nil when no keys
+	// are trusted.
+	signer  *crypt.VerifyingKey
+	cleanup func()
+}
+
+// openSignedInput returns the contents of file before its signature. With
+// trusted keys the signature is checked against them before anything is
+// returned, so input that is not signed by one of them is refused before
+// any of it is decompressed. A stream is spooled to a temporary file for
+// that, otherwise it is read as it arrives.
+func openSignedInput(file *os.File, trusted []*crypt.VerifyingKey) (*signedInput, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {}
+	if !info.Mode().IsRegular() {
+		if len(trusted) == 0 {
+			return &signedInput{Reader: crypt.NewSignedReader(file), cleanup: cleanup}, nil
+		}
+		if file, cleanup, err = spoolToTempFile(file); err != nil {
+			return nil, err
+		}
+		if info, err = file.Stat(); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	size := info.Size()
+	signature, err := crypt.ReadSignature(file, size)
+	if err == crypt.ErrNotSigned && len(trusted) == 0 {
+		return &signedInput{Reader: io.NewSectionReader(file, 0, size), file: file, size: size, cleanup: cleanup}, nil
+	}
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	content := io.NewSectionReader(file, 0, size-int64(crypt.SignatureSize))
+	input := &signedInput{Reader: content, file: content, size: content.Size(), cleanup: cleanup}
+	if len(trusted) > 0 {
+		if err := signature.Verify(content, trusted...); err != nil {
+			cleanup()
+			return nil, err
+		}
+		if _, err := content.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			return nil, err
+		}
+		input.signer = signature.Signer()
+	}
+	return input, nil
+}
+
+// openVerifiedInput is openSignedInput with the trusted keys of keys.
+func openVerifiedInput(file *os.File, keys *keySource) (*signedInput, error) {
+	trusted, err := keys.trusted()
+	if err != nil {
+		return nil, err
+	}
+	input, err := openSignedInput(file, trusted)
+	if err != nil && len(trusted) > 0 {
+		return nil, fmt.Errorf("refusing the input: %w", err)
+	}
+	return input, err
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prashant1k99/compactor/crypt"
+	"github.com/spf13/cobra"
+)
+
+func sign(t *testing.T, data []byte, key *crypt.SigningKey) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	sw := crypt.NewSigner(&buf, key)
+	if _, err := sw.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// inputFile returns data as a regular file, or as the read end of a pipe
+// like stdin when pipe is set.
+func inputFile(t *testing.T, data []byte, pipe bool) *os.File {
+	t.Helper()
+
+	if !pipe {
+		path := filepath.Join(t.TempDir(), "input")
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		t.Cleanup(func() { file.Close() })
+		return file
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe() error = %v", err)
+	}
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestOpenSignedInput(t *testing.T) {
+	key, _ := crypt.GenerateSigningKey()
+	other, _ := crypt.GenerateSigningKey()
+	content := bytes.Repeat([]byte("compressed data "), 1000)
+	signed := sign(t, content, key)
+	modified := bytes.Clone(signed)
+	modified[10] ^= 0xff
+
+	tests := []struct {
+		name     string
+		input    []byte
+		trusted  []*crypt.VerifyingKey
+		expected error
+	}{
+		{name: "Signed", input: signed, trusted: []*crypt.VerifyingKey{other.VerifyingKey(), key.VerifyingKey()}},
+		{name: "No trusted keys", input: signed},
+		{name: "Unsigned without trusted keys", input: content},
+		{name: "Unsigned", input: content, trusted: []*crypt.VerifyingKey{key.VerifyingKey()}, expected: crypt.ErrNotSigned},
+		{name: "Untrusted signer", input: signed, trusted: []*crypt.VerifyingKey{other.VerifyingKey()}, expected: crypt.ErrUntrustedSigner},
+		{name: "Modified after signing", input: modified, trusted: []*crypt.VerifyingKey{key.VerifyingKey()}, expected: crypt.ErrInvalidSignature},
+		{name: "Empty", input: nil, trusted: []*crypt.VerifyingKey{key.VerifyingKey()}, expected: crypt.ErrNotSigned},
+	}
+
+	for _, tt := range tests {
+		for _, pipe := range []bool{false, true} {
+			name := tt.name + "/File"
+			if pipe {
+				name = tt.name + "/Pipe"
+			}
+			t.Run(name, func(t *testing.T) {
+				input, err := openSignedInput(inputFile(t, tt.input, pipe), tt.trusted)
+				if !errors.Is(err, tt.expected) {
+					t.Fatalf("openSignedInput() error = %v, want %v", err, tt.expected)
+				}
+				if err != nil {
+					return
+				}
+				defer input.cleanup()
+
+				got, err := io.ReadAll(input)
+				if err != nil {
+					t.Fatalf("ReadAll() error = %v", err)
+				}
+				if !bytes.Equal(got, content) {
+					t.Errorf("openSignedInput() read %d bytes, want the %d bytes before the signature", len(got), len(content))
+				}
+				if len(tt.trusted) > 0 && input.signer.String() != key.VerifyingKey().String() {
+					t.Errorf("signer = %v, want %v", input.signer, key.VerifyingKey())
+				}
+			})
+		}
+	}
+}
+
+// newKeysCommand returns a command with the flags decryptionKeys reads.
+func newKeysCommand(args ...string) (*cobra.Command, error) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("password-file", "", "")
+	cmd.Flags().StringArray("identity", nil, "")
+	cmd.Flags().StringArray("pubkey", nil, "")
+	return cmd, cmd.Flags().Parse(args)
+}
+
+func TestOpenVerifiedInputTrustedKeys(t *testing.T) {
+	key, _ := crypt.GenerateSigningKey()
+	other, _ := crypt.GenerateSigningKey()
+	content := []byte("compressed data")
+	signed := sign(t, content, key)
+
+	keyFile := filepath.Join(t.TempDir(), "release.pub")
+	if err := os.WriteFile(keyFile, []byte("# release key\n"+key.VerifyingKey().String()+"\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		env      string
+		args     []string
+		input    []byte
+		expected error
+	}{
+		{name: "Environment", env: key.VerifyingKey().String(), input: signed},
+		{name: "Environment key file", env: keyFile, input: signed},
+		{name: "Unsigned with environment", env: key.VerifyingKey().String(), input: content, expected: crypt.ErrNotSigned},
+		{name: "Untrusted with environment", env: other.VerifyingKey().String(), input: signed, expected: crypt.ErrUntrustedSigner},
+		{name: "Flag", args: []string{"--pubkey", keyFile}, input: signed},
+		{name: "Unsigned with flag", args: []string{"--pubkey", keyFile}, input: content, expected: crypt.ErrNotSigned},
+		// --pubkey replaces the keys of the environment.
+		{name: "Flag over environment", env: key.VerifyingKey().String(), args: []string{"--pubkey", other.VerifyingKey().String()}, input: signed, expected: crypt.ErrUntrustedSigner},
+		{name: "Nothing trusted", input: content},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(trustedKeysEnv, tt.env)
+			cmd, err := newKeysCommand(tt.args...)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			keys, err := decryptionKeys(cmd)
+			if err != nil {
+				t.Fatalf("decryptionKeys() error = %v", err)
+			}
+
+			input, err := openVerifiedInput(inputFile(t, tt.input, true), keys)
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("openVerifiedInput() error = %v, want %v", err, tt.expected)
+			}
+			if err != nil {
+				return
+			}
+			defer input.cleanup()
+			if got, _ := io.ReadAll(input); !bytes.Equal(got, content) {
+				t.Errorf("openVerifiedInput() read %q, want %q", got, content)
+			}
+		})
+	}
+}
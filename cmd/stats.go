@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/prashant1k99/compactor/compactor"
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the byte frequencies, Huffman code lengths and entropy of a file.",
+	Run:   statsFile,
+}
+
+var statsCmdHelpTemplate = `{{with .Short}}{{. | trimTrailingWhitespaces}}{{end}}
+
+Usage:
+  {{.UseLine}}
+
+Flags:
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}
+
+Description:
+  This command counts every byte value of the input and builds the Huffman code for it without writing any output.
+  It prints the count and code length of every symbol, the Shannon entropy, the average code length, the header overhead and the exact size the default compression mode would produce.
+  An average code length close to 8 bits per byte means Huffman compression will not pay off for this data.
+
+Examples:
+  # Show the statistics of a file
+  compactor stats -i input.txt
+
+  # Machine readable output
+  compactor stats -i input.txt --json | jq .compressed_size
+
+`
+
+// fileStats is the output of the stats command.
+type fileStats struct {
+	File string `json:"file"`
+	*compactor.Stats
+	// Ratio is the compressed size as a fraction of the original size.
+	Ratio float64 `json:"ratio"`
+}
+
+// StatsFile counts the bytes of inputFile and computes the code it would be
+// compressed with, codes limited to maxCodeLength bits.
+func StatsFile(inputFile string, maxCodeLength int) (*fileStats, error) {
+	file, err := openInput(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	frequency, err := compressutils.GetFrequencyForReader(file)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := compactor.NewStats(*frequency, maxCodeLength)
+	if err != nil {
+		return nil, err
+	}
+
+	name := inputFile
+	if isStdio(inputFile) {
+		name = "stdin"
+	}
+	result := &fileStats{File: name, Stats: stats}
+	if stats.OriginalSize > 0 {
+		result.Ratio = float64(stats.CompressedSize) / float64(stats.OriginalSize)
+	}
+	return result, nil
+}
+
+func printStats(w io.Writer, stats *fileStats) {
+	fmt.Fprintf(w, "File:                %s\n", stats.File)
+	fmt.Fprintf(w, "Original size:       %d bytes\n", stats.OriginalSize)
+	fmt.Fprintf(w, "Distinct symbols:    %d\n", len(stats.Symbols))
+	fmt.Fprintf(w, "Entropy:             %.4f bits/byte\n", stats.Entropy)
+	fmt.Fprintf(w, "Average code length: %.4f bits/byte\n", stats.AverageCodeLength)
+	fmt.Fprintf(w, "Header overhead:     %d bytes (+%d byte checksum)\n", stats.HeaderSize, stats.TrailerSize)
+	fmt.Fprintf(w, "Payload:             %d bytes\n", stats.PayloadSize)
+	fmt.Fprintf(w, "Compressed size:     %d bytes (%.2f%% of the original)\n", stats.CompressedSize, stats.Ratio*100)
+
+	if len(stats.Symbols) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%-8s %12s %8s %6s\n", "Symbol", "Count", "Share", "Bits")
+	for _, symbol := range stats.Symbols {
+		share := float64(symbol.Count) / float64(stats.OriginalSize) * 100
+		fmt.Fprintf(w, "%-8s %12d %7.3f%% %6d\n", symbolName(symbol.Symbol), symbol.Count, share, symbol.CodeLength)
+	}
+}
+
+// symbolName shows printable ASCII as itself and anything else in hex.
+func symbolName(b byte) string {
+	switch {
+	case b == ' ':
+		return "' '"
+	case b > ' ' && b < 0x7f:
+		return fmt.Sprintf("%c", b)
+	default:
+		return fmt.Sprintf("0x%02x", b)
+	}
+}
+
+func statsFile(cmd *cobra.Command, args []string) {
+	inputFile, err := cmd.Flags().GetString("input")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	asJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	maxCodeLength, err := cmd.Flags().GetInt("max-code-length")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	stats, err := StatsFile(inputFile, maxCodeLength)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(stats); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	printStats(os.Stdout, stats)
+}
+
+func init() {
+	statsCmd.Flags().StringP("input", "i", "", "Enter the path of the file to analyse (\"-\" or omitted reads stdin)")
+	statsCmd.Flags().Bool("json", false, "Print the statistics as JSON")
+	statsCmd.Flags().IntP("max-code-length", "l", 0, "Longest Huffman code in bits (1-64), as for compression (default 15)")
+	statsCmd.Flags().BoolP("help", "h", false, "Show help for all the options")
+	statsCmd.SetHelpTemplate(statsCmdHelpTemplate)
+
+	rootCmd.AddCommand(statsCmd)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prashant1k99/compactor/compactor"
+	"github.com/prashant1k99/compactor/deflate"
+)
+
+// stdioPath stands for stdin as an input path and stdout as an output path.
+const stdioPath = "-"
+
+func isStdio(path string) bool {
+	return path == "" || path == stdioPath
+}
+
+// statusOutput is where progress and status messages go. They must stay out
+// of stdout when the compressed or decompressed data is written there.
+func statusOutput(outputPath string) io.Writer {
+	if outputPath == stdioPath {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// resolveOutputPath works out where the result of an operation on inputFile
+// goes: "-" writes to stdout, an existing directory receives a file named by
+// nameFor, any other path is used as the output file itself. Without an
+// explicit output the file is placed next to the input, or on stdout when
+// reading from stdin.
+func resolveOutputPath(inputFile, outputPath string, toStdout bool, nameFor func(string) string) string {
+	if toStdout || outputPath == stdioPath {
+		return stdioPath
+	}
+	if outputPath == "" {
+		if isStdio(inputFile) {
+			return stdioPath
+		}
+		outputPath = filepath.Dir(inputFile)
+	}
+	if info, err := os.Stat(outputPath); err == nil && info.IsDir() {
+		inputName := "stdin"
+		if !isStdio(inputFile) {
+			inputName = filepath.Base(inputFile)
+		}
+		return filepath.Join(outputPath, nameFor(inputName))
+	}
+	return outputPath
+}
+
+func compressedFileName(inputName string) string {
+	return inputName + ".crypt"
+}
+
+func gzipFileName(inputName string) string {
+	return inputName + ".gz"
+}
+
+func decompressedFileName(inputName string) string {
+	return strings.TrimSuffix(inputName, filepath.Ext(inputName))
+}
+
+// openInput opens inputFile, or stdin for "-" or an empty path.
+func openInput(inputFile string) (*os.File, error) {
+	if isStdio(inputFile) {
+		return os.Stdin, nil
+	}
+	return os.Open(inputFile)
+}
+
+// createOutput creates outputPath, or returns stdout for "-". The returned
+// remove function deletes a partially written file and is a no-op for stdout.
+func createOutput(outputPath string) (*os.File, func(), error) {
+	if outputPath == stdioPath {
+		return os.Stdout, func() {}, nil
+	}
+	file, err := os.OpenFile(outputPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, func() { os.Remove(outputPath) }, nil
+}
+
+// spoolToTempFile copies a stream that cannot be read twice, such as a pipe,
+// into a temporary file positioned at its start. The caller removes the file
+// with the returned cleanup function.
+func spoolToTempFile(reader io.Reader) (*os.File, func(), error) {
+	file, err := os.CreateTemp("", "compactor-spool-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	if _, err := io.Copy(file, reader); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return file, cleanup, nil
+}
+
+// gzipMagic starts every gzip file.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// openDecompressor returns a reader for the decompressed contents of r,
+// which is either a compactor file or a gzip file as told by its first bytes.
+// tables are the trained tables a compactor file may reference.
+func openDecompressor(r io.Reader, tables []*compactor.Table) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		zr, err := deflate.NewGzipReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return zr, nil
+	}
+
+	zr, err := compactor.NewReaderWithTables(br, tables...)
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/prashant1k99/compactor/compactor"
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+	"github.com/spf13/cobra"
+)
+
+var trainCmd = &cobra.Command{
+	Use:   "train",
+	Short: "Build a shared Huffman table from a corpus of similar files.",
+	Run:   trainTable,
+}
+
+var trainCmdHelpTemplate = `{{with .Short}}{{. | trimTrailingWhitespaces}}{{end}}
+
+Usage:
+  {{.UseLine}}
+
+Flags:
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}
+
+Description:
+  This command counts the bytes of every file in the corpus, a file or a directory tree, and writes one Huffman table for all of them to the output file.
+  Files compressed with "compactor --table" then store only the 8 byte ID of the table instead of a table of their own, which for small files is often larger than what Huffman coding saves.
+  The table has a code for every byte value, so files with bytes the corpus lacks can still be compressed, only less well.
+  Decompressing needs the same table, passed to "compactor dec --table" or "compactor verify --table". Keep the table file as long as the files compressed with it.
+
+Examples:
+  # Train a table on a sample of the JSON documents
+  compactor train -i corpus/ -o json.table
+
+  # Compress a document with it
+  compactor -i doc.json --table json.table
+
+  # Decompress it again
+  compactor dec -i doc.json.crypt --table json.table
+
+`
+
+// minTableCodeLength is the shortest --max-code-length that can give each of
+// the 256 byte values a code.
+const minTableCodeLength = 8
+
+// trainingResult is what "compactor train" reports.
+type trainingResult struct {
+	Table *compactor.Table
+	Files int
+	Bytes int64
+	// PayloadBits is the size of the encoded corpus without headers.
+	PayloadBits int64
+}
+
+// TrainTableFile counts the bytes of inputPath, a file or every regular file
+// in a directory tree, and writes a table trained on them to outputPath, or
+// to stdout for "-".
+func TrainTableFile(inputPath, outputPath string, maxCodeLength int) (_ *trainingResult, err error) {
+	frequency := compressutils.Frequency{}
+	result := &trainingResult{}
+	err = filepath.WalkDir(inputPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		fileFrequency, err := compressutils.GetFrequencyForFile(path)
+		if err != nil {
+			return err
+		}
+		for symbol, count := range *fileFrequency {
+			frequency[symbol] += count
+			result.Bytes += int64(count)
+		}
+		result.Files++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Bytes == 0 {
+		return nil, fmt.Errorf("%s holds no data to train on", inputPath)
+	}
+
+	if result.Table, err = compactor.TrainTable(frequency, maxCodeLength); err != nil {
+		return nil, err
+	}
+	codeLengths := result.Table.CodeLengths()
+	for symbol, count := range frequency {
+		result.PayloadBits += int64(count) * int64(codeLengths[symbol])
+	}
+
+	outputFile, removeOutput, err := createOutput(outputPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if outputFile != os.Stdout {
+			if closeErr := outputFile.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			removeOutput()
+		}
+	}()
+	if _, err = result.Table.WriteTo(outputFile); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readTables reads the table files of --table.
+func readTables(names []string) ([]*compactor.Table, error) {
+	var tables []*compactor.Table
+	for _, name := range names {
+		table, err := readTable(name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func readTable(name string) (*compactor.Table, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table, err := compactor.ReadTable(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return table, nil
+}
+
+func trainTable(cmd *cobra.Command, args []string) {
+	inputPath, err := cmd.Flags().GetString("input")
+	if err != nil {
+		os.Exit(1)
+	}
+	if inputPath == "" {
+		fmt.Fprintln(os.Stderr, "pass the corpus to train on with -i, a file or a directory")
+		os.Exit(1)
+	}
+
+	outputPath, err := cmd.Flags().GetString("output")
+	if err != nil {
+		os.Exit(1)
+	}
+	if outputPath == "" {
+		fmt.Fprintln(os.Stderr, "pass the path of the table file with -o (\"-\" writes to stdout)")
+		os.Exit(1)
+	}
+
+	maxCodeLength, err := cmd.Flags().GetInt("max-code-length")
+	if err != nil {
+		os.Exit(1)
+	}
+	if maxCodeLength != 0 && (maxCodeLength < minTableCodeLength || maxCodeLength > compressutils.MaxCodeLength) {
+		fmt.Fprintf(os.Stderr, "--max-code-length must be between %d and %d bits, or 0 for the default of %d\n", minTableCodeLength, compressutils.MaxCodeLength, compressutils.DefaultMaxCodeLength)
+		os.Exit(1)
+	}
+
+	result, err := TrainTableFile(inputPath, outputPath, maxCodeLength)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	printTraining(statusOutput(outputPath), result)
+}
+
+func printTraining(w io.Writer, result *trainingResult) {
+	fmt.Fprintf(w, "Table %s trained on %d files, %d bytes\n", result.Table.ID(), result.Files, result.Bytes)
+	fmt.Fprintf(w, "Average code length: %.3f bits per byte, the corpus encodes to %d bytes without headers\n",
+		float64(result.PayloadBits)/float64(result.Bytes), (result.PayloadBits+7)/8)
+}
+
+func init() {
+	trainCmd.Flags().StringP("input", "i", "", "Enter the path of the corpus, a file or a directory of sample files")
+	trainCmd.Flags().StringP("output", "o", "", "Enter the path of the table file to write (\"-\" writes to stdout)")
+	trainCmd.Flags().IntP("max-code-length", "l", 0, "Longest Huffman code in bits (8-64), 256 codes need at least 8 (default 15)")
+	trainCmd.Flags().BoolP("help", "h", false, "Show help for all the options")
+	trainCmd.SetHelpTemplate(trainCmdHelpTemplate)
+
+	rootCmd.AddCommand(trainCmd)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/prashant1k99/compactor/compactor"
+	"github.com/prashant1k99/compactor/crypt"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check a compressed file for corruption without writing any output.",
+	Run:   verifyFile,
+}
+
+var verifyCmdHelpTemplate = `{{with .Short}}{{. | trimTrailingWhitespaces}}{{end}}
+
+Usage:
+  {{.UseLine}}
+
+Flags:
+{{.LocalFlags.FlagUsages | trimTrailingWhitespaces}}
+
+Description:
+  This command decodes a compressed file, discards the output and compares the stored size and checksum with the decoded data.
+  For an archive every file in it is checked.
+  It exits with a non-zero status if the file is truncated or corrupt.
+  Encrypted files are decrypted first, which also checks that nothing in them was modified.
+  With --pubkey the Ed25519 signature appended by "compactor --sign" is checked as well, and the file fails when it is unsigned, signed by another key or modified after signing.
+  Files compressed with --table need the same table file passed to --table.
+
+Examples:
+  # Verify a file
+  compactor verify -i input.crypt
+
+  # Verify an encrypted file
+  compactor verify -i secrets.txt.crypt --password-file ~/.compactor-pass
+
+  # Check that a release was signed with the release key
+  compactor verify -i release.crypt --pubkey release.pub
+
+`
+
+// VerifyFile decodes inputFile to a discard sink and returns the number of
+// decompressed bytes, or the reason the file failed verification. When keys
+// trusts any signing keys the signature is checked first, and the key that
+// made it is returned. tables are the trained tables the input may have been
+// compressed with.
+func VerifyFile(inputFile string, keys *keySource, tables []*compactor.Table) (int64, *crypt.VerifyingKey, error) {
+	file, err := openInput(inputFile)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.Close()
+
+	signed, err := openVerifiedInput(file, keys)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer signed.cleanup()
+
+	input, decrypted, err := openDecrypted(bufio.NewReader(signed), keys)
+	if err != nil {
+		return 0, nil, err
+	}
+	if isArchiveInput(input) {
+		if decrypted {
+			signed.file = nil
+		}
+		size, err := verifyArchive(signed.file, signed.size, input, tables)
+		return size, signed.signer, err
+	}
+
+	reader, err := openDecompressor(input, tables)
+	if err != nil {
+		return 0, nil, err
+	}
+	size, err := io.Copy(io.Discard, reader)
+	return size, signed.signer, err
+}
+
+func verifyArchive(file io.ReaderAt, size int64, input *bufio.Reader, tables []*compactor.Table) (int64, error) {
+	ar, cleanup, err := openArchive(file, size, input)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+	ar.Tables = tables
+
+	var total int64
+	for i := range ar.Entries {
+		entry := &ar.Entries[i]
+		if entry.IsDir() {
+			continue
+		}
+		reader, err := ar.Open(entry)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", entry.Path, err)
+		}
+		n, err := io.Copy(io.Discard, reader)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", entry.Path, err)
+		}
+	}
+	return total, nil
+}
+
+func verifyFile(cmd *cobra.Command, args []string) {
+	inputFile, err := cmd.Flags().GetString("input")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	name := inputFile
+	if isStdio(inputFile) {
+		name = "stdin"
+	}
+
+	keys, err := decryptionKeys(cmd)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	tableFiles, err := cmd.Flags().GetStringArray("table")
+	if err != nil {
+		os.Exit(1)
+	}
+	tables, err := readTables(tableFiles)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	size, signer, err := VerifyFile(inputFile, keys, tables)
+	if err != nil {
+		fmt.Printf("FAIL %s: %v\n", name, err)
+		os.Exit(1)
+	}
+	if signer != nil {
+		fmt.Printf("OK %s (%d bytes, signed by %s)\n", name, size, signer)
+		return
+	}
+	fmt.Printf("OK %s (%d bytes)\n", name, size)
+}
+
+func init() {
+	verifyCmd.Flags().StringP("input", "i", "", "Enter file path of Compressed file (\"-\" or omitted reads stdin)")
+	verifyCmd.Flags().String("password-file", "", "Read the password of an encrypted file from the first line of this file")
+	verifyCmd.Flags().StringArray("identity", nil, "Decrypt with the X25519 secret keys in this file, as written by \"compactor keygen\" (repeatable)")
+	verifyCmd.Flags().StringArray("pubkey", nil, "Fail unless the file is signed by one of the public keys in this file, or by a public key given directly (repeatable, default $"+trustedKeysEnv+")")
+	verifyCmd.Flags().StringArray("table", nil, "Decode with this trained table from \"compactor train\", needed for files compressed with --table (repeatable)")
+	verifyCmd.Flags().BoolP("help", "h", false, "Show help for all the options")
+	verifyCmd.SetHelpTemplate(verifyCmdHelpTemplate)
+
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,85 @@
+package compactor
+
+import (
+	"fmt"
+	"io"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// adaptiveWriter encodes the input with adaptive Huffman coding. It needs no
+// code table, so the header goes out on the first write and nothing is ever
+// buffered beyond the BitWriter.
+type adaptiveWriter struct {
+	w         io.Writer
+	coder     *compressutils.AdaptiveCoder
+	bitWriter *compressutils.BitWriter
+	started   bool
+}
+
+func newAdaptiveWriter(w io.Writer) *adaptiveWriter {
+	return &adaptiveWriter{
+		w:     w,
+		coder: compressutils.NewAdaptiveCoder(),
+	}
+}
+
+func (aw *adaptiveWriter) start() error {
+	aw.started = true
+	aw.bitWriter = compressutils.NewBitWriter(aw.w)
+	return writeHeader(aw.w, &header{
+		version: FormatVersion,
+		flags:   flagChecksum | flagAdaptive,
+	})
+}
+
+func (aw *adaptiveWriter) write(p []byte) error {
+	if !aw.started {
+		if err := aw.start(); err != nil {
+			return err
+		}
+	}
+	if err := aw.coder.Encode(aw.bitWriter, p); err != nil {
+		return fmt.Errorf("compactor: %w", err)
+	}
+	return nil
+}
+
+func (aw *adaptiveWriter) close() error {
+	if !aw.started {
+		if err := aw.start(); err != nil {
+			return err
+		}
+	}
+	if err := aw.coder.EncodeEnd(aw.bitWriter); err != nil {
+		return err
+	}
+	return aw.bitWriter.Flush()
+}
+
+// adaptiveReader decodes the body written by an adaptiveWriter, which ends
+// with the end of stream symbol.
+type adaptiveReader struct {
+	bitReader *compressutils.BitReader
+	coder     *compressutils.AdaptiveCoder
+}
+
+func newAdaptiveReader(r io.Reader) *adaptiveReader {
+	return &adaptiveReader{
+		bitReader: compressutils.NewBitReader(r),
+		coder:     compressutils.NewAdaptiveCoder(),
+	}
+}
+
+func (ar *adaptiveReader) Read(p []byte) (int, error) {
+	n, err := ar.coder.DecodeTo(ar.bitReader, p)
+	if err == compressutils.ErrInvalidCode {
+		err = ErrCorruptData
+	}
+	return n, err
+}
+
+func (ar *adaptiveReader) trailer() io.Reader {
+	ar.bitReader.Align()
+	return ar.bitReader
+}
@@ -0,0 +1,92 @@
+package compactor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestAdaptiveRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: nil},
+		{name: "Single symbol", input: []byte(strings.Repeat("a", 100))},
+		{name: "Text", input: []byte(strings.Repeat("adaptive coding learns as it goes. ", 300))},
+		{name: "Binary", input: blockInput(40000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := NewWriter(&buf, Options{Adaptive: true})
+			// Small writes exercise the streaming path.
+			for chunk := tt.input; len(chunk) > 0; {
+				n := min(len(chunk), 77)
+				if _, err := zw.Write(chunk[:n]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				chunk = chunk[n:]
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got := decompress(t, buf.Bytes()); !bytes.Equal(got, tt.input) {
+				t.Errorf("round trip of %d bytes mismatch", len(tt.input))
+			}
+		})
+	}
+}
+
+// TestAdaptiveWriterStreams checks that output appears before Close, which
+// the static single table mode cannot do without a Frequency.
+func TestAdaptiveWriterStreams(t *testing.T) {
+	var buf bytes.Buffer
+	zw := NewWriter(&buf, Options{Adaptive: true})
+	zw.Write(blockInput(256 * 1024))
+	if buf.Len() == 0 {
+		t.Error("nothing was written before Close")
+	}
+	zw.Close()
+}
+
+func TestAdaptiveInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{Adaptive: true, BlockSize: 1024},
+		{Adaptive: true, Frequency: map[byte]int{'a': 1}},
+	} {
+		zw := NewWriter(io.Discard, opts)
+		if err := zw.Close(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Close() with %+v error = %v, want %v", opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestAdaptiveReaderErrors(t *testing.T) {
+	compressed := compress(t, []byte(strings.Repeat("adaptive ", 200)), Options{Adaptive: true})
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{name: "Truncated", data: compressed[:len(compressed)/2], expected: io.ErrUnexpectedEOF},
+		{name: "Missing trailer", data: compressed[:len(compressed)-checksumSize], expected: io.ErrUnexpectedEOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zr, err := NewReader(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			if _, err := io.ReadAll(zr); !errors.Is(err, tt.expected) {
+				t.Errorf("ReadAll() error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package compactor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"time"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// Archive layout, all fixed size integers big-endian:
+//
+//	magic           4 bytes "CPTA"
+//	version         1 byte
+//	members         one complete compactor stream per regular file, in
+//	                index order
+//	index           uvarint entry count, then per entry:
+//	                  path (uvarint length and slash separated bytes)
+//	                  mode (uvarint, fs.FileMode bits)
+//	                  mtime (varint, nanoseconds since the Unix epoch)
+//	                  size (uvarint, original length)
+//	                  offset (uvarint, start of the member)
+//	                  compressed size (uvarint, 0 for directories)
+//	footer          index offset (8 bytes), CRC-32 (IEEE) of the index
+//	                (4 bytes), magic "CPTA"
+//
+// The index is written last so the archive can be streamed out, and is found
+// through the footer, so it can be listed without reading any member.
+const (
+	ArchiveMagic   = "CPTA"
+	ArchiveVersion = 1
+
+	archiveHeaderSize = len(ArchiveMagic) + 1
+	archiveFooterSize = 8 + 4 + len(ArchiveMagic)
+)
+
+var (
+	ErrNotArchive     = errors.New("compactor: not a compactor archive")
+	ErrCorruptArchive = errors.New("compactor: corrupt archive index")
+)
+
+// ArchiveEntry describes a file or directory in an archive.
+type ArchiveEntry struct {
+	// Path is relative to the archived directory and slash separated.
+	Path    string
+	Mode    fs.FileMode
+	ModTime time.Time
+	// Size is the original length of a file.
+	Size int64
+	// CompressedSize is the length of the member holding the file.
+	CompressedSize int64
+
+	offset int64
+}
+
+func (e *ArchiveEntry) IsDir() bool {
+	return e.Mode.IsDir()
+}
+
+// ArchiveWriter writes a multi-file archive. Every file is compressed as an
+// independent compactor stream with the same Options. Close writes the index.
+type ArchiveWriter struct {
+	w       io.Writer
+	opts    Options
+	offset  int64
+	entries []ArchiveEntry
+	paths   map[string]bool
+
+	started bool
+	closed  bool
+	err     error
+}
+
+func NewArchiveWriter(w io.Writer, opts Options) *ArchiveWriter {
+	return &ArchiveWriter{
+		w:     w,
+		opts:  opts,
+		paths: make(map[string]bool),
+	}
+}
+
+// countingWriter counts what is written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func (aw *ArchiveWriter) start() error {
+	if aw.started {
+		return nil
+	}
+	aw.started = true
+	if aw.opts.Frequency != nil {
+		return fmt.Errorf("%w: Frequency cannot be used for archives", ErrInvalidOptions)
+	}
+	n, err := aw.w.Write(append([]byte(ArchiveMagic), ArchiveVersion))
+	aw.offset += int64(n)
+	return err
+}
+
+func (aw *ArchiveWriter) add(entry ArchiveEntry) error {
+	if aw.closed {
+		return ErrClosed
+	}
+	if aw.err != nil {
+		return aw.err
+	}
+	if !fs.ValidPath(entry.Path) || entry.Path == "." {
+		return fmt.Errorf("compactor: invalid archive path %q", entry.Path)
+	}
+	if aw.paths[entry.Path] {
+		return fmt.Errorf("compactor: duplicate archive path %q", entry.Path)
+	}
+	if aw.err = aw.start(); aw.err != nil {
+		return aw.err
+	}
+	aw.paths[entry.Path] = true
+	return nil
+}
+
+// AddDir adds a directory entry. Directories are created on extraction even
+// when they hold no files.
+func (aw *ArchiveWriter) AddDir(name string, mode fs.FileMode, modTime time.Time) error {
+	entry := ArchiveEntry{Path: name, Mode: mode.Perm() | fs.ModeDir, ModTime: modTime}
+	if err := aw.add(entry); err != nil {
+		return err
+	}
+	entry.offset = aw.offset
+	aw.entries = append(aw.entries, entry)
+	return nil
+}
+
+// AddFile compresses everything read from r as the file name. In the
+// default mode the file is buffered in memory until it is complete, see
+// AddFileWithFrequency to stream it.
+func (aw *ArchiveWriter) AddFile(name string, mode fs.FileMode, modTime time.Time, r io.Reader) error {
+	return aw.AddFileWithFrequency(name, mode, modTime, r, nil)
+}
+
+// AddFileWithFrequency is AddFile with the byte frequency of the file known
+// up front, which is used as Options.Frequency for this file alone so the
+// encoded data is streamed. A nil frequency is the same as AddFile.
+func (aw *ArchiveWriter) AddFileWithFrequency(name string, mode fs.FileMode, modTime time.Time, r io.Reader, frequency compressutils.Frequency) error {
+	entry := ArchiveEntry{Path: name, Mode: mode.Perm(), ModTime: modTime}
+	if err := aw.add(entry); err != nil {
+		return err
+	}
+	entry.offset = aw.offset
+
+	counter := &countingWriter{w: aw.w}
+	opts := aw.opts
+	opts.Frequency = frequency
+	zw := NewWriter(counter, opts)
+	if entry.Size, aw.err = io.Copy(zw, r); aw.err != nil {
+		return aw.err
+	}
+	if aw.err = zw.Close(); aw.err != nil {
+		return aw.err
+	}
+	entry.CompressedSize = counter.n
+	aw.offset += counter.n
+	aw.entries = append(aw.entries, entry)
+	return nil
+}
+
+// Close writes the index and the footer. It does not close the underlying
+// writer.
+func (aw *ArchiveWriter) Close() error {
+	if aw.closed {
+		return aw.err
+	}
+	aw.closed = true
+	if aw.err != nil {
+		return aw.err
+	}
+	if aw.err = aw.start(); aw.err != nil {
+		return aw.err
+	}
+
+	index := binary.AppendUvarint(nil, uint64(len(aw.entries)))
+	for _, e := range aw.entries {
+		index = binary.AppendUvarint(index, uint64(len(e.Path)))
+		index = append(index, e.Path...)
+		index = binary.AppendUvarint(index, uint64(e.Mode))
+		index = binary.AppendVarint(index, e.ModTime.UnixNano())
+		index = binary.AppendUvarint(index, uint64(e.Size))
+		index = binary.AppendUvarint(index, uint64(e.offset))
+		index = binary.AppendUvarint(index, uint64(e.CompressedSize))
+	}
+	footer := binary.BigEndian.AppendUint64(nil, uint64(aw.offset))
+	footer = binary.BigEndian.AppendUint32(footer, crc32.ChecksumIEEE(index))
+	footer = append(footer, ArchiveMagic...)
+
+	if _, aw.err = aw.w.Write(append(index, footer...)); aw.err != nil {
+		return aw.err
+	}
+	return nil
+}
+
+// ArchiveReader gives access to the files of an archive.
+type ArchiveReader struct {
+	r io.ReaderAt
+	// Entries lists the files and directories in the order they were added,
+	// which puts every directory before its contents when the archive was
+	// written by walking a directory tree.
+	Entries []ArchiveEntry
+	// Tables are passed to NewReaderWithTables for every member, they have
+	// to be set before Open when the archive was written with Options.Table.
+	Tables []*Table
+}
+
+// IsArchive reports whether data starts like an archive.
+func IsArchive(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ArchiveMagic))
+}
+
+// OpenArchive reads the index of the archive of size bytes in r. Only the
+// header, index and footer are read.
+func OpenArchive(r io.ReaderAt, size int64) (*ArchiveReader, error) {
+	if size < int64(archiveHeaderSize+archiveFooterSize) {
+		return nil, ErrNotArchive
+	}
+	header := make([]byte, archiveHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if !IsArchive(header) {
+		return nil, ErrNotArchive
+	}
+	if header[len(ArchiveMagic)] != ArchiveVersion {
+		return nil, fmt.Errorf("%w %d (this build reads archive version %d)", ErrUnsupportedVersion, header[len(ArchiveMagic)], ArchiveVersion)
+	}
+
+	footer := make([]byte, archiveFooterSize)
+	if _, err := r.ReadAt(footer, size-int64(archiveFooterSize)); err != nil {
+		return nil, err
+	}
+	if string(footer[12:]) != ArchiveMagic {
+		return nil, fmt.Errorf("%w: missing footer, the archive may be truncated", ErrCorruptArchive)
+	}
+	indexOffset := binary.BigEndian.Uint64(footer)
+	indexEnd := uint64(size) - uint64(archiveFooterSize)
+	if indexOffset < uint64(archiveHeaderSize) || indexOffset >= indexEnd {
+		return nil, fmt.Errorf("%w: index offset %d out of range", ErrCorruptArchive, indexOffset)
+	}
+
+	index := make([]byte, indexEnd-indexOffset)
+	if _, err := r.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(index) != binary.BigEndian.Uint32(footer[8:]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptArchive)
+	}
+
+	entries, err := readArchiveIndex(bytes.NewReader(index), int64(indexOffset))
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveReader{r: r, Entries: entries}, nil
+}
+
+// readArchiveIndex parses the index and checks that every entry has a local
+// path and a member within membersEnd.
+func readArchiveIndex(r *bytes.Reader, membersEnd int64) ([]ArchiveEntry, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, archiveIndexError(err)
+	}
+	// Every entry takes at least six bytes.
+	if count > uint64(r.Len())/6 {
+		return nil, fmt.Errorf("%w: %d entries do not fit the index", ErrCorruptArchive, count)
+	}
+
+	entries := make([]ArchiveEntry, count)
+	seen := make(map[string]bool, count)
+	for i := range entries {
+		var fields [4]uint64
+		pathLength, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, archiveIndexError(err)
+		}
+		if pathLength > uint64(r.Len()) {
+			return nil, fmt.Errorf("%w: path length %d out of range", ErrCorruptArchive, pathLength)
+		}
+		name := make([]byte, pathLength)
+		io.ReadFull(r, name)
+
+		fields[0], err = binary.ReadUvarint(r)
+		if err != nil {
+			return nil, archiveIndexError(err)
+		}
+		mtime, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, archiveIndexError(err)
+		}
+		for j := 1; j < 4; j++ {
+			if fields[j], err = binary.ReadUvarint(r); err != nil {
+				return nil, archiveIndexError(err)
+			}
+		}
+
+		e := ArchiveEntry{
+			Path:           string(name),
+			Mode:           fs.FileMode(fields[0]),
+			ModTime:        time.Unix(0, mtime),
+			Size:           int64(fields[1]),
+			offset:         int64(fields[2]),
+			CompressedSize: int64(fields[3]),
+		}
+		// Only local paths are accepted, so extracting cannot write outside
+		// the target directory.
+		if !fs.ValidPath(e.Path) || e.Path == "." || seen[e.Path] {
+			return nil, fmt.Errorf("%w: invalid path %q", ErrCorruptArchive, e.Path)
+		}
+		if e.Mode&^(fs.ModeDir|fs.ModePerm) != 0 || e.Size < 0 || e.offset < int64(archiveHeaderSize) ||
+			e.CompressedSize < 0 || e.CompressedSize > membersEnd-e.offset {
+			return nil, fmt.Errorf("%w: invalid entry for %q", ErrCorruptArchive, e.Path)
+		}
+		seen[e.Path] = true
+		entries[i] = e
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%w: %d bytes after the last entry", ErrCorruptArchive, r.Len())
+	}
+	return entries, nil
+}
+
+func archiveIndexError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end of index", ErrCorruptArchive)
+	}
+	return err
+}
+
+// Open returns a reader for the contents of the file entry. It fails with
+// ErrCorruptData at the end if the member does not hold Size bytes.
+func (ar *ArchiveReader) Open(entry *ArchiveEntry) (io.Reader, error) {
+	if entry.IsDir() {
+		return nil, fmt.Errorf("compactor: %s is a directory", entry.Path)
+	}
+	zr, err := NewReaderWithTables(io.NewSectionReader(ar.r, entry.offset, entry.CompressedSize), ar.Tables...)
+	if err != nil {
+		return nil, err
+	}
+	return &sizeCheckReader{r: zr, remaining: entry.Size}, nil
+}
+
+// sizeCheckReader fails unless r yields exactly remaining bytes.
+type sizeCheckReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (sr *sizeCheckReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+	sr.remaining -= int64(n)
+	if sr.remaining < 0 || (err == io.EOF && sr.remaining != 0) {
+		return n, fmt.Errorf("%w: file size does not match the archive index", ErrCorruptData)
+	}
+	return n, err
+}
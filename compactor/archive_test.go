@@ -0,0 +1,244 @@
+package compactor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+type archiveFile struct {
+	path    string
+	dir     bool
+	content string
+}
+
+var archiveFiles = []archiveFile{
+	{path: "logs", dir: true},
+	{path: "logs/app.log", content: strings.Repeat("GET /index.html 200\n", 300)},
+	{path: "logs/empty.log"},
+	{path: "logs/nested", dir: true},
+	{path: "logs/nested/run.bin", content: strings.Repeat("z", 5000)},
+	{path: "README", content: "archived by compactor"},
+}
+
+func writeArchive(t *testing.T, files []archiveFile, opts Options) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	aw := NewArchiveWriter(&buf, opts)
+	modTime := time.Unix(1700000000, 123456789)
+	for _, f := range files {
+		var err error
+		if f.dir {
+			err = aw.AddDir(f.path, 0o755, modTime)
+		} else {
+			err = aw.AddFile(f.path, 0o640, modTime, strings.NewReader(f.content))
+		}
+		if err != nil {
+			t.Fatalf("Add(%q) error = %v", f.path, err)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []archiveFile
+		opts  Options
+	}{
+		{name: "Empty archive", files: nil},
+		{name: "Default options", files: archiveFiles},
+		{name: "Blocks", files: archiveFiles, opts: Options{BlockSize: 1024}},
+		{name: "LZ", files: archiveFiles, opts: Options{LZ: true}},
+		{name: "Adaptive", files: archiveFiles, opts: Options{Adaptive: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := writeArchive(t, tt.files, tt.opts)
+			if !IsArchive(data) {
+				t.Errorf("IsArchive() = false, want true")
+			}
+
+			ar, err := OpenArchive(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("OpenArchive() error = %v", err)
+			}
+			if len(ar.Entries) != len(tt.files) {
+				t.Fatalf("len(Entries) = %d, want %d", len(ar.Entries), len(tt.files))
+			}
+
+			for i, f := range tt.files {
+				entry := &ar.Entries[i]
+				if entry.Path != f.path || entry.IsDir() != f.dir {
+					t.Errorf("Entries[%d] = %q (dir %v), want %q (dir %v)", i, entry.Path, entry.IsDir(), f.path, f.dir)
+				}
+				if want := time.Unix(1700000000, 123456789); !entry.ModTime.Equal(want) {
+					t.Errorf("Entries[%d].ModTime = %v, want %v", i, entry.ModTime, want)
+				}
+				if f.dir {
+					if entry.Mode != fs.ModeDir|0o755 {
+						t.Errorf("Entries[%d].Mode = %v, want %v", i, entry.Mode, fs.ModeDir|0o755)
+					}
+					continue
+				}
+				if entry.Mode != 0o640 || entry.Size != int64(len(f.content)) {
+					t.Errorf("Entries[%d] mode %v size %d, want %v size %d", i, entry.Mode, entry.Size, fs.FileMode(0o640), len(f.content))
+				}
+
+				r, err := ar.Open(entry)
+				if err != nil {
+					t.Fatalf("Open(%q) error = %v", f.path, err)
+				}
+				got, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatalf("ReadAll(%q) error = %v", f.path, err)
+				}
+				if string(got) != f.content {
+					t.Errorf("Open(%q) content mismatch: got %d bytes, want %d", f.path, len(got), len(f.content))
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveAddFileWithFrequency(t *testing.T) {
+	var buffered, streamed bytes.Buffer
+	modTime := time.Unix(1700000000, 0)
+	bw := NewArchiveWriter(&buffered, Options{})
+	sw := NewArchiveWriter(&streamed, Options{})
+	for _, f := range archiveFiles[1:2] {
+		if err := bw.AddFile(f.path, 0o640, modTime, strings.NewReader(f.content)); err != nil {
+			t.Fatalf("AddFile() error = %v", err)
+		}
+		frequency := compressutils.GetFrequencyForBytes([]byte(f.content))
+		if err := sw.AddFileWithFrequency(f.path, 0o640, modTime, strings.NewReader(f.content), frequency); err != nil {
+			t.Fatalf("AddFileWithFrequency() error = %v", err)
+		}
+	}
+	bw.Close()
+	sw.Close()
+	if !bytes.Equal(streamed.Bytes(), buffered.Bytes()) {
+		t.Error("AddFileWithFrequency() writes different output than AddFile()")
+	}
+
+	aw := NewArchiveWriter(io.Discard, Options{})
+	err := aw.AddFileWithFrequency("a", 0o644, time.Time{}, strings.NewReader("xy"), compressutils.Frequency{'x': 2})
+	if !errors.Is(err, ErrFrequencyMatch) {
+		t.Errorf("AddFileWithFrequency() with a wrong frequency error = %v, want %v", err, ErrFrequencyMatch)
+	}
+}
+
+func TestArchiveWriterInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		add  func(aw *ArchiveWriter) error
+	}{
+		{name: "Absolute path", add: func(aw *ArchiveWriter) error {
+			return aw.AddDir("/etc", 0o755, time.Time{})
+		}},
+		{name: "Parent path", add: func(aw *ArchiveWriter) error {
+			return aw.AddFile("../secret", 0o644, time.Time{}, strings.NewReader("x"))
+		}},
+		{name: "Duplicate path", add: func(aw *ArchiveWriter) error {
+			aw.AddFile("a", 0o644, time.Time{}, strings.NewReader("x"))
+			return aw.AddFile("a", 0o644, time.Time{}, strings.NewReader("y"))
+		}},
+		{name: "Frequency", add: func(aw *ArchiveWriter) error {
+			aw.opts.Frequency = compressutils.Frequency{'x': 1}
+			return aw.AddFile("a", 0o644, time.Time{}, strings.NewReader("x"))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.add(NewArchiveWriter(io.Discard, Options{})); err == nil {
+				t.Errorf("Add() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestOpenArchiveInvalid(t *testing.T) {
+	valid := writeArchive(t, archiveFiles, Options{})
+	footer := len(valid) - archiveFooterSize
+
+	// evilIndex builds an archive whose only entry has the given path.
+	evilIndex := func(name string) []byte {
+		index := binary.AppendUvarint(nil, 1)
+		index = binary.AppendUvarint(index, uint64(len(name)))
+		index = append(index, name...)
+		index = append(index, 0, 0, 0, byte(archiveHeaderSize), 0)
+		data := append([]byte(ArchiveMagic), ArchiveVersion)
+		data = append(data, index...)
+		data = binary.BigEndian.AppendUint64(data, uint64(archiveHeaderSize))
+		data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(index))
+		return append(data, ArchiveMagic...)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "Too short", data: []byte("CPTA"), wantErr: ErrNotArchive},
+		{name: "Compactor stream", data: compress(t, []byte(strings.Repeat("not an archive ", 10)), Options{}), wantErr: ErrNotArchive},
+		{name: "Unknown version", data: func() []byte {
+			data := bytes.Clone(valid)
+			data[len(ArchiveMagic)] = ArchiveVersion + 1
+			return data
+		}(), wantErr: ErrUnsupportedVersion},
+		{name: "Truncated", data: valid[:len(valid)-1], wantErr: ErrCorruptArchive},
+		{name: "Index corrupted", data: func() []byte {
+			data := bytes.Clone(valid)
+			data[footer-1] ^= 0xff
+			return data
+		}(), wantErr: ErrCorruptArchive},
+		{name: "Index offset out of range", data: func() []byte {
+			data := bytes.Clone(valid)
+			binary.BigEndian.PutUint64(data[footer:], uint64(len(valid)))
+			return data
+		}(), wantErr: ErrCorruptArchive},
+		{name: "Parent path", data: evilIndex("../escape"), wantErr: ErrCorruptArchive},
+		{name: "Absolute path", data: evilIndex("/etc/passwd"), wantErr: ErrCorruptArchive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := OpenArchive(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("OpenArchive() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestArchiveOpenDetectsSizeMismatch(t *testing.T) {
+	data := writeArchive(t, archiveFiles, Options{})
+	ar, err := OpenArchive(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("OpenArchive() error = %v", err)
+	}
+
+	entry := ar.Entries[1]
+	entry.Size--
+	r, err := ar.Open(&entry)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, ErrCorruptData) {
+		t.Errorf("ReadAll() error = %v, want %v", err, ErrCorruptData)
+	}
+}
@@ -0,0 +1,423 @@
+package compactor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"runtime"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+const (
+	// DefaultBlockSize is a good block size for Options.BlockSize: large
+	// enough for the per block code table not to matter, small enough to
+	// keep every core busy on inputs of a few tens of megabytes.
+	DefaultBlockSize = 4 << 20
+	// MaxBlockSize bounds Options.BlockSize and the block size a Reader
+	// accepts, which is how much it allocates for a block.
+	MaxBlockSize = 64 << 20
+)
+
+type blockResult struct {
+	data []byte
+	err  error
+}
+
+// blockWriter splits the input into blocks and compresses up to concurrency
+// of them at a time. Finished blocks are written in input order.
+type blockWriter struct {
+	w             io.Writer
+	blockSize     int
+	maxCodeLength int
+	lz            bool
+	indexed       bool
+	concurrency   int
+
+	block   []byte
+	pending []chan blockResult
+	// index has an entry for every block handed out, written counts the
+	// ones whose frame length is known.
+	index   []blockIndexEntry
+	written int
+	started bool
+}
+
+func newBlockWriter(w io.Writer, blockSize, concurrency, maxCodeLength int, lz, indexed bool) *blockWriter {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	return &blockWriter{
+		w:             w,
+		blockSize:     blockSize,
+		maxCodeLength: maxCodeLength,
+		lz:            lz,
+		indexed:       indexed,
+		concurrency:   concurrency,
+	}
+}
+
+func (bw *blockWriter) start() error {
+	bw.started = true
+	flags := flagChecksum | flagBlocks
+	if bw.lz {
+		flags |= flagLZ
+	}
+	if bw.indexed {
+		flags |= flagIndex
+	}
+	return writeHeader(bw.w, &header{
+		version:   FormatVersion,
+		flags:     flags,
+		blockSize: uint64(bw.blockSize),
+	})
+}
+
+func (bw *blockWriter) write(p []byte) error {
+	if !bw.started {
+		if err := bw.start(); err != nil {
+			return err
+		}
+	}
+
+	for len(p) > 0 {
+		if bw.block == nil {
+			bw.block = make([]byte, 0, bw.blockSize)
+		}
+		n := min(len(p), bw.blockSize-len(bw.block))
+		bw.block = append(bw.block, p[:n]...)
+		p = p[n:]
+
+		if len(bw.block) == bw.blockSize {
+			if err := bw.flushBlock(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flushBlock hands the current block to a new goroutine, after waiting for
+// the oldest one when concurrency blocks are already in flight.
+func (bw *blockWriter) flushBlock() error {
+	if len(bw.pending) == bw.concurrency {
+		if err := bw.writeOldest(); err != nil {
+			return err
+		}
+	}
+
+	block := bw.block
+	bw.block = nil
+	if bw.indexed {
+		bw.index = append(bw.index, blockIndexEntry{
+			length:   uint64(len(block)),
+			checksum: crc32.ChecksumIEEE(block),
+		})
+	}
+	// The channel is buffered so the goroutine finishes even when the
+	// writer gives up on an error and never receives its result.
+	compress := compressBlock
+	if bw.lz {
+		compress = compressLZBlock
+	}
+	result := make(chan blockResult, 1)
+	go func() {
+		data, err := compress(block, bw.maxCodeLength)
+		result <- blockResult{data: data, err: err}
+	}()
+	bw.pending = append(bw.pending, result)
+	return nil
+}
+
+func (bw *blockWriter) writeOldest() error {
+	result := <-bw.pending[0]
+	bw.pending = bw.pending[1:]
+	if result.err != nil {
+		return result.err
+	}
+	if bw.indexed {
+		bw.index[bw.written].frameLength = uint64(len(result.data))
+		bw.written++
+	}
+	_, err := bw.w.Write(result.data)
+	return err
+}
+
+func (bw *blockWriter) close() error {
+	if !bw.started {
+		if err := bw.start(); err != nil {
+			return err
+		}
+	}
+	if len(bw.block) > 0 {
+		if err := bw.flushBlock(); err != nil {
+			return err
+		}
+	}
+	for len(bw.pending) > 0 {
+		if err := bw.writeOldest(); err != nil {
+			return err
+		}
+	}
+
+	end := binary.AppendUvarint(nil, 0)
+	if bw.indexed {
+		end = appendBlockIndex(end, bw.index)
+	}
+	_, err := bw.w.Write(end)
+	return err
+}
+
+// compressBlock encodes one block with its own code table into a complete
+// block frame, which is a single stream.
+func compressBlock(data []byte, maxCodeLength int) ([]byte, error) {
+	return appendStream(nil, data, maxCodeLength)
+}
+
+// appendStream appends data as a Huffman coded stream with its own code
+// table: the length of data, then unless it is empty the code table, the
+// payload length and the payload. Codes are at most maxCodeLength bits long.
+// A run of one byte value has a table of that symbol and no payload.
+func appendStream(frame, data []byte, maxCodeLength int) ([]byte, error) {
+	frame = binary.AppendUvarint(frame, uint64(len(data)))
+	if len(data) == 0 {
+		return frame, nil
+	}
+
+	huffmanCodes, err := generateHuffmanCodes(compressutils.GetFrequencyForBytes(data), maxCodeLength)
+	if err != nil {
+		return nil, err
+	}
+	codeLengths := compressutils.GetCodeLengths(huffmanCodes)
+	if _, ok := runSymbol(codeLengths); ok {
+		if frame, err = appendCodeLengths(frame, codeLengths); err != nil {
+			return nil, err
+		}
+		return binary.AppendUvarint(frame, 0), nil
+	}
+
+	codeTable, err := compressutils.NewCodeTable(huffmanCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var payload bytes.Buffer
+	bitWriter := compressutils.NewBitWriter(&payload)
+	if err := codeTable.Encode(bitWriter, data); err != nil {
+		return nil, fmt.Errorf("compactor: %w", err)
+	}
+	if err := bitWriter.Flush(); err != nil {
+		return nil, err
+	}
+
+	if frame, err = appendCodeLengths(frame, codeLengths); err != nil {
+		return nil, err
+	}
+	frame = binary.AppendUvarint(frame, uint64(payload.Len()))
+	return append(frame, payload.Bytes()...), nil
+}
+
+// stream is a stream read by readStream, ready to be decoded.
+type stream struct {
+	length      uint64
+	codeLengths compressutils.CodeLengthTable
+	payload     []byte
+}
+
+// readStream reads the rest of a stream whose length the caller has already
+// read.
+func readStream(r *bufio.Reader, length uint64) (*stream, error) {
+	s := &stream{length: length}
+	if length == 0 {
+		return s, nil
+	}
+
+	var err error
+	if s.codeLengths, err = readCodeLengths(r); err != nil {
+		return nil, blockError(err)
+	}
+	if len(s.codeLengths) == 0 {
+		return nil, fmt.Errorf("%w: no code table for a stream of %d bytes", ErrCorruptData, length)
+	}
+
+	payloadLength, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, blockError(err)
+	}
+	_, run := runSymbol(s.codeLengths)
+	if (payloadLength == 0 && !run) || payloadLength > (length*compressutils.MaxCodeLength+7)/8 {
+		return nil, fmt.Errorf("%w: invalid stream payload length %d", ErrCorruptData, payloadLength)
+	}
+
+	s.payload = make([]byte, payloadLength)
+	if _, err := io.ReadFull(r, s.payload); err != nil {
+		return nil, blockError(err)
+	}
+	return s, nil
+}
+
+func (s *stream) decode() ([]byte, error) {
+	data := make([]byte, s.length)
+	if s.length == 0 {
+		return data, nil
+	}
+	if runByte, ok := runSymbol(s.codeLengths); ok && len(s.payload) == 0 {
+		for i := range data {
+			data[i] = runByte
+		}
+		return data, nil
+	}
+
+	decoder, err := compressutils.NewDecoder(s.codeLengths)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptData, err)
+	}
+	if _, err := decoder.DecodeTo(compressutils.NewBitReader(bytes.NewReader(s.payload)), data); err != nil {
+		// The payload is complete, running out of bits means it does not
+		// match the code table.
+		return nil, ErrCorruptData
+	}
+	return data, nil
+}
+
+// blockReader reads block frames in order and decodes up to concurrency of
+// them at a time.
+type blockReader struct {
+	r           *bufio.Reader
+	blockSize   uint64
+	lz          bool
+	indexed     bool
+	concurrency int
+
+	pending []chan blockResult
+	current []byte
+	blocks  int
+	done    bool
+}
+
+func newBlockReader(r *bufio.Reader, h *header) *blockReader {
+	return &blockReader{
+		r:           r,
+		blockSize:   h.blockSize,
+		lz:          h.flags&flagLZ != 0,
+		indexed:     h.flags&flagIndex != 0,
+		concurrency: runtime.GOMAXPROCS(0),
+	}
+}
+
+func (br *blockReader) Read(p []byte) (int, error) {
+	for len(br.current) == 0 {
+		if err := br.fill(); err != nil {
+			return 0, err
+		}
+		if len(br.pending) == 0 {
+			return 0, io.EOF
+		}
+
+		result := <-br.pending[0]
+		br.pending = br.pending[1:]
+		if result.err != nil {
+			return 0, result.err
+		}
+		br.current = result.data
+	}
+
+	n := copy(p, br.current)
+	br.current = br.current[n:]
+	return n, nil
+}
+
+// fill reads block frames until concurrency blocks are being decoded or the
+// end marker is reached.
+func (br *blockReader) fill() error {
+	for !br.done && len(br.pending) < br.concurrency {
+		_, decode, err := readBlockFrame(br.r, br.blockSize, br.lz)
+		if err != nil {
+			return err
+		}
+		if decode == nil {
+			br.done = true
+			if br.indexed {
+				return br.skipIndex()
+			}
+			break
+		}
+		br.blocks++
+
+		result := make(chan blockResult, 1)
+		go func() {
+			data, err := decode()
+			result <- blockResult{data: data, err: err}
+		}()
+		br.pending = append(br.pending, result)
+	}
+	return nil
+}
+
+// skipIndex reads the block index that follows the end marker. Sequential
+// reading has no use for it beyond checking that it covers every block.
+func (br *blockReader) skipIndex() error {
+	index, err := readBlockIndex(br.r)
+	if err != nil {
+		return err
+	}
+	if len(index) != br.blocks {
+		return fmt.Errorf("%w: block index lists %d blocks, the file has %d", ErrCorruptData, len(index), br.blocks)
+	}
+	return nil
+}
+
+// readBlockFrame reads the next block frame and returns its original length
+// and the function that decodes it, or a nil function at the end marker.
+func readBlockFrame(r *bufio.Reader, blockSize uint64, lz bool) (uint64, func() ([]byte, error), error) {
+	originalLength, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, nil, blockError(err)
+	}
+	if originalLength == 0 {
+		return 0, nil, nil
+	}
+	if originalLength > blockSize {
+		return 0, nil, fmt.Errorf("%w: block of %d bytes exceeds the block size %d", ErrCorruptData, originalLength, blockSize)
+	}
+
+	if !lz {
+		s, err := readStream(r, originalLength)
+		if err != nil {
+			return 0, nil, err
+		}
+		return originalLength, s.decode, nil
+	}
+
+	var streams [lzStreamCount]*stream
+	for i := range streams {
+		// No stream of a block can be longer than the block itself.
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, nil, blockError(err)
+		}
+		if length > originalLength {
+			return 0, nil, fmt.Errorf("%w: LZ stream of %d bytes in a block of %d", ErrCorruptData, length, originalLength)
+		}
+		if streams[i], err = readStream(r, length); err != nil {
+			return 0, nil, err
+		}
+	}
+	return originalLength, func() ([]byte, error) {
+		return decompressLZBlock(int(originalLength), streams)
+	}, nil
+}
+
+func (br *blockReader) trailer() io.Reader {
+	return br.r
+}
+
+func blockError(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
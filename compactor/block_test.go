@@ -0,0 +1,219 @@
+package compactor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func blockInput(size int) []byte {
+	input := make([]byte, size)
+	random := rand.New(rand.NewSource(int64(size)))
+	for i := range input {
+		// A skewed alphabet of 32 bytes, so every block has several symbols.
+		input[i] = 'a' + byte(random.Intn(8)*random.Intn(5))
+	}
+	return input
+}
+
+func TestBlockRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		blockSize   int
+		concurrency int
+	}{
+		{name: "Smaller than a block", size: 100, blockSize: 1024},
+		{name: "Exactly one block", size: 1024, blockSize: 1024},
+		{name: "Uneven last block", size: 10*1024 + 17, blockSize: 1024},
+		{name: "Serial", size: 10 * 1024, blockSize: 512, concurrency: 1},
+		{name: "More blocks than workers", size: 64 * 1024, blockSize: 256, concurrency: 3},
+		{name: "Empty", size: 0, blockSize: 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := blockInput(tt.size)
+
+			var buf bytes.Buffer
+			zw := NewWriter(&buf, Options{BlockSize: tt.blockSize, Concurrency: tt.concurrency})
+			// Write in pieces that do not line up with the blocks.
+			for chunk := input; len(chunk) > 0; {
+				n := min(len(chunk), 300)
+				if _, err := zw.Write(chunk[:n]); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				chunk = chunk[n:]
+			}
+			if err := zw.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if got := decompress(t, buf.Bytes()); !bytes.Equal(got, input) {
+				t.Errorf("round trip of %d bytes mismatch", tt.size)
+			}
+		})
+	}
+}
+
+func TestBlockWriterIsReproducible(t *testing.T) {
+	input := blockInput(32 * 1024)
+	serial := compress(t, input, Options{BlockSize: 1024, Concurrency: 1})
+	parallel := compress(t, input, Options{BlockSize: 1024, Concurrency: 8})
+	if !bytes.Equal(serial, parallel) {
+		t.Error("output depends on Options.Concurrency")
+	}
+}
+
+func TestBlockWriterInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Negative block size", opts: Options{BlockSize: -1}},
+		{name: "Block size too large", opts: Options{BlockSize: MaxBlockSize + 1}},
+		{name: "Frequency with blocks", opts: Options{BlockSize: 1024, Frequency: map[byte]int{'a': 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zw := NewWriter(io.Discard, tt.opts)
+			if _, err := zw.Write([]byte("a")); !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("Write() error = %v, want %v", err, ErrInvalidOptions)
+			}
+		})
+	}
+}
+
+func TestBlockReaderErrors(t *testing.T) {
+	compressed := compress(t, blockInput(8*1024), Options{BlockSize: 1024})
+	// The first block frame starts after magic, version, flags and the
+	// two byte uvarint block size.
+	firstBlock := len(Magic) + 2 + 2
+
+	tests := []struct {
+		name     string
+		data     func() []byte
+		expected error
+	}{
+		{
+			name:     "Truncated",
+			data:     func() []byte { return compressed[:len(compressed)/2] },
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			name:     "Missing end marker",
+			data:     func() []byte { return compressed[:len(compressed)-checksumSize-1] },
+			expected: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "Block larger than the block size",
+			data: func() []byte {
+				data := bytes.Clone(compressed[:firstBlock])
+				return binary.AppendUvarint(data, 1025)
+			},
+			expected: ErrCorruptData,
+		},
+		{
+			name: "Corrupt checksum",
+			data: func() []byte {
+				data := bytes.Clone(compressed)
+				data[len(data)-1] ^= 0xff
+				return data
+			},
+			expected: ErrChecksum,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zr, err := NewReader(bytes.NewReader(tt.data()))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			if _, err := io.ReadAll(zr); !errors.Is(err, tt.expected) {
+				t.Errorf("ReadAll() error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBlockReaderDetectsPayloadCorruption(t *testing.T) {
+	compressed := compress(t, blockInput(8*1024), Options{BlockSize: 1024})
+	compressed[len(compressed)/2] ^= 0x10
+
+	zr, err := NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if _, err := io.ReadAll(zr); err == nil {
+		t.Error("ReadAll() expected an error for corrupted block")
+	}
+}
+
+func TestReadStream(t *testing.T) {
+	// uvarint length, code count, (symbol, length) pairs, payload length and
+	// payload.
+	tests := []struct {
+		name     string
+		frame    []byte
+		expected string
+		err      error
+	}{
+		{name: "Run", frame: []byte{5, 1, 'z', 1, 0}, expected: "zzzzz"},
+		// Runs used to be written with a payload of zero bits, which still
+		// decodes.
+		{name: "Run with payload", frame: []byte{5, 1, 'z', 1, 1, 0x00}, expected: "zzzzz"},
+		{name: "Two symbols", frame: []byte{3, 2, 'a', 1, 'b', 1, 1, 0x40}, expected: "aba"},
+		{name: "No payload for two symbols", frame: []byte{3, 2, 'a', 1, 'b', 1, 0}, err: ErrCorruptData},
+		{name: "No code table", frame: []byte{3, 0, 0}, err: ErrCorruptData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufioReader(tt.frame)
+			length, _ := binary.ReadUvarint(r)
+			s, err := readStream(r, length)
+			var data []byte
+			if err == nil {
+				data, err = s.decode()
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("readStream() error = %v, want %v", err, tt.err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("decode() = %q, want %q", data, tt.expected)
+			}
+		})
+	}
+}
+
+func BenchmarkBlockWriter(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		zw := NewWriter(io.Discard, Options{BlockSize: 256 << 10})
+		zw.Write(input)
+		zw.Close()
+	}
+}
+
+func BenchmarkBlockReader(b *testing.B) {
+	input := benchmarkInput()
+	var buf bytes.Buffer
+	zw := NewWriter(&buf, Options{BlockSize: 256 << 10})
+	zw.Write(input)
+	zw.Close()
+	b.SetBytes(int64(len(input)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		zr, _ := NewReader(bytes.NewReader(buf.Bytes()))
+		io.Copy(io.Discard, zr)
+	}
+}
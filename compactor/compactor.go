@@ -0,0 +1,10 @@
+// Package compactor implements streaming Huffman compression on top of
+// io.Writer and io.Reader, in the same spirit as compress/gzip.
+//
+//	zw := compactor.NewWriter(&buf, compactor.Options{})
+//	zw.Write(data)
+//	zw.Close()
+//
+//	zr, err := compactor.NewReader(&buf)
+//	io.Copy(os.Stdout, zr)
+package compactor
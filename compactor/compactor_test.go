@@ -0,0 +1,457 @@
+package compactor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+func compress(t *testing.T, data []byte, opts Options) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := NewWriter(&buf, opts)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decompress(t *testing.T, compressed []byte) []byte {
+	t.Helper()
+
+	zr, err := NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return data
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{name: "Two characters", input: "abababababbbbbba"},
+		{name: "Sentence", input: "the quick brown fox jumps over the lazy dog"},
+		{name: "Newlines and colons", input: "key: value\nother:thing\n\n::\n"},
+		{name: "Larger than a batch", input: strings.Repeat("compactor compresses files. ", 200)},
+		{name: "Multi-byte characters", input: strings.Repeat("naïve café — 😀 ", 150)},
+		{name: "Invalid UTF-8", input: "\xff\xfe\x00\x80abc\x00\xc3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := compress(t, []byte(tt.input), Options{})
+			got := decompress(t, compressed)
+			if string(got) != tt.input {
+				t.Errorf("round trip = %q, want %q", got, tt.input)
+			}
+		})
+	}
+}
+
+func TestRoundTripBinary(t *testing.T) {
+	input := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(input)
+	// Skew the distribution so the codes are not all eight bits long.
+	for i := range input[:len(input)/2] {
+		input[i] %= 16
+	}
+
+	if got := decompress(t, compress(t, input, Options{})); !bytes.Equal(got, input) {
+		t.Errorf("binary round trip mismatch")
+	}
+}
+
+func TestConcurrentRoundTrips(t *testing.T) {
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Every goroutine gets its own alphabet, so shared code tables
+			// would garble the output.
+			input := bytes.Repeat([]byte{byte('a' + i), byte('A' + i), ' ', byte('0' + i%10)}, 500+i*37)
+			var buf bytes.Buffer
+			zw := NewWriter(&buf, Options{})
+			zw.Write(input)
+			if err := zw.Close(); err != nil {
+				errs <- err
+				return
+			}
+
+			zr, err := NewReader(&buf)
+			if err != nil {
+				errs <- err
+				return
+			}
+			output, err := io.ReadAll(zr)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(output, input) {
+				errs <- fmt.Errorf("goroutine %d: round trip mismatch", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestSequentialReadersAreIndependent(t *testing.T) {
+	first := compress(t, []byte("aaaaabbbc"), Options{})
+	second := compress(t, []byte("xyzzy xyzzy"), Options{})
+
+	if got := decompress(t, first); string(got) != "aaaaabbbc" {
+		t.Errorf("first decompress = %q", got)
+	}
+	if got := decompress(t, second); string(got) != "xyzzy xyzzy" {
+		t.Errorf("second decompress = %q", got)
+	}
+}
+
+func TestWriterIsReproducible(t *testing.T) {
+	// Many characters share a frequency, so any dependence on map iteration
+	// order would show up as differing output.
+	input := []byte("abcdefghijklmnopqrstuvwxyz0123456789 abcdefghijklm")
+
+	first := compress(t, input, Options{})
+	for i := 0; i < 20; i++ {
+		if again := compress(t, input, Options{}); !bytes.Equal(again, first) {
+			t.Fatalf("compressing the same input twice gave different output")
+		}
+	}
+}
+
+func TestWriterWithFrequency(t *testing.T) {
+	input := []byte(strings.Repeat("streaming writes ", 300))
+	frequency, err := compressutils.GetFrequencyForReader(bytes.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := NewWriter(&buf, Options{Frequency: *frequency})
+	// Write in small pieces to exercise the carry over of partial bytes.
+	for i := 0; i < len(input); i += 7 {
+		end := min(i+7, len(input))
+		if _, err := zw.Write(input[i:end]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := decompress(t, buf.Bytes()); !bytes.Equal(got, input) {
+		t.Errorf("round trip mismatch: got %d bytes, want %d", len(got), len(input))
+	}
+}
+
+// fibonacciInput repeats byte i as often as the i-th Fibonacci number, which
+// makes the Huffman tree as deep as there are symbols.
+func fibonacciInput(symbols int) []byte {
+	var input []byte
+	a, b := 1, 1
+	for i := 0; i < symbols; i++ {
+		input = append(input, bytes.Repeat([]byte{byte(i)}, a)...)
+		a, b = b, a+b
+	}
+	return input
+}
+
+func TestMaxCodeLength(t *testing.T) {
+	input := fibonacciInput(25)
+
+	tests := []struct {
+		name  string
+		opts  Options
+		limit int
+	}{
+		{name: "Default", opts: Options{}, limit: compressutils.DefaultMaxCodeLength},
+		{name: "Eight bits", opts: Options{MaxCodeLength: 8}, limit: 8},
+		{name: "Unlimited", opts: Options{MaxCodeLength: compressutils.MaxCodeLength}, limit: 24},
+		{name: "Blocks", opts: Options{BlockSize: 64 * 1024, MaxCodeLength: 10}, limit: 10},
+		{name: "LZ", opts: Options{LZ: true, MaxCodeLength: 12}, limit: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := compress(t, input, tt.opts)
+			if got := decompress(t, compressed); !bytes.Equal(got, input) {
+				t.Fatalf("round trip mismatch: got %d bytes, want %d", len(got), len(input))
+			}
+
+			if tt.opts.BlockSize > 0 || tt.opts.LZ {
+				return
+			}
+			h, err := readHeader(bufioReader(compressed))
+			if err != nil {
+				t.Fatalf("readHeader() error = %v", err)
+			}
+			longest := 0
+			for _, length := range h.codeLengths {
+				longest = max(longest, length)
+			}
+			if longest != tt.limit {
+				t.Errorf("longest code = %d bits, want %d", longest, tt.limit)
+			}
+		})
+	}
+}
+
+func TestMaxCodeLengthInvalid(t *testing.T) {
+	for _, opts := range []Options{
+		{MaxCodeLength: -1},
+		{MaxCodeLength: compressutils.MaxCodeLength + 1},
+		{MaxCodeLength: 8, Adaptive: true},
+		// Five distinct bytes do not fit into two bit codes.
+		{MaxCodeLength: 2},
+		{MaxCodeLength: 2, BlockSize: 1024},
+	} {
+		zw := NewWriter(io.Discard, opts)
+		zw.Write([]byte("abcde"))
+		if err := zw.Close(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Close() with %+v error = %v, want %v", opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestWriterFrequencyMismatch(t *testing.T) {
+	zw := NewWriter(io.Discard, Options{Frequency: compressutils.Frequency{'a': 2, 'b': 1}})
+	if _, err := zw.Write([]byte("ab")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := zw.Close(); !errors.Is(err, ErrFrequencyMatch) {
+		t.Errorf("Close() error = %v, want %v", err, ErrFrequencyMatch)
+	}
+}
+
+func TestDegenerateInputs(t *testing.T) {
+	run := bytes.Repeat([]byte("x"), 1<<20)
+
+	modes := []struct {
+		name string
+		opts func(input []byte) Options
+	}{
+		{name: "Buffered", opts: func([]byte) Options { return Options{} }},
+		{name: "Frequency", opts: func(input []byte) Options {
+			return Options{Frequency: compressutils.GetFrequencyForBytes(input)}
+		}},
+		{name: "Blocks", opts: func([]byte) Options { return Options{BlockSize: 64 * 1024} }},
+		{name: "Adaptive", opts: func([]byte) Options { return Options{Adaptive: true} }},
+		{name: "LZ", opts: func([]byte) Options { return Options{LZ: true} }},
+	}
+	inputs := []struct {
+		name  string
+		input []byte
+		// maxSize bounds the compressed size in the Huffman only static
+		// modes.
+		maxSize int
+	}{
+		// Magic, version, flags, an empty table, the length and the
+		// checksum.
+		{name: "Empty", input: nil, maxSize: 19},
+		{name: "Single byte", input: []byte("a"), maxSize: 21},
+		// A run is stored without a payload.
+		{name: "Run of one byte", input: run, maxSize: 64 + 16*4},
+	}
+
+	for _, in := range inputs {
+		for _, mode := range modes {
+			t.Run(in.name+"/"+mode.name, func(t *testing.T) {
+				opts := mode.opts(in.input)
+				compressed := compress(t, in.input, opts)
+				if got := decompress(t, compressed); !bytes.Equal(got, in.input) {
+					t.Fatalf("round trip gave %d bytes, want %d", len(got), len(in.input))
+				}
+				if !opts.Adaptive && !opts.LZ && len(compressed) > in.maxSize {
+					t.Errorf("compressed to %d bytes, want at most %d", len(compressed), in.maxSize)
+				}
+			})
+		}
+	}
+}
+
+func TestWriterRunMismatch(t *testing.T) {
+	for _, frequency := range []compressutils.Frequency{{}, {'a': 3}} {
+		zw := NewWriter(io.Discard, Options{Frequency: frequency})
+		if _, err := zw.Write([]byte("aab")); !errors.Is(err, ErrFrequencyMatch) {
+			t.Errorf("Write() with %v error = %v, want %v", frequency, err, ErrFrequencyMatch)
+		}
+	}
+}
+
+func TestReaderVersion3Run(t *testing.T) {
+	input := bytes.Repeat([]byte("z"), 20)
+	compressed := compress(t, input, Options{})
+
+	// Version 3 stored the run with a one bit code per byte before the
+	// checksum.
+	checksum := len(compressed) - checksumSize
+	old := append(bytes.Clone(compressed[:checksum]), make([]byte, (len(input)+7)/8)...)
+	old = append(old, compressed[checksum:]...)
+	old[len(Magic)] = runPayloadVersion
+
+	if got := decompress(t, old); !bytes.Equal(got, input) {
+		t.Errorf("version 3 run decompressed to %q, want %q", got, input)
+	}
+}
+
+func TestReaderRejectsEmptyTableWithData(t *testing.T) {
+	var buf bytes.Buffer
+	writeHeader(&buf, &header{
+		version:        FormatVersion,
+		flags:          flagChecksum,
+		codeLengths:    compressutils.CodeLengthTable{},
+		originalLength: 5,
+	})
+	if _, err := NewReader(&buf); !errors.Is(err, ErrCorruptHeader) {
+		t.Errorf("NewReader() error = %v, want %v", err, ErrCorruptHeader)
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	var buf bytes.Buffer
+	zw := NewWriter(&buf, Options{})
+	zw.Write([]byte("abc"))
+	zw.Close()
+
+	if _, err := zw.Write([]byte("abc")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write() after Close error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	compressed := compress(t, []byte(strings.Repeat("truncate me ", 50)), Options{})
+
+	zr, err := NewReader(bytes.NewReader(compressed[:len(compressed)-10]))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if _, err := io.ReadAll(zr); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("ReadAll() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestReaderChecksum(t *testing.T) {
+	input := []byte(strings.Repeat("bit rot happens ", 100))
+	compressed := compress(t, input, Options{})
+
+	tests := []struct {
+		name     string
+		corrupt  func(data []byte)
+		expected error
+	}{
+		{
+			name:     "Corrupt trailer",
+			corrupt:  func(data []byte) { data[len(data)-1] ^= 0xff },
+			expected: ErrChecksum,
+		},
+		{
+			name:     "Missing trailer",
+			corrupt:  nil,
+			expected: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Clone(compressed)
+			if tt.corrupt != nil {
+				tt.corrupt(data)
+			} else {
+				data = data[:len(data)-checksumSize]
+			}
+
+			zr, err := NewReader(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			if _, err := io.ReadAll(zr); !errors.Is(err, tt.expected) {
+				t.Errorf("ReadAll() error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReaderDetectsPayloadCorruption(t *testing.T) {
+	input := []byte(strings.Repeat("payload corruption must not go unnoticed. ", 100))
+	compressed := compress(t, input, Options{})
+	compressed[len(compressed)/2] ^= 0x10
+
+	zr, err := NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if _, err := io.ReadAll(zr); err == nil {
+		t.Error("ReadAll() expected an error for corrupted payload")
+	}
+}
+
+func TestNewReaderInvalidHeader(t *testing.T) {
+	if _, err := NewReader(strings.NewReader("not a compactor file")); !errors.Is(err, ErrNotCompactor) {
+		t.Errorf("NewReader() error = %v, want %v", err, ErrNotCompactor)
+	}
+}
+
+func benchmarkInput() []byte {
+	return []byte(strings.Repeat("2024-10-17T12:00:00Z INFO request served path=/api/v1/items status=200 duration=12ms\n", 16384))
+}
+
+func BenchmarkWriter(b *testing.B) {
+	input := benchmarkInput()
+	frequency, err := compressutils.GetFrequencyForReader(bytes.NewReader(input))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(input)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		zw := NewWriter(io.Discard, Options{Frequency: *frequency})
+		zw.Write(input)
+		zw.Close()
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	input := benchmarkInput()
+	var buf bytes.Buffer
+	zw := NewWriter(&buf, Options{})
+	zw.Write(input)
+	zw.Close()
+	b.SetBytes(int64(len(input)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		zr, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(io.Discard, zr)
+	}
+}
@@ -0,0 +1,297 @@
+package compactor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// Container layout, all integers big-endian unless noted:
+//
+//	magic           4 bytes  "CPTR"
+//	version         1 byte
+//	flags           1 byte
+//	body            single table or block mode, see below
+//	checksum        4 bytes CRC-32 (IEEE) of the original data, present when
+//	                flagChecksum is set
+//
+// Single table body:
+//
+//	code table      see appendCodeLengths
+//	original length 8 bytes
+//	payload         Huffman encoded data, zero padded to a whole byte
+//
+// With flagTable the code table is a trained Table the file references
+// instead, see table.go:
+//
+//	table ID        8 bytes
+//	original length uvarint
+//	payload         Huffman encoded data, zero padded to a whole byte
+//
+// With flagPreset it is one of the tables built into the package, see
+// preset.go:
+//
+//	preset ID       1 byte
+//	original length uvarint
+//	payload         Huffman encoded data, zero padded to a whole byte
+//
+// Empty input has an empty code table and no payload. A code table of a
+// single symbol has no payload either: the data is that byte repeated
+// original length times. Version 3 wrote such a run with a payload of one
+// zero bit per byte, which is still read.
+//
+// Block mode body (flagBlocks), every block has its own code table so blocks
+// can be encoded and decoded independently:
+//
+//	block size      uvarint, the largest original length of a block
+//	blocks          per block: original length (uvarint), code table,
+//	                payload length (uvarint), payload
+//	end marker      uvarint 0
+//
+// A block of a single repeated byte has a table of one symbol and a payload
+// length of zero.
+//
+// With flagLZ the blocks are in the LZ block format described in lz.go. With
+// flagIndex the end marker is followed by the block index described in
+// seekable.go.
+//
+// Adaptive body (flagAdaptive), the code tree is built up while decoding so
+// there is no table and no length:
+//
+//	payload         adaptive Huffman encoded data ending with the end of
+//	                stream symbol, zero padded to a whole byte
+const (
+	Magic         = "CPTR"
+	FormatVersion = 4
+
+	// runPayloadVersion is the last version that stored runs with a
+	// payload in single table mode. It differs from the current version in
+	// nothing else and is still read.
+	runPayloadVersion = 3
+
+	checksumSize = 4
+)
+
+// Header flags.
+const (
+	flagChecksum uint8 = 1 << iota
+	flagBlocks
+	flagAdaptive
+	flagLZ
+	flagIndex
+	flagTable
+	flagPreset
+
+	knownFlags = flagChecksum | flagBlocks | flagAdaptive | flagLZ | flagIndex | flagTable | flagPreset
+)
+
+var (
+	ErrNotCompactor       = errors.New("compactor: not a compactor file (bad magic)")
+	ErrUnsupportedVersion = errors.New("compactor: unsupported format version")
+	ErrCorruptHeader      = errors.New("compactor: corrupt header")
+)
+
+type header struct {
+	version uint8
+	flags   uint8
+
+	// Single table mode.
+	codeLengths    compressutils.CodeLengthTable
+	originalLength uint64
+	// tableID replaces codeLengths with flagTable, preset with flagPreset.
+	tableID TableID
+	preset  uint8
+
+	// Block mode.
+	blockSize uint64
+}
+
+// appendCodeLengths serializes a code table as a uvarint entry count
+// followed by (symbol, code length in bits) byte pairs in canonical order.
+// The canonical codes are rebuilt from the lengths, so that is all the table
+// needs to carry.
+func appendCodeLengths(buf []byte, codeLengths compressutils.CodeLengthTable) ([]byte, error) {
+	buf = binary.AppendUvarint(buf, uint64(len(codeLengths)))
+	for _, char := range codeLengths.SortedSymbols() {
+		length := codeLengths[char]
+		if length <= 0 || length > compressutils.MaxCodeLength {
+			return nil, fmt.Errorf("compactor: code length %d for %q cannot be stored", length, char)
+		}
+		buf = append(buf, char, byte(length))
+	}
+	return buf, nil
+}
+
+// runSymbol returns the byte a code table of a single symbol stands for. Data
+// coded with such a table is that byte repeated, so it needs no payload.
+func runSymbol(codeLengths compressutils.CodeLengthTable) (byte, bool) {
+	if len(codeLengths) != 1 {
+		return 0, false
+	}
+	for char := range codeLengths {
+		return char, true
+	}
+	return 0, false
+}
+
+// readCodeLengths reads a table written by appendCodeLengths. Errors of r are
+// returned as they are, the caller knows whether the end of its input is
+// expected.
+func readCodeLengths(r io.ByteReader) (compressutils.CodeLengthTable, error) {
+	codeCount, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if codeCount > 256 {
+		return nil, fmt.Errorf("%w: invalid code count %d", ErrCorruptHeader, codeCount)
+	}
+
+	codeLengths := make(compressutils.CodeLengthTable, codeCount)
+	for i := uint64(0); i < codeCount; i++ {
+		symbol, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if length == 0 || length > compressutils.MaxCodeLength {
+			return nil, fmt.Errorf("%w: code length %d out of range", ErrCorruptHeader, length)
+		}
+		codeLengths[symbol] = int(length)
+	}
+	return codeLengths, nil
+}
+
+func writeHeader(w io.Writer, h *header) error {
+	buf := []byte(Magic)
+	buf = append(buf, h.version, h.flags)
+
+	switch {
+	case h.flags&flagAdaptive != 0:
+		// The adaptive body starts right away.
+	case h.flags&flagBlocks != 0:
+		buf = binary.AppendUvarint(buf, h.blockSize)
+	case h.flags&flagTable != 0:
+		buf = append(buf, h.tableID[:]...)
+		buf = binary.AppendUvarint(buf, h.originalLength)
+	case h.flags&flagPreset != 0:
+		buf = append(buf, h.preset)
+		buf = binary.AppendUvarint(buf, h.originalLength)
+	default:
+		var err error
+		if buf, err = appendCodeLengths(buf, h.codeLengths); err != nil {
+			return err
+		}
+		buf = binary.BigEndian.AppendUint64(buf, h.originalLength)
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// readHeader parses the container header and leaves r positioned at the
+// start of the body.
+func readHeader(r *bufio.Reader) (*header, error) {
+	magic := make([]byte, len(Magic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrNotCompactor
+		}
+		return nil, err
+	}
+	if string(magic) != Magic {
+		return nil, ErrNotCompactor
+	}
+
+	h := &header{}
+	var err error
+	if h.version, err = r.ReadByte(); err != nil {
+		return nil, headerError(err)
+	}
+	if h.version != FormatVersion && h.version != runPayloadVersion {
+		return nil, fmt.Errorf("%w %d (this build reads versions %d and %d)", ErrUnsupportedVersion, h.version, runPayloadVersion, FormatVersion)
+	}
+	if h.flags, err = r.ReadByte(); err != nil {
+		return nil, headerError(err)
+	}
+	if h.flags&^knownFlags != 0 {
+		return nil, fmt.Errorf("%w: unknown flags 0x%02x", ErrCorruptHeader, h.flags&^knownFlags)
+	}
+
+	if h.flags&flagBlocks != 0 && h.flags&flagAdaptive != 0 {
+		return nil, fmt.Errorf("%w: block and adaptive mode are exclusive", ErrCorruptHeader)
+	}
+	if h.flags&flagLZ != 0 && h.flags&flagBlocks == 0 {
+		return nil, fmt.Errorf("%w: LZ mode requires block mode", ErrCorruptHeader)
+	}
+	if h.flags&flagIndex != 0 && h.flags&flagBlocks == 0 {
+		return nil, fmt.Errorf("%w: a block index requires block mode", ErrCorruptHeader)
+	}
+	if h.flags&(flagTable|flagPreset) != 0 && h.flags&(flagBlocks|flagAdaptive) != 0 {
+		return nil, fmt.Errorf("%w: a trained or preset table requires single table mode", ErrCorruptHeader)
+	}
+	if h.flags&flagTable != 0 && h.flags&flagPreset != 0 {
+		return nil, fmt.Errorf("%w: trained and preset table are exclusive", ErrCorruptHeader)
+	}
+	if h.flags&flagAdaptive != 0 {
+		return h, nil
+	}
+	if h.flags&flagBlocks != 0 {
+		if h.blockSize, err = binary.ReadUvarint(r); err != nil {
+			return nil, headerError(err)
+		}
+		if h.blockSize == 0 || h.blockSize > MaxBlockSize {
+			return nil, fmt.Errorf("%w: block size %d out of range", ErrCorruptHeader, h.blockSize)
+		}
+		return h, nil
+	}
+	if h.flags&flagTable != 0 {
+		if _, err := io.ReadFull(r, h.tableID[:]); err != nil {
+			return nil, headerError(err)
+		}
+		if h.originalLength, err = binary.ReadUvarint(r); err != nil {
+			return nil, headerError(err)
+		}
+		return h, nil
+	}
+	if h.flags&flagPreset != 0 {
+		if h.preset, err = r.ReadByte(); err != nil {
+			return nil, headerError(err)
+		}
+		if !validPreset(h.preset) {
+			return nil, fmt.Errorf("%w: ID %d, this build knows %d presets", ErrUnknownPreset, h.preset, len(presetInfos))
+		}
+		if h.originalLength, err = binary.ReadUvarint(r); err != nil {
+			return nil, headerError(err)
+		}
+		return h, nil
+	}
+
+	if h.codeLengths, err = readCodeLengths(r); err != nil {
+		return nil, headerError(err)
+	}
+
+	var originalLength [8]byte
+	if _, err := io.ReadFull(r, originalLength[:]); err != nil {
+		return nil, headerError(err)
+	}
+	h.originalLength = binary.BigEndian.Uint64(originalLength[:])
+	if len(h.codeLengths) == 0 && h.originalLength > 0 {
+		return nil, fmt.Errorf("%w: no code table for %d bytes", ErrCorruptHeader, h.originalLength)
+	}
+
+	return h, nil
+}
+
+func headerError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end of header", ErrCorruptHeader)
+	}
+	return err
+}
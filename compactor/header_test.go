@@ -0,0 +1,190 @@
+package compactor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		header *header
+	}{
+		{
+			name: "Single table",
+			header: &header{
+				version: FormatVersion,
+				flags:   flagChecksum,
+				codeLengths: compressutils.CodeLengthTable{
+					':':  1,
+					'\n': 2,
+					'a':  3,
+					0xff: 3,
+				},
+				originalLength: 42,
+			},
+		},
+		{
+			name:   "Blocks",
+			header: &header{version: FormatVersion, flags: flagChecksum | flagBlocks, blockSize: DefaultBlockSize},
+		},
+		{
+			name:   "Adaptive",
+			header: &header{version: FormatVersion, flags: flagChecksum | flagAdaptive},
+		},
+		{
+			name: "Trained table",
+			header: &header{
+				version:        FormatVersion,
+				flags:          flagChecksum | flagTable,
+				originalLength: 300,
+				tableID:        TableID{1, 2, 3, 4, 5, 6, 7, 8},
+			},
+		},
+		{
+			name:   "Preset",
+			header: &header{version: FormatVersion, flags: flagChecksum | flagPreset, originalLength: 70, preset: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeHeader(&buf, tt.header); err != nil {
+				t.Fatalf("writeHeader() error = %v", err)
+			}
+			if !bytes.HasPrefix(buf.Bytes(), []byte(Magic)) {
+				t.Errorf("header does not start with magic %q", Magic)
+			}
+
+			got, err := readHeader(bufio.NewReader(&buf))
+			if err != nil {
+				t.Fatalf("readHeader() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.header) {
+				t.Errorf("readHeader() = %+v, want %+v", got, tt.header)
+			}
+		})
+	}
+}
+
+func TestReadHeaderErrors(t *testing.T) {
+	valid := func() []byte {
+		var buf bytes.Buffer
+		writeHeader(&buf, &header{
+			version:        FormatVersion,
+			codeLengths:    compressutils.CodeLengthTable{'a': 1, 'b': 1},
+			originalLength: 2,
+		})
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name     string
+		input    func() []byte
+		expected error
+	}{
+		{
+			name:     "Empty input",
+			input:    func() []byte { return nil },
+			expected: ErrNotCompactor,
+		},
+		{
+			name:     "Bad magic",
+			input:    func() []byte { return []byte("PaddingBits:3\n") },
+			expected: ErrNotCompactor,
+		},
+		{
+			name: "Unknown version",
+			input: func() []byte {
+				data := valid()
+				data[len(Magic)] = FormatVersion + 1
+				return data
+			},
+			expected: ErrUnsupportedVersion,
+		},
+		{
+			name: "Old version",
+			input: func() []byte {
+				data := valid()
+				data[len(Magic)] = runPayloadVersion - 1
+				return data
+			},
+			expected: ErrUnsupportedVersion,
+		},
+		{
+			name: "Unknown flags",
+			input: func() []byte {
+				data := valid()
+				data[len(Magic)+1] = 0x80
+				return data
+			},
+			expected: ErrCorruptHeader,
+		},
+		{
+			name:     "Truncated",
+			input:    func() []byte { return valid()[:len(Magic)+4] },
+			expected: ErrCorruptHeader,
+		},
+		{
+			name: "Block and adaptive mode",
+			input: func() []byte {
+				return []byte{'C', 'P', 'T', 'R', FormatVersion, flagBlocks | flagAdaptive, 1}
+			},
+			expected: ErrCorruptHeader,
+		},
+		{
+			name:     "LZ without blocks",
+			input:    func() []byte { return []byte{'C', 'P', 'T', 'R', FormatVersion, flagLZ} },
+			expected: ErrCorruptHeader,
+		},
+		{
+			name:     "Index without blocks",
+			input:    func() []byte { return []byte{'C', 'P', 'T', 'R', FormatVersion, flagIndex} },
+			expected: ErrCorruptHeader,
+		},
+		{
+			name:     "Table with blocks",
+			input:    func() []byte { return []byte{'C', 'P', 'T', 'R', FormatVersion, flagTable | flagBlocks, 1} },
+			expected: ErrCorruptHeader,
+		},
+		{
+			name:     "Truncated table ID",
+			input:    func() []byte { return []byte{'C', 'P', 'T', 'R', FormatVersion, flagTable, 1, 2, 3} },
+			expected: ErrCorruptHeader,
+		},
+		{
+			name:     "Unknown preset",
+			input:    func() []byte { return []byte{'C', 'P', 'T', 'R', FormatVersion, flagPreset, 200, 1} },
+			expected: ErrUnknownPreset,
+		},
+		{
+			name:     "Table and preset",
+			input:    func() []byte { return []byte{'C', 'P', 'T', 'R', FormatVersion, flagTable | flagPreset, 1, 1} },
+			expected: ErrCorruptHeader,
+		},
+		{
+			name: "Block size out of range",
+			input: func() []byte {
+				data := []byte{'C', 'P', 'T', 'R', FormatVersion, flagBlocks}
+				return binary.AppendUvarint(data, MaxBlockSize+1)
+			},
+			expected: ErrCorruptHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readHeader(bufio.NewReader(bytes.NewReader(tt.input())))
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("readHeader() error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+}
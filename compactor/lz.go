@@ -0,0 +1,119 @@
+package compactor
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// An LZ block runs the block through the LZ77 stage first and stores the
+// result as separate byte streams, each Huffman coded with its own table:
+//
+//	original length uvarint
+//	literals        the literal bytes of all sequences, then the trailing
+//	                literals
+//	lengths         per sequence the literal length and the match length
+//	                minus LZMinMatch, see appendLZLength
+//	offset high     per sequence the high byte of the match offset
+//	offset low      per sequence the low byte of the match offset
+//
+// Keeping the kinds apart gives every stream a skewed distribution that the
+// byte oriented Huffman coder handles well.
+const lzStreamCount = 4
+
+// appendLZLength stores n as a run of 255 bytes and a final byte below 255
+// that add up to n. Short lengths, by far the most common, take one byte.
+func appendLZLength(buf []byte, n int) []byte {
+	for ; n >= 255; n -= 255 {
+		buf = append(buf, 255)
+	}
+	return append(buf, byte(n))
+}
+
+func readLZLength(buf []byte) (n int, rest []byte, ok bool) {
+	for i, b := range buf {
+		n += int(b)
+		if b != 255 {
+			return n, buf[i+1:], true
+		}
+	}
+	return 0, nil, false
+}
+
+// compressLZBlock encodes one block in the LZ block format.
+func compressLZBlock(data []byte, maxCodeLength int) ([]byte, error) {
+	var literals, lengths, offsetHigh, offsetLow []byte
+	pos := 0
+	for _, seq := range compressutils.LZParse(data) {
+		literals = append(literals, data[pos:pos+seq.LiteralLength]...)
+		lengths = appendLZLength(lengths, seq.LiteralLength)
+		lengths = appendLZLength(lengths, seq.MatchLength-compressutils.LZMinMatch)
+		offsetHigh = append(offsetHigh, byte(seq.Offset>>8))
+		offsetLow = append(offsetLow, byte(seq.Offset))
+		pos += seq.LiteralLength + seq.MatchLength
+	}
+	literals = append(literals, data[pos:]...)
+
+	frame := binary.AppendUvarint(nil, uint64(len(data)))
+	for _, s := range [lzStreamCount][]byte{literals, lengths, offsetHigh, offsetLow} {
+		var err error
+		if frame, err = appendStream(frame, s, maxCodeLength); err != nil {
+			return nil, err
+		}
+	}
+	return frame, nil
+}
+
+// decompressLZBlock decodes the streams of an LZ block and replays its
+// sequences.
+func decompressLZBlock(originalLength int, streams [lzStreamCount]*stream) ([]byte, error) {
+	var decoded [lzStreamCount][]byte
+	for i, s := range streams {
+		var err error
+		if decoded[i], err = s.decode(); err != nil {
+			return nil, err
+		}
+	}
+	literals, lengths, offsetHigh, offsetLow := decoded[0], decoded[1], decoded[2], decoded[3]
+	if len(offsetHigh) != len(offsetLow) {
+		return nil, fmt.Errorf("%w: LZ offset streams differ in length", ErrCorruptData)
+	}
+
+	data := make([]byte, 0, originalLength)
+	sequences := 0
+	for ; len(lengths) > 0; sequences++ {
+		literalLength, rest, ok := readLZLength(lengths)
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated LZ length", ErrCorruptData)
+		}
+		matchLength, rest, ok := readLZLength(rest)
+		if !ok {
+			return nil, fmt.Errorf("%w: truncated LZ length", ErrCorruptData)
+		}
+		lengths = rest
+		matchLength += compressutils.LZMinMatch
+
+		if literalLength > len(literals) || sequences >= len(offsetHigh) ||
+			literalLength+matchLength > originalLength-len(data) {
+			return nil, fmt.Errorf("%w: LZ sequence exceeds the block", ErrCorruptData)
+		}
+		data = append(data, literals[:literalLength]...)
+		literals = literals[literalLength:]
+
+		offset := int(offsetHigh[sequences])<<8 | int(offsetLow[sequences])
+		var err error
+		if data, err = compressutils.AppendLZMatch(data, offset, matchLength); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCorruptData, err)
+		}
+	}
+	data = append(data, literals...)
+
+	if sequences != len(offsetHigh) {
+		return nil, fmt.Errorf("%w: %d LZ offsets for %d sequences", ErrCorruptData, len(offsetHigh), sequences)
+	}
+	if len(data) != originalLength {
+		return nil, fmt.Errorf("%w: LZ block decodes to %d bytes instead of %d", ErrCorruptData, len(data), originalLength)
+	}
+	return data, nil
+}
@@ -0,0 +1,168 @@
+package compactor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+func jsonLogs(lines int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&buf, `{"time":"2024-10-17T12:%02d:%02dZ","level":"info","msg":"request served","path":"/api/v1/items/%d","status":200}`+"\n", i/60%60, i%60, i%97)
+	}
+	return buf.Bytes()
+}
+
+func TestLZRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     []byte
+		blockSize int
+	}{
+		{name: "Empty", input: nil},
+		{name: "Shorter than a match", input: []byte("abc")},
+		{name: "Single repeated byte", input: bytes.Repeat([]byte("x"), 5000)},
+		{name: "JSON logs", input: jsonLogs(2000)},
+		{name: "Binary", input: blockInput(50000)},
+		{name: "Several blocks", input: jsonLogs(3000), blockSize: 64 * 1024},
+		{name: "Long matches", input: bytes.Repeat(blockInput(3000), 20), blockSize: 16 * 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := compress(t, tt.input, Options{LZ: true, BlockSize: tt.blockSize})
+			if got := decompress(t, compressed); !bytes.Equal(got, tt.input) {
+				t.Errorf("round trip of %d bytes mismatch", len(tt.input))
+			}
+		})
+	}
+}
+
+func TestLZCompressesRepetitiveData(t *testing.T) {
+	input := jsonLogs(5000)
+	huffman := compress(t, input, Options{})
+	lz := compress(t, input, Options{LZ: true})
+
+	// Huffman alone stays above half the size on these logs, the LZ stage
+	// should do several times better.
+	if len(lz)*4 > len(huffman) {
+		t.Errorf("LZ output %d bytes, Huffman only %d bytes, want at least 4x smaller", len(lz), len(huffman))
+	}
+}
+
+func TestLZInvalidOptions(t *testing.T) {
+	for _, opts := range []Options{
+		{LZ: true, Adaptive: true},
+		{LZ: true, Frequency: map[byte]int{'a': 1}},
+	} {
+		zw := NewWriter(io.Discard, opts)
+		if err := zw.Close(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Close() with %+v error = %v, want %v", opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestReadLZLength(t *testing.T) {
+	tests := []struct {
+		input    []byte
+		expected int
+		ok       bool
+	}{
+		{input: []byte{0}, expected: 0, ok: true},
+		{input: []byte{254, 7}, expected: 254, ok: true},
+		{input: []byte{255, 0}, expected: 255, ok: true},
+		{input: []byte{255, 255, 10}, expected: 520, ok: true},
+		{input: []byte{255}, ok: false},
+	}
+
+	for _, tt := range tests {
+		n, _, ok := readLZLength(tt.input)
+		if n != tt.expected || ok != tt.ok {
+			t.Errorf("readLZLength(%v) = %d, %v; want %d, %v", tt.input, n, ok, tt.expected, tt.ok)
+		}
+		if tt.ok && !bytes.HasPrefix(tt.input, appendLZLength(nil, tt.expected)) {
+			t.Errorf("appendLZLength(%d) = %v, want a prefix of %v", tt.expected, appendLZLength(nil, tt.expected), tt.input)
+		}
+	}
+}
+
+func TestDecompressLZBlockErrors(t *testing.T) {
+	streamsOf := func(literals, lengths, offsetHigh, offsetLow string) [lzStreamCount]*stream {
+		var streams [lzStreamCount]*stream
+		for i, data := range []string{literals, lengths, offsetHigh, offsetLow} {
+			frame, err := appendStream(nil, []byte(data), compressutils.DefaultMaxCodeLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Skip the uvarint stream length, a single byte for these tests.
+			if streams[i], err = readStream(bufioReader(frame[1:]), uint64(len(data))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return streams
+	}
+
+	tests := []struct {
+		name           string
+		originalLength int
+		streams        [lzStreamCount]*stream
+	}{
+		{name: "Offset before the start", originalLength: 8, streams: streamsOf("abcd", "\x00\x00", "\x00", "\x05")},
+		{name: "Zero offset", originalLength: 8, streams: streamsOf("abcd", "\x04\x00", "\x00", "\x00")},
+		{name: "Match past the block", originalLength: 6, streams: streamsOf("abcd", "\x04\x00", "\x00", "\x04")},
+		{name: "Missing offset", originalLength: 8, streams: streamsOf("abcd", "\x04\x00", "", "")},
+		{name: "Unused offset", originalLength: 8, streams: streamsOf("abcd", "\x04\x00", "\x00\x00", "\x04\x04")},
+		{name: "Truncated length", originalLength: 8, streams: streamsOf("abcd", "\x04\xff", "\x00", "\x04")},
+		{name: "Short block", originalLength: 9, streams: streamsOf("abcd", "\x04\x00", "\x00", "\x04")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decompressLZBlock(tt.originalLength, tt.streams); !errors.Is(err, ErrCorruptData) {
+				t.Errorf("decompressLZBlock() error = %v, want %v", err, ErrCorruptData)
+			}
+		})
+	}
+
+	valid := streamsOf("abcd", "\x04\x00", "\x00", "\x04")
+	if data, err := decompressLZBlock(8, valid); err != nil || string(data) != "abcdabcd" {
+		t.Errorf("decompressLZBlock() = %q, %v; want %q", data, err, "abcdabcd")
+	}
+}
+
+func BenchmarkLZWriter(b *testing.B) {
+	input := jsonLogs(20000)
+	b.SetBytes(int64(len(input)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		zw := NewWriter(io.Discard, Options{LZ: true})
+		zw.Write(input)
+		zw.Close()
+	}
+}
+
+func BenchmarkLZReader(b *testing.B) {
+	input := jsonLogs(20000)
+	var buf bytes.Buffer
+	zw := NewWriter(&buf, Options{LZ: true})
+	zw.Write(input)
+	zw.Close()
+	b.SetBytes(int64(len(input)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		zr, _ := NewReader(bytes.NewReader(buf.Bytes()))
+		io.Copy(io.Discard, zr)
+	}
+}
+
+func bufioReader(data []byte) *bufio.Reader {
+	return bufio.NewReader(bytes.NewReader(data))
+}
@@ -0,0 +1,172 @@
+package compactor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// Presets are tables built into every build, so a file compressed with one
+// stores neither a code table nor a table ID but the one byte preset ID,
+// its index in presetInfos plus one. The code lengths were trained on
+// samples of each kind of content and are part of the format: they can
+// never change, a new table needs a new ID.
+var presetInfos = []struct {
+	name        string
+	codeLengths *[256]uint8
+}{
+	{name: "text", codeLengths: &textCodeLengths},
+	{name: "json", codeLengths: &jsonCodeLengths},
+	{name: "source", codeLengths: &sourceCodeLengths},
+	{name: "hex", codeLengths: &hexCodeLengths},
+	{name: "base64", codeLengths: &base64CodeLengths},
+}
+
+var ErrUnknownPreset = errors.New("compactor: unknown preset table")
+
+// presets builds the tables of presetInfos the first time they are needed.
+var presets = sync.OnceValue(func() []*Table {
+	tables := make([]*Table, len(presetInfos))
+	for i, info := range presetInfos {
+		codeLengths := make(compressutils.CodeLengthTable, len(info.codeLengths))
+		for symbol, length := range info.codeLengths {
+			codeLengths[byte(symbol)] = int(length)
+		}
+		t, err := newTable(codeLengths)
+		if err != nil {
+			panic(fmt.Sprintf("compactor: preset %s: %v", info.name, err))
+		}
+		t.name = info.name
+		t.preset = uint8(i + 1)
+		tables[i] = t
+	}
+	return tables
+})
+
+// Presets returns the built-in tables: "text" for English prose, "json",
+// "source" for program code, "hex" and "base64". Any of them can be used as
+// Options.Table and is found by every Reader without being given.
+func Presets() []*Table {
+	return slices.Clone(presets())
+}
+
+// Preset returns the built-in table called name.
+func Preset(name string) (*Table, error) {
+	for _, t := range presets() {
+		if t.name == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownPreset, name)
+}
+
+// validPreset reports whether id is the ID of a built-in table.
+func validPreset(id uint8) bool {
+	return id > 0 && int(id) <= len(presetInfos)
+}
+
+// presetByID returns the built-in table with the given ID, which readHeader
+// has checked.
+func presetByID(id uint8) *Table {
+	return presets()[id-1]
+}
+
+// Code lengths of the presets, indexed by byte value.
+
+var textCodeLengths = [256]uint8{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 12, 5, 15, 15, 11, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	3, 10, 8, 8, 13, 12, 11, 10, 8, 8, 9, 13, 7, 7, 6, 6,
+	9, 9, 9, 10, 10, 10, 10, 11, 11, 11, 7, 10, 10, 9, 10, 11,
+	14, 9, 10, 8, 9, 8, 10, 10, 10, 8, 13, 12, 9, 9, 9, 10,
+	9, 14, 9, 8, 8, 10, 11, 11, 13, 11, 13, 8, 13, 8, 13, 8,
+	7, 5, 7, 5, 5, 4, 6, 6, 5, 4, 11, 8, 5, 6, 5, 4,
+	6, 10, 4, 4, 4, 6, 7, 7, 8, 7, 10, 10, 10, 10, 15, 15,
+	13, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 13, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 13, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+}
+
+var jsonCodeLengths = [256]uint8{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 8, 5, 15, 15, 7, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	2, 14, 4, 11, 13, 12, 11, 13, 13, 13, 11, 10, 6, 7, 5, 7,
+	7, 7, 7, 8, 8, 8, 8, 9, 9, 9, 5, 15, 14, 10, 12, 14,
+	10, 9, 9, 8, 9, 9, 10, 10, 10, 9, 10, 11, 10, 8, 9, 10,
+	9, 11, 9, 8, 9, 10, 10, 10, 11, 11, 11, 10, 12, 10, 9, 9,
+	13, 5, 7, 6, 6, 4, 7, 7, 7, 5, 8, 8, 6, 6, 5, 5,
+	6, 10, 5, 5, 5, 6, 8, 8, 8, 7, 9, 7, 13, 8, 12, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 13, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+}
+
+var sourceCodeLengths = [256]uint8{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 6, 5, 15, 15, 11, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	2, 10, 7, 9, 13, 11, 9, 9, 6, 7, 8, 11, 6, 9, 6, 6,
+	8, 8, 8, 9, 9, 9, 9, 10, 10, 10, 7, 8, 10, 7, 9, 12,
+	13, 8, 9, 8, 9, 8, 9, 10, 9, 8, 13, 11, 9, 9, 8, 9,
+	8, 13, 8, 8, 8, 9, 10, 10, 11, 11, 11, 9, 10, 9, 14, 6,
+	9, 5, 7, 6, 6, 4, 6, 7, 6, 5, 10, 8, 6, 7, 5, 5,
+	6, 9, 5, 5, 4, 6, 8, 8, 8, 7, 9, 8, 11, 8, 12, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+}
+
+var hexCodeLengths = [256]uint8{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 6, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 15, 15, 15, 15, 15, 15,
+	15, 7, 7, 6, 6, 7, 6, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 5, 4, 4, 5, 4, 5, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 14, 14, 14, 14, 14, 14, 14,
+	14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14,
+}
+
+var base64CodeLengths = [256]uint8{
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 7, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 6, 15, 15, 15, 6,
+	6, 6, 6, 6, 6, 6, 7, 6, 6, 6, 15, 15, 15, 14, 15, 15,
+	15, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6,
+	6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 15, 15, 15, 15, 15,
+	15, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 7,
+	6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15, 15,
+	14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14,
+	14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14,
+	14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14,
+	14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14, 14,
+}
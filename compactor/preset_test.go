@@ -0,0 +1,140 @@
+package compactor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestPresets(t *testing.T) {
+	ids := map[TableID]string{}
+	for i, table := range Presets() {
+		if table.preset != uint8(i+1) {
+			t.Errorf("preset %s has ID %d, want %d", table.Name(), table.preset, i+1)
+		}
+		if other, ok := ids[table.ID()]; ok {
+			t.Errorf("presets %s and %s have the same table", other, table.Name())
+		}
+		ids[table.ID()] = table.Name()
+
+		got, err := Preset(table.Name())
+		if err != nil || got != table {
+			t.Errorf("Preset(%q) = %v, %v, want the preset", table.Name(), got, err)
+		}
+	}
+	if len(ids) != 5 {
+		t.Errorf("Presets() = %d tables, want 5", len(ids))
+	}
+
+	if _, err := Preset("xml"); !errors.Is(err, ErrUnknownPreset) {
+		t.Errorf("Preset(%q) error = %v, want %v", "xml", err, ErrUnknownPreset)
+	}
+}
+
+func TestPresetRoundTrip(t *testing.T) {
+	inputs := map[string][]byte{
+		"text":   []byte("The quick brown fox jumps over the lazy dog, and then it rests.\n"),
+		"json":   jsonLogs(2),
+		"source": []byte("func main() {\n\tfmt.Println(\"hello\")\n}\n"),
+		"hex":    []byte(hex.EncodeToString(blockInput(100)) + "\n"),
+		"base64": []byte("SGVsbG8sIFdvcmxkIQ==\n"),
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			table, err := Preset(name)
+			if err != nil {
+				t.Fatalf("Preset() error = %v", err)
+			}
+			compressed := compress(t, input, Options{Table: table})
+			// The preset is found without being given to the reader.
+			if got := decompress(t, compressed); !bytes.Equal(got, input) {
+				t.Errorf("round trip of %d bytes mismatch", len(input))
+			}
+
+			// A trained table with the same codes costs the table ID.
+			trained := compress(t, input, Options{Table: &Table{id: table.id, codeLengths: table.codeLengths, codeTable: table.codeTable, decoder: table.decoder}})
+			if len(trained)-len(compressed) != TableIDSize-1 {
+				t.Errorf("preset output %d bytes, trained table %d bytes, want %d bytes less", len(compressed), len(trained), TableIDSize-1)
+			}
+		})
+	}
+}
+
+func TestAutoPreset(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		// want is the preset that should be picked, "" for a table of its
+		// own.
+		want string
+	}{
+		{name: "Small JSON", input: jsonLogs(1), want: "json"},
+		{name: "Sentence", input: []byte("Please find the report attached, let me know if anything is missing.\n"), want: "text"},
+		{name: "Digest", input: []byte("9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08\n"), want: "hex"},
+		{name: "Large JSON", input: jsonLogs(2000)},
+		{name: "Binary", input: blockInput(20000)},
+		{name: "Single repeated byte", input: bytes.Repeat([]byte("x"), 1000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := compress(t, tt.input, Options{AutoPreset: true})
+			if got := decompress(t, compressed); !bytes.Equal(got, tt.input) {
+				t.Fatalf("round trip of %d bytes mismatch", len(tt.input))
+			}
+
+			got := ""
+			if flags := compressed[len(Magic)+1]; flags&flagPreset != 0 {
+				got = presetByID(compressed[len(Magic)+2]).Name()
+			}
+			if got != tt.want {
+				t.Errorf("AutoPreset picked %q, want %q", got, tt.want)
+			}
+			if own := compress(t, tt.input, Options{}); len(compressed) > len(own) {
+				t.Errorf("AutoPreset output %d bytes, own table %d bytes", len(compressed), len(own))
+			}
+		})
+	}
+}
+
+func TestAutoPresetInvalidOptions(t *testing.T) {
+	table, _ := Preset("text")
+	for _, opts := range []Options{
+		{AutoPreset: true, Table: table},
+		{AutoPreset: true, LZ: true},
+		{AutoPreset: true, MaxCodeLength: 12},
+	} {
+		zw := NewWriter(io.Discard, opts)
+		if err := zw.Close(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Close() with %+v error = %v, want %v", opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestPresetArchive(t *testing.T) {
+	archive := writeArchive(t, archiveFiles, Options{AutoPreset: true})
+	ar, err := OpenArchive(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("OpenArchive() error = %v", err)
+	}
+	for i := range ar.Entries {
+		entry := &ar.Entries[i]
+		if entry.IsDir() {
+			continue
+		}
+		zr, err := ar.Open(entry)
+		if err != nil {
+			t.Fatalf("Open(%q) error = %v", entry.Path, err)
+		}
+		got, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("ReadAll(%q) error = %v", entry.Path, err)
+		}
+		if want := archiveFiles[i].content; string(got) != want {
+			t.Errorf("Open(%q) = %d bytes, want %d", entry.Path, len(got), len(want))
+		}
+	}
+}
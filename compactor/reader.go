@@ -0,0 +1,114 @@
+package compactor
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+var (
+	ErrCorruptData = errors.New("compactor: compressed data does not match the code table")
+	ErrChecksum    = errors.New("compactor: checksum mismatch, the file is corrupt")
+)
+
+// bodyReader decodes the container body for one mode.
+type bodyReader interface {
+	io.Reader
+	// trailer returns the input that follows the body. It is only valid once
+	// Read has returned io.EOF.
+	trailer() io.Reader
+}
+
+// Reader is an io.Reader that decodes data produced by a Writer. Like the
+// Writer it keeps all decoding state to itself, so independent Readers can
+// be used from parallel goroutines.
+type Reader struct {
+	body     bodyReader
+	flags    uint8
+	checksum hash.Hash32
+	err      error
+}
+
+// NewReader reads the header from r and returns a Reader that yields the
+// decompressed data.
+func NewReader(r io.Reader) (*Reader, error) {
+	return NewReaderWithTables(r)
+}
+
+// NewReaderWithTables is NewReader for data that may have been written with
+// Options.Table. The table it references has to be one of tables, otherwise
+// it fails with ErrUnknownTable.
+func NewReaderWithTables(r io.Reader, tables ...*Table) (*Reader, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	var table *Table
+	switch {
+	case h.flags&flagTable != 0:
+		if table, err = findTable(h.tableID, tables); err != nil {
+			return nil, err
+		}
+	case h.flags&flagPreset != 0:
+		table = presetByID(h.preset)
+	}
+
+	var body bodyReader
+	switch {
+	case h.flags&flagAdaptive != 0:
+		body = newAdaptiveReader(br)
+	case h.flags&flagBlocks != 0:
+		body = newBlockReader(br, h)
+	default:
+		if body, err = newSingleTableReader(br, h, table); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Reader{
+		body:     body,
+		flags:    h.flags,
+		checksum: crc32.NewIEEE(),
+	}, nil
+}
+
+// verifyChecksum reads the trailer that follows the body and compares it
+// with the checksum of the decoded data.
+func (z *Reader) verifyChecksum() error {
+	var trailer [checksumSize]byte
+	if _, err := io.ReadFull(z.body.trailer(), trailer[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if binary.BigEndian.Uint32(trailer[:]) != z.checksum.Sum32() {
+		return ErrChecksum
+	}
+	return nil
+}
+
+// Read decompresses data into p.
+func (z *Reader) Read(p []byte) (int, error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+
+	n, err := z.body.Read(p)
+	z.checksum.Write(p[:n])
+	if err == io.EOF && z.flags&flagChecksum != 0 {
+		if checksumErr := z.verifyChecksum(); checksumErr != nil {
+			err = checksumErr
+		}
+	}
+	z.err = err
+
+	if n > 0 {
+		return n, nil
+	}
+	return 0, z.err
+}
@@ -0,0 +1,275 @@
+package compactor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Block index (flagIndex), written after the end marker of block mode:
+//
+//	block count     uvarint
+//	blocks          per block: original length (uvarint), frame length
+//	                (uvarint), CRC-32 (IEEE) of the original data (4 bytes)
+//	index length    8 bytes, the length of the count and the blocks
+//
+// The frame length covers the whole block frame including its original
+// length. Only the checksum trailer follows the index length, so a reader
+// with random access finds the index at a fixed distance from the end and
+// from it the position of every block.
+
+var ErrNotSeekable = errors.New("compactor: no block index, the file was not written with Options.Seekable")
+
+type blockIndexEntry struct {
+	length      uint64
+	frameLength uint64
+	checksum    uint32
+}
+
+func appendBlockIndex(buf []byte, index []blockIndexEntry) []byte {
+	start := len(buf)
+	buf = binary.AppendUvarint(buf, uint64(len(index)))
+	for _, entry := range index {
+		buf = binary.AppendUvarint(buf, entry.length)
+		buf = binary.AppendUvarint(buf, entry.frameLength)
+		buf = binary.BigEndian.AppendUint32(buf, entry.checksum)
+	}
+	return binary.BigEndian.AppendUint64(buf, uint64(len(buf)-start))
+}
+
+// readBlockIndex reads a block index up to and including its length, which
+// must match the entries read.
+func readBlockIndex(r *bufio.Reader) ([]blockIndexEntry, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, blockError(err)
+	}
+	length := uint64(len(binary.AppendUvarint(nil, count)))
+
+	// The count is not trusted for the allocation, entries are added as
+	// they are read.
+	index := make([]blockIndexEntry, 0, min(count, 1<<16))
+	for i := uint64(0); i < count; i++ {
+		var entry blockIndexEntry
+		if entry.length, err = binary.ReadUvarint(r); err != nil {
+			return nil, blockError(err)
+		}
+		if entry.frameLength, err = binary.ReadUvarint(r); err != nil {
+			return nil, blockError(err)
+		}
+		var checksum [4]byte
+		if _, err := io.ReadFull(r, checksum[:]); err != nil {
+			return nil, blockError(err)
+		}
+		entry.checksum = binary.BigEndian.Uint32(checksum[:])
+		length += uint64(len(binary.AppendUvarint(nil, entry.length))+len(binary.AppendUvarint(nil, entry.frameLength))) + 4
+		index = append(index, entry)
+	}
+
+	var indexLength [8]byte
+	if _, err := io.ReadFull(r, indexLength[:]); err != nil {
+		return nil, blockError(err)
+	}
+	if binary.BigEndian.Uint64(indexLength[:]) != length {
+		return nil, fmt.Errorf("%w: block index length does not match its entries", ErrCorruptData)
+	}
+	return index, nil
+}
+
+// seekBlock is a block of a seekable file with its position in the
+// decompressed data and in the file.
+type seekBlock struct {
+	blockIndexEntry
+	offset      int64
+	frameOffset int64
+}
+
+// SeekableReader decodes a file written with Options.Seekable from an
+// io.ReaderAt. Only the blocks that hold the requested bytes are read and
+// decoded, and every block is checked against its own checksum, as the
+// checksum of the whole file cannot be verified on a partial read.
+//
+// ReadAt is safe for concurrent use. Read and Seek share a position, like
+// those of an os.File.
+type SeekableReader struct {
+	r         io.ReaderAt
+	blockSize uint64
+	lz        bool
+	blocks    []seekBlock
+	size      int64
+	pos       int64
+
+	// The most recently decoded block is kept, so small sequential reads
+	// decode every block once.
+	mu          sync.Mutex
+	cachedBlock int
+	cachedData  []byte
+}
+
+// NewSeekableReader reads the header and the block index of the size bytes
+// in r. It fails with ErrNotSeekable for files without a block index.
+func NewSeekableReader(r io.ReaderAt, size int64) (*SeekableReader, error) {
+	h, err := readHeader(bufio.NewReader(io.NewSectionReader(r, 0, size)))
+	if err != nil {
+		return nil, err
+	}
+	if h.flags&flagIndex == 0 {
+		return nil, ErrNotSeekable
+	}
+
+	// Between the header and the index come the blocks and the end marker,
+	// the index length and the checksum trailer follow the index.
+	headerSize := int64(len(Magic) + 2 + len(binary.AppendUvarint(nil, h.blockSize)))
+	indexEnd := size - checksumSize
+	if indexEnd-8-1 < headerSize {
+		return nil, fmt.Errorf("%w: file too short for a block index", ErrCorruptData)
+	}
+	var indexLength [8]byte
+	if _, err := r.ReadAt(indexLength[:], indexEnd-8); err != nil {
+		return nil, blockError(err)
+	}
+	length := binary.BigEndian.Uint64(indexLength[:])
+	if length > uint64(indexEnd-8-1-headerSize) {
+		return nil, fmt.Errorf("%w: block index length %d out of range", ErrCorruptData, length)
+	}
+	indexStart := indexEnd - 8 - int64(length)
+
+	buf := make([]byte, indexEnd-indexStart)
+	if _, err := r.ReadAt(buf, indexStart); err != nil {
+		return nil, blockError(err)
+	}
+	index, err := readBlockIndex(bufio.NewReader(bytes.NewReader(buf)))
+	if err == io.ErrUnexpectedEOF {
+		// The whole index is in buf, running out means its entries do not
+		// match its length.
+		return nil, fmt.Errorf("%w: block index length does not match its entries", ErrCorruptData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sr := &SeekableReader{
+		r:           r,
+		blockSize:   h.blockSize,
+		lz:          h.flags&flagLZ != 0,
+		blocks:      make([]seekBlock, len(index)),
+		cachedBlock: -1,
+	}
+	// The blocks must exactly fill the space up to the end marker.
+	blocksEnd := indexStart - 1
+	frameOffset := headerSize
+	for i, entry := range index {
+		if entry.length == 0 || entry.length > h.blockSize || entry.frameLength == 0 ||
+			entry.frameLength > uint64(blocksEnd-frameOffset) {
+			return nil, fmt.Errorf("%w: invalid block index entry %d", ErrCorruptData, i)
+		}
+		sr.blocks[i] = seekBlock{blockIndexEntry: entry, offset: sr.size, frameOffset: frameOffset}
+		sr.size += int64(entry.length)
+		frameOffset += int64(entry.frameLength)
+	}
+	if frameOffset != blocksEnd {
+		return nil, fmt.Errorf("%w: block index does not cover the file", ErrCorruptData)
+	}
+	return sr, nil
+}
+
+// Size returns the length of the decompressed data.
+func (sr *SeekableReader) Size() int64 {
+	return sr.size
+}
+
+// ReadAt decompresses len(p) bytes starting at offset off of the
+// decompressed data.
+func (sr *SeekableReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("compactor: negative offset")
+	}
+
+	n := 0
+	for n < len(p) {
+		if off >= sr.size {
+			return n, io.EOF
+		}
+		i := sort.Search(len(sr.blocks), func(i int) bool {
+			return sr.blocks[i].offset+int64(sr.blocks[i].length) > off
+		})
+		data, err := sr.block(i)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], data[off-sr.blocks[i].offset:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// block returns the decoded block i. The returned slice is never modified.
+func (sr *SeekableReader) block(i int) ([]byte, error) {
+	sr.mu.Lock()
+	if sr.cachedBlock == i {
+		data := sr.cachedData
+		sr.mu.Unlock()
+		return data, nil
+	}
+	sr.mu.Unlock()
+
+	b := &sr.blocks[i]
+	frame := make([]byte, b.frameLength)
+	if n, err := sr.r.ReadAt(frame, b.frameOffset); n < len(frame) {
+		return nil, blockError(err)
+	}
+	length, decode, err := readBlockFrame(bufio.NewReader(bytes.NewReader(frame)), sr.blockSize, sr.lz)
+	if err != nil {
+		return nil, err
+	}
+	if decode == nil || length != b.length {
+		return nil, fmt.Errorf("%w: block %d does not match the block index", ErrCorruptData, i)
+	}
+	data, err := decode()
+	if err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != b.checksum {
+		return nil, ErrChecksum
+	}
+
+	sr.mu.Lock()
+	sr.cachedBlock, sr.cachedData = i, data
+	sr.mu.Unlock()
+	return data, nil
+}
+
+// Read decompresses data into p from the current position.
+func (sr *SeekableReader) Read(p []byte) (int, error) {
+	n, err := sr.ReadAt(p, sr.pos)
+	sr.pos += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the position of the next Read in the decompressed data.
+func (sr *SeekableReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += sr.pos
+	case io.SeekEnd:
+		offset += sr.size
+	default:
+		return 0, errors.New("compactor: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("compactor: negative position")
+	}
+	sr.pos = offset
+	return offset, nil
+}
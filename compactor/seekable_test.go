@@ -0,0 +1,222 @@
+package compactor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestSeekableReaderReadAt(t *testing.T) {
+	input := blockInput(10*1024 + 17)
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Blocks", opts: Options{Seekable: true, BlockSize: 1024}},
+		{name: "LZ blocks", opts: Options{Seekable: true, LZ: true, BlockSize: 1000}},
+		{name: "Default block size", opts: Options{Seekable: true}},
+	}
+	ranges := []struct {
+		off, length int64
+	}{
+		{off: 0, length: 10},
+		{off: 1000, length: 100},
+		{off: 1020, length: 10},
+		{off: 3000, length: 4096},
+		{off: int64(len(input)) - 5, length: 5},
+		{off: 0, length: int64(len(input))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := compress(t, input, tt.opts)
+			if got := decompress(t, compressed); !bytes.Equal(got, input) {
+				t.Fatalf("sequential round trip mismatch")
+			}
+
+			sr, err := NewSeekableReader(bytes.NewReader(compressed), int64(len(compressed)))
+			if err != nil {
+				t.Fatalf("NewSeekableReader() error = %v", err)
+			}
+			if sr.Size() != int64(len(input)) {
+				t.Errorf("Size() = %d, want %d", sr.Size(), len(input))
+			}
+			for _, r := range ranges {
+				got := make([]byte, r.length)
+				if n, err := sr.ReadAt(got, r.off); n != len(got) || (err != nil && err != io.EOF) {
+					t.Fatalf("ReadAt(%d, %d) = %d, %v", r.off, r.length, n, err)
+				}
+				if want := input[r.off : r.off+r.length]; !bytes.Equal(got, want) {
+					t.Errorf("ReadAt(%d, %d) mismatch", r.off, r.length)
+				}
+			}
+
+			got := make([]byte, 10)
+			if n, err := sr.ReadAt(got, int64(len(input))-3); n != 3 || err != io.EOF {
+				t.Errorf("ReadAt() past the end = %d, %v, want 3, %v", n, err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestSeekableReaderSeek(t *testing.T) {
+	input := blockInput(5000)
+	compressed := compress(t, input, Options{Seekable: true, BlockSize: 512})
+	sr, err := NewSeekableReader(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewSeekableReader() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		offset int64
+		whence int
+		want   int64
+	}{
+		{name: "Start", offset: 700, whence: io.SeekStart, want: 700},
+		{name: "Current", offset: 100, whence: io.SeekCurrent, want: 800},
+		{name: "End", offset: -10, whence: io.SeekEnd, want: 4990},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := sr.Seek(tt.offset, tt.whence)
+			if err != nil || pos != tt.want {
+				t.Fatalf("Seek(%d, %d) = %d, %v, want %d", tt.offset, tt.whence, pos, err, tt.want)
+			}
+			got := make([]byte, 10)
+			if _, err := io.ReadFull(sr, got); err != nil {
+				t.Fatalf("ReadFull() error = %v", err)
+			}
+			if !bytes.Equal(got, input[tt.want:tt.want+10]) {
+				t.Errorf("Read() after Seek(%d, %d) mismatch", tt.offset, tt.whence)
+			}
+			sr.Seek(tt.want, io.SeekStart)
+		})
+	}
+
+	if _, err := sr.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek(-1, io.SeekStart) error = nil, want an error")
+	}
+	sr.Seek(0, io.SeekStart)
+	if got, err := io.ReadAll(sr); err != nil || !bytes.Equal(got, input) {
+		t.Errorf("ReadAll() = %d bytes, %v, want %d bytes", len(got), err, len(input))
+	}
+}
+
+func TestSeekableReaderConcurrent(t *testing.T) {
+	input := blockInput(64 * 1024)
+	compressed := compress(t, input, Options{Seekable: true, BlockSize: 1024})
+	sr, err := NewSeekableReader(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewSeekableReader() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			got := make([]byte, 300)
+			for off := int64(worker * 97); off+300 <= int64(len(input)); off += 2000 {
+				if _, err := sr.ReadAt(got, off); err != nil {
+					t.Errorf("ReadAt(%d) error = %v", off, err)
+					return
+				}
+				if !bytes.Equal(got, input[off:off+300]) {
+					t.Errorf("ReadAt(%d) mismatch", off)
+					return
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
+
+func TestSeekableReaderEmpty(t *testing.T) {
+	compressed := compress(t, nil, Options{Seekable: true})
+	sr, err := NewSeekableReader(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewSeekableReader() error = %v", err)
+	}
+	if sr.Size() != 0 {
+		t.Errorf("Size() = %d, want 0", sr.Size())
+	}
+	if n, err := sr.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("Read() = %d, %v, want 0, %v", n, err, io.EOF)
+	}
+}
+
+func TestNewSeekableReaderErrors(t *testing.T) {
+	input := blockInput(4000)
+	valid := compress(t, input, Options{Seekable: true, BlockSize: 1024})
+	indexLengthAt := len(valid) - checksumSize - 8
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "No index", data: compress(t, input, Options{BlockSize: 1024}), wantErr: ErrNotSeekable},
+		{name: "Single table", data: compress(t, input, Options{}), wantErr: ErrNotSeekable},
+		{name: "Index length out of range", data: func() []byte {
+			data := bytes.Clone(valid)
+			binary.BigEndian.PutUint64(data[indexLengthAt:], uint64(len(valid)))
+			return data
+		}(), wantErr: ErrCorruptData},
+		{name: "Index length mismatch", data: func() []byte {
+			data := bytes.Clone(valid)
+			binary.BigEndian.PutUint64(data[indexLengthAt:], binary.BigEndian.Uint64(data[indexLengthAt:])-1)
+			return data
+		}(), wantErr: ErrCorruptData},
+		{name: "Truncated", data: valid[:len(valid)-1], wantErr: ErrCorruptData},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSeekableReader(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewSeekableReader() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSeekableReaderDetectsBlockCorruption(t *testing.T) {
+	input := blockInput(4000)
+	compressed := compress(t, input, Options{Seekable: true, BlockSize: 1024})
+	sr, err := NewSeekableReader(bytes.NewReader(compressed), int64(len(compressed)))
+	if err != nil {
+		t.Fatalf("NewSeekableReader() error = %v", err)
+	}
+
+	// Flip the stored checksum of the second block.
+	sr.blocks[1].checksum ^= 1
+	if _, err := sr.ReadAt(make([]byte, 10), 100); err != nil {
+		t.Errorf("ReadAt() in an intact block error = %v", err)
+	}
+	if _, err := sr.ReadAt(make([]byte, 10), 1100); !errors.Is(err, ErrChecksum) {
+		t.Errorf("ReadAt() in a corrupt block error = %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestSeekableWriterInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "Adaptive", opts: Options{Seekable: true, Adaptive: true}},
+		{name: "Frequency", opts: Options{Seekable: true, Frequency: map[byte]int{'a': 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zw := NewWriter(io.Discard, tt.opts)
+			if _, err := zw.Write([]byte("a")); !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("Write() error = %v, want %v", err, ErrInvalidOptions)
+			}
+		})
+	}
+}
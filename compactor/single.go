@@ -0,0 +1,264 @@
+package compactor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// singleTableWriter encodes the whole input with one code table. The table
+// needs the frequency of all the data, so unless it is given up front the
+// input is buffered until close. With a trained table only the length of the
+// data is needed, which the frequency gives just the same.
+type singleTableWriter struct {
+	w             io.Writer
+	frequency     compressutils.Frequency
+	maxCodeLength int
+	table         *Table
+	// autoPreset replaces the generated table by a preset when that makes
+	// the output smaller.
+	autoPreset bool
+
+	buffer    bytes.Buffer
+	codeTable *compressutils.CodeTable
+	// run is set when the input repeats a single byte, which is stored
+	// without a payload. codeTable is nil then, and for empty input.
+	run            bool
+	runByte        byte
+	bitWriter      *compressutils.BitWriter
+	originalLength uint64
+	writtenBytes   uint64
+	started        bool
+}
+
+func newSingleTableWriter(w io.Writer, frequency compressutils.Frequency, maxCodeLength int, table *Table, autoPreset bool) *singleTableWriter {
+	return &singleTableWriter{
+		w:             w,
+		frequency:     frequency,
+		maxCodeLength: maxCodeLength,
+		table:         table,
+		autoPreset:    autoPreset,
+	}
+}
+
+// start generates the code table and writes the header.
+func (sw *singleTableWriter) start(frequency compressutils.Frequency) error {
+	originalLength := uint64(0)
+	for _, count := range frequency {
+		originalLength += uint64(count)
+	}
+	if sw.table != nil {
+		return sw.startWithTable(originalLength)
+	}
+
+	huffmanCodes := compressutils.HuffmanCodeTable{}
+	if len(frequency) > 0 {
+		var err error
+		if huffmanCodes, err = generateHuffmanCodes(frequency, sw.maxCodeLength); err != nil {
+			return err
+		}
+	}
+
+	codeLengths := compressutils.GetCodeLengths(huffmanCodes)
+	if sw.autoPreset {
+		if sw.table = smallerPreset(frequency, codeLengths, originalLength); sw.table != nil {
+			return sw.startWithTable(originalLength)
+		}
+	}
+	err := writeHeader(sw.w, &header{
+		version:        FormatVersion,
+		flags:          flagChecksum,
+		codeLengths:    codeLengths,
+		originalLength: originalLength,
+	})
+	if err != nil {
+		return err
+	}
+
+	if sw.runByte, sw.run = runSymbol(codeLengths); !sw.run && len(codeLengths) > 0 {
+		if sw.codeTable, err = compressutils.NewCodeTable(huffmanCodes); err != nil {
+			return err
+		}
+	}
+	sw.bitWriter = compressutils.NewBitWriter(sw.w)
+	sw.originalLength = originalLength
+	sw.started = true
+	return nil
+}
+
+// startWithTable writes the header referencing the trained or preset table.
+func (sw *singleTableWriter) startWithTable(originalLength uint64) error {
+	h := &header{
+		version:        FormatVersion,
+		flags:          flagChecksum | flagTable,
+		originalLength: originalLength,
+		tableID:        sw.table.id,
+	}
+	if sw.table.preset != 0 {
+		h.flags = flagChecksum | flagPreset
+		h.preset = sw.table.preset
+	}
+	if err := writeHeader(sw.w, h); err != nil {
+		return err
+	}
+	sw.codeTable = sw.table.codeTable
+	sw.bitWriter = compressutils.NewBitWriter(sw.w)
+	sw.originalLength = originalLength
+	sw.started = true
+	return nil
+}
+
+// smallerPreset returns the preset that encodes data of the given frequency
+// into the smallest body, or nil when none beats codeLengths, the table
+// generated for it, stored in the header.
+func smallerPreset(frequency compressutils.Frequency, codeLengths compressutils.CodeLengthTable, originalLength uint64) *Table {
+	bodySize := func(codeLengths compressutils.CodeLengthTable, tableSize int) uint64 {
+		bits := uint64(0)
+		for symbol, count := range frequency {
+			bits += uint64(count) * uint64(codeLengths[symbol])
+		}
+		return uint64(tableSize) + (bits+7)/8
+	}
+
+	// A run has no payload, its table alone is stored.
+	generated, _ := appendCodeLengths(nil, codeLengths)
+	best := uint64(len(generated)) + 8
+	if _, run := runSymbol(codeLengths); !run {
+		best = bodySize(codeLengths, len(generated)+8)
+	}
+	var preset *Table
+	lengthSize := len(binary.AppendUvarint(nil, originalLength))
+	for _, t := range presets() {
+		if size := bodySize(t.codeLengths, 1+lengthSize); size < best {
+			best, preset = size, t
+		}
+	}
+	return preset
+}
+
+func (sw *singleTableWriter) encode(data []byte) error {
+	if sw.codeTable == nil {
+		// Nothing is written for a run, the data only has to match it.
+		for _, b := range data {
+			if !sw.run || b != sw.runByte {
+				return ErrFrequencyMatch
+			}
+		}
+		sw.writtenBytes += uint64(len(data))
+		return nil
+	}
+
+	if err := sw.codeTable.Encode(sw.bitWriter, data); err != nil {
+		return fmt.Errorf("compactor: %w", err)
+	}
+	sw.writtenBytes += uint64(len(data))
+	return nil
+}
+
+func (sw *singleTableWriter) write(p []byte) error {
+	if sw.frequency == nil {
+		sw.buffer.Write(p)
+		return nil
+	}
+
+	if !sw.started {
+		if err := sw.start(sw.frequency); err != nil {
+			return err
+		}
+	}
+	return sw.encode(p)
+}
+
+func (sw *singleTableWriter) close() error {
+	if sw.frequency == nil {
+		var err error
+		if sw.table != nil {
+			err = sw.startWithTable(uint64(sw.buffer.Len()))
+		} else {
+			err = sw.start(compressutils.GetFrequencyForBytes(sw.buffer.Bytes()))
+		}
+		if err != nil {
+			return err
+		}
+		if err := sw.encode(sw.buffer.Bytes()); err != nil {
+			return err
+		}
+		sw.buffer.Reset()
+	} else if !sw.started {
+		if err := sw.start(sw.frequency); err != nil {
+			return err
+		}
+	}
+
+	if sw.writtenBytes != sw.originalLength {
+		return ErrFrequencyMatch
+	}
+	return sw.bitWriter.Flush()
+}
+
+// singleTableReader decodes the body written by a singleTableWriter.
+type singleTableReader struct {
+	bitReader *compressutils.BitReader
+	// decoder is nil for a run of runByte, which has no payload.
+	decoder   *compressutils.Decoder
+	runByte   byte
+	remaining uint64
+}
+
+// newSingleTableReader decodes the body described by h. table is the trained
+// table of a header with flagTable, nil otherwise.
+func newSingleTableReader(r io.Reader, h *header, table *Table) (*singleTableReader, error) {
+	sr := &singleTableReader{
+		bitReader: compressutils.NewBitReader(r),
+		remaining: h.originalLength,
+	}
+	if table != nil {
+		sr.decoder = table.decoder
+		return sr, nil
+	}
+	// readHeader only accepts an empty table for empty input. Runs of
+	// version 3 have a payload, the decoder reads it like any other.
+	runByte, run := runSymbol(h.codeLengths)
+	if (run && h.version != runPayloadVersion) || len(h.codeLengths) == 0 {
+		sr.runByte = runByte
+		return sr, nil
+	}
+
+	var err error
+	if sr.decoder, err = compressutils.NewDecoder(h.codeLengths); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptHeader, err)
+	}
+	return sr, nil
+}
+
+func (sr *singleTableReader) Read(p []byte) (int, error) {
+	if sr.remaining == 0 {
+		return 0, io.EOF
+	}
+	if uint64(len(p)) > sr.remaining {
+		p = p[:sr.remaining]
+	}
+
+	if sr.decoder == nil {
+		for i := range p {
+			p[i] = sr.runByte
+		}
+		sr.remaining -= uint64(len(p))
+		return len(p), nil
+	}
+
+	n, err := sr.decoder.DecodeTo(sr.bitReader, p)
+	sr.remaining -= uint64(n)
+	if err == compressutils.ErrInvalidCode {
+		err = ErrCorruptData
+	}
+	return n, err
+}
+
+func (sr *singleTableReader) trailer() io.Reader {
+	sr.bitReader.Align()
+	return sr.bitReader
+}
@@ -0,0 +1,98 @@
+package compactor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// SymbolStats describes one byte value of the input and its code.
+type SymbolStats struct {
+	Symbol     byte `json:"symbol"`
+	Count      int  `json:"count"`
+	CodeLength int  `json:"code_length"`
+}
+
+// Stats describes how the single table format encodes an input with a given
+// byte frequency. The sizes are exact: a Writer given the same frequency and
+// maximum code length writes CompressedSize bytes.
+type Stats struct {
+	// Symbols holds every byte value that occurs, most frequent first.
+	Symbols      []SymbolStats `json:"symbols"`
+	OriginalSize int64         `json:"original_size"`
+	// Entropy is the Shannon entropy in bits per byte, the lower bound of
+	// AverageCodeLength.
+	Entropy           float64 `json:"entropy"`
+	AverageCodeLength float64 `json:"average_code_length"`
+	// PayloadBits is the length of the encoded data before padding.
+	PayloadBits int64 `json:"payload_bits"`
+	PayloadSize int64 `json:"payload_size"`
+	// HeaderSize covers the magic, version, flags, code table and original
+	// length, TrailerSize the checksum.
+	HeaderSize     int64 `json:"header_size"`
+	TrailerSize    int64 `json:"trailer_size"`
+	CompressedSize int64 `json:"compressed_size"`
+}
+
+// NewStats computes the code lengths for frequency, limited to maxCodeLength
+// bits like Options.MaxCodeLength, and the size of the output.
+func NewStats(frequency compressutils.Frequency, maxCodeLength int) (*Stats, error) {
+	if maxCodeLength < 0 || maxCodeLength > compressutils.MaxCodeLength {
+		return nil, fmt.Errorf("%w: maximum code length %d is not between 1 and %d", ErrInvalidOptions, maxCodeLength, compressutils.MaxCodeLength)
+	}
+	if maxCodeLength == 0 {
+		maxCodeLength = compressutils.DefaultMaxCodeLength
+	}
+
+	codeLengths := compressutils.CodeLengthTable{}
+	if len(frequency) > 0 {
+		huffmanCodes, err := generateHuffmanCodes(frequency, maxCodeLength)
+		if err != nil {
+			return nil, err
+		}
+		codeLengths = compressutils.GetCodeLengths(huffmanCodes)
+	}
+
+	stats := &Stats{
+		Symbols:     make([]SymbolStats, 0, len(frequency)),
+		Entropy:     compressutils.Entropy(frequency),
+		TrailerSize: checksumSize,
+	}
+	for symbol, count := range frequency {
+		stats.Symbols = append(stats.Symbols, SymbolStats{Symbol: symbol, Count: count, CodeLength: codeLengths[symbol]})
+		stats.OriginalSize += int64(count)
+		stats.PayloadBits += int64(count) * int64(codeLengths[symbol])
+	}
+	sort.Slice(stats.Symbols, func(i, j int) bool {
+		a, b := stats.Symbols[i], stats.Symbols[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Symbol < b.Symbol
+	})
+
+	if stats.OriginalSize > 0 {
+		stats.AverageCodeLength = float64(stats.PayloadBits) / float64(stats.OriginalSize)
+	}
+	// A run of one byte value is stored without a payload.
+	if _, run := runSymbol(codeLengths); run {
+		stats.PayloadBits = 0
+	}
+	stats.PayloadSize = (stats.PayloadBits + 7) / 8
+
+	var buf bytes.Buffer
+	err := writeHeader(&buf, &header{
+		version:        FormatVersion,
+		flags:          flagChecksum,
+		codeLengths:    codeLengths,
+		originalLength: uint64(stats.OriginalSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.HeaderSize = int64(buf.Len())
+	stats.CompressedSize = stats.HeaderSize + stats.PayloadSize + stats.TrailerSize
+	return stats, nil
+}
@@ -0,0 +1,84 @@
+package compactor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+func TestStatsMatchWriter(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         []byte
+		maxCodeLength int
+	}{
+		{name: "Empty", input: nil},
+		{name: "Single byte run", input: []byte(strings.Repeat("z", 1000))},
+		{name: "Sentence", input: []byte("the quick brown fox jumps over the lazy dog")},
+		{name: "Binary", input: blockInput(20000)},
+		{name: "Limited code length", input: fibonacciInput(20), maxCodeLength: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frequency := compressutils.GetFrequencyForBytes(tt.input)
+			stats, err := NewStats(frequency, tt.maxCodeLength)
+			if err != nil {
+				t.Fatalf("NewStats() error = %v", err)
+			}
+
+			compressed := compress(t, tt.input, Options{MaxCodeLength: tt.maxCodeLength})
+			if stats.CompressedSize != int64(len(compressed)) {
+				t.Errorf("CompressedSize = %d, want %d", stats.CompressedSize, len(compressed))
+			}
+			if stats.OriginalSize != int64(len(tt.input)) {
+				t.Errorf("OriginalSize = %d, want %d", stats.OriginalSize, len(tt.input))
+			}
+			if stats.HeaderSize+stats.PayloadSize+stats.TrailerSize != stats.CompressedSize {
+				t.Errorf("HeaderSize + PayloadSize + TrailerSize = %d, want %d", stats.HeaderSize+stats.PayloadSize+stats.TrailerSize, stats.CompressedSize)
+			}
+			if len(stats.Symbols) != len(frequency) {
+				t.Errorf("len(Symbols) = %d, want %d", len(stats.Symbols), len(frequency))
+			}
+			if stats.AverageCodeLength < stats.Entropy-1e-9 {
+				t.Errorf("AverageCodeLength = %v is below the entropy %v", stats.AverageCodeLength, stats.Entropy)
+			}
+		})
+	}
+}
+
+func TestStatsSymbolOrder(t *testing.T) {
+	stats, err := NewStats(compressutils.Frequency{'a': 1, 'b': 8, 'c': 4, 'd': 4}, 0)
+	if err != nil {
+		t.Fatalf("NewStats() error = %v", err)
+	}
+
+	expected := []SymbolStats{
+		{Symbol: 'b', Count: 8, CodeLength: 1},
+		{Symbol: 'c', Count: 4},
+		{Symbol: 'd', Count: 4},
+		{Symbol: 'a', Count: 1, CodeLength: 3},
+	}
+	payloadBits := int64(0)
+	for i, want := range expected {
+		got := stats.Symbols[i]
+		if got.Symbol != want.Symbol || got.Count != want.Count || (want.CodeLength != 0 && got.CodeLength != want.CodeLength) {
+			t.Errorf("Symbols[%d] = %+v, want %+v", i, got, want)
+		}
+		payloadBits += int64(got.Count * got.CodeLength)
+	}
+	if stats.PayloadBits != payloadBits {
+		t.Errorf("PayloadBits = %d, want %d", stats.PayloadBits, payloadBits)
+	}
+}
+
+func TestStatsInvalid(t *testing.T) {
+	if _, err := NewStats(compressutils.Frequency{'a': 1}, -1); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("NewStats() error = %v, want %v", err, ErrInvalidOptions)
+	}
+	if _, err := NewStats(compressutils.Frequency{'a': 1, 'b': 1, 'c': 1}, 1); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("NewStats() error = %v, want %v", err, ErrInvalidOptions)
+	}
+}
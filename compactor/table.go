@@ -0,0 +1,184 @@
+package compactor
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// Table file layout, as written by Table.WriteTo:
+//
+//	magic           4 bytes "CPTT"
+//	version         1 byte
+//	code table      see appendCodeLengths, with a code for all 256 bytes
+//
+// The ID of a table is the start of the SHA-256 of its code table. A file
+// compressed with Options.Table stores only that ID, so a table can be
+// shared by any number of small files that would otherwise each carry
+// their own code table.
+const (
+	TableMagic   = "CPTT"
+	TableVersion = 1
+
+	// TableIDSize is the length of a TableID.
+	TableIDSize = 8
+)
+
+var (
+	ErrNotTable     = errors.New("compactor: not a code table file (bad magic)")
+	ErrUnknownTable = errors.New("compactor: the file was compressed with a table that was not given")
+)
+
+// TableID identifies a Table in the header of the files compressed with it.
+type TableID [TableIDSize]byte
+
+func (id TableID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Table is a fixed Huffman code for a kind of content, trained on a corpus
+// with TrainTable or built in, see Presets. It is immutable and can be
+// shared by any number of Writers and Readers.
+type Table struct {
+	id TableID
+	// name and preset are set for the built-in tables of preset.go.
+	name        string
+	preset      uint8
+	codeLengths compressutils.CodeLengthTable
+	codeTable   *compressutils.CodeTable
+	decoder     *compressutils.Decoder
+}
+
+// newTable builds the encoder and decoder for codeLengths, which must hold a
+// code for every byte value.
+func newTable(codeLengths compressutils.CodeLengthTable) (*Table, error) {
+	if len(codeLengths) != 256 {
+		return nil, fmt.Errorf("compactor: a table needs a code for all 256 bytes, not %d", len(codeLengths))
+	}
+	serialized, err := appendCodeLengths(nil, codeLengths)
+	if err != nil {
+		return nil, err
+	}
+	huffmanCodes, err := compressutils.GenerateCanonicalHuffmanCodes(codeLengths)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Table{codeLengths: codeLengths}
+	sum := sha256.Sum256(serialized)
+	copy(t.id[:], sum[:])
+	if t.codeTable, err = compressutils.NewCodeTable(huffmanCodes); err != nil {
+		return nil, err
+	}
+	if t.decoder, err = compressutils.NewDecoder(codeLengths); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// TrainTable builds a table from the byte frequency of a corpus, usually
+// the sum of the frequency of every file in it. Every byte that does not
+// occur is counted once, so the table can encode any input, only with a
+// long code for bytes the corpus lacks. The codes are limited to
+// maxCodeLength bits like Options.MaxCodeLength.
+func TrainTable(frequency compressutils.Frequency, maxCodeLength int) (*Table, error) {
+	if len(frequency) == 0 {
+		return nil, ErrEmptyInput
+	}
+	if maxCodeLength < 0 || maxCodeLength > compressutils.MaxCodeLength {
+		return nil, fmt.Errorf("%w: maximum code length %d is not between 1 and %d", ErrInvalidOptions, maxCodeLength, compressutils.MaxCodeLength)
+	}
+	if maxCodeLength == 0 {
+		maxCodeLength = compressutils.DefaultMaxCodeLength
+	}
+
+	smoothed := make(compressutils.Frequency, 256)
+	for b := 0; b < 256; b++ {
+		smoothed[byte(b)] = frequency[byte(b)] + 1
+	}
+	huffmanCodes, err := generateHuffmanCodes(smoothed, maxCodeLength)
+	if err != nil {
+		return nil, err
+	}
+	return newTable(compressutils.GetCodeLengths(huffmanCodes))
+}
+
+// ID returns the ID files compressed with t store.
+func (t *Table) ID() TableID {
+	return t.id
+}
+
+// Name returns the name of a built-in table, see Presets, and "" for a
+// trained one.
+func (t *Table) Name() string {
+	return t.name
+}
+
+// CodeLengths returns the length in bits of the code of every byte value.
+func (t *Table) CodeLengths() compressutils.CodeLengthTable {
+	codeLengths := make(compressutils.CodeLengthTable, len(t.codeLengths))
+	for symbol, length := range t.codeLengths {
+		codeLengths[symbol] = length
+	}
+	return codeLengths
+}
+
+// WriteTo writes the table file to w.
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	buf := append([]byte(TableMagic), TableVersion)
+	buf, err := appendCodeLengths(buf, t.codeLengths)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// ReadTable reads a table file written by WriteTo.
+func ReadTable(r io.Reader) (*Table, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(TableMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != TableMagic {
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		return nil, ErrNotTable
+	}
+	version, err := br.ReadByte()
+	if err != nil {
+		return nil, headerError(err)
+	}
+	if version != TableVersion {
+		return nil, fmt.Errorf("%w %d of a table (this build reads version %d)", ErrUnsupportedVersion, version, TableVersion)
+	}
+
+	codeLengths, err := readCodeLengths(br)
+	if err != nil {
+		return nil, headerError(err)
+	}
+	if _, err := br.ReadByte(); err == nil {
+		return nil, fmt.Errorf("%w: data after the table", ErrCorruptHeader)
+	} else if err != io.EOF {
+		return nil, err
+	}
+	t, err := newTable(codeLengths)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptHeader, err)
+	}
+	return t, nil
+}
+
+// findTable returns the table with the given ID.
+func findTable(id TableID, tables []*Table) (*Table, error) {
+	for _, t := range tables {
+		if t.id == id {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownTable, id)
+}
@@ -0,0 +1,206 @@
+package compactor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+func trainTable(t *testing.T, corpus []byte) *Table {
+	t.Helper()
+
+	table, err := TrainTable(compressutils.GetFrequencyForBytes(corpus), 0)
+	if err != nil {
+		t.Fatalf("TrainTable() error = %v", err)
+	}
+	return table
+}
+
+func TestTableRoundTrip(t *testing.T) {
+	table := trainTable(t, jsonLogs(500))
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: nil},
+		{name: "One line", input: jsonLogs(1)},
+		{name: "Single repeated byte", input: bytes.Repeat([]byte("x"), 100)},
+		{name: "Bytes not in the corpus", input: []byte("\x00\xff\x80 naïve")},
+		{name: "Binary", input: blockInput(5000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := compress(t, tt.input, Options{Table: table})
+
+			zr, err := NewReaderWithTables(bytes.NewReader(compressed), trainTable(t, []byte("other")), table)
+			if err != nil {
+				t.Fatalf("NewReaderWithTables() error = %v", err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.input) {
+				t.Errorf("round trip of %d bytes mismatch", len(tt.input))
+			}
+		})
+	}
+}
+
+func TestTableSavesTheCodeTable(t *testing.T) {
+	table := trainTable(t, jsonLogs(500))
+	input := jsonLogs(1)
+
+	withTable := compress(t, input, Options{Table: table})
+	own := compress(t, input, Options{})
+	// The code table of one line alone is about as large as its payload.
+	if len(withTable) >= len(own) {
+		t.Errorf("output with a table is %d bytes, with its own %d bytes, want it smaller", len(withTable), len(own))
+	}
+
+	// With the frequency the data is streamed, the result is the same.
+	streamed := compress(t, input, Options{Table: table, Frequency: compressutils.GetFrequencyForBytes(input)})
+	if !bytes.Equal(streamed, withTable) {
+		t.Error("Table with Frequency writes different output than without")
+	}
+}
+
+func TestTableReaderErrors(t *testing.T) {
+	table := trainTable(t, jsonLogs(100))
+	compressed := compress(t, jsonLogs(3), Options{Table: table})
+
+	if _, err := NewReader(bytes.NewReader(compressed)); !errors.Is(err, ErrUnknownTable) {
+		t.Errorf("NewReader() error = %v, want %v", err, ErrUnknownTable)
+	}
+	if _, err := NewReaderWithTables(bytes.NewReader(compressed), trainTable(t, []byte("other"))); !errors.Is(err, ErrUnknownTable) {
+		t.Errorf("NewReaderWithTables() with another table error = %v, want %v", err, ErrUnknownTable)
+	}
+
+	corrupt := bytes.Clone(compressed)
+	corrupt[len(corrupt)-5] ^= 0xff
+	zr, err := NewReaderWithTables(bytes.NewReader(corrupt), table)
+	if err != nil {
+		t.Fatalf("NewReaderWithTables() error = %v", err)
+	}
+	if _, err := io.ReadAll(zr); err == nil {
+		t.Error("ReadAll() of corrupt data error = nil, want an error")
+	}
+}
+
+func TestTableInvalidOptions(t *testing.T) {
+	table := trainTable(t, []byte("abc"))
+	for _, opts := range []Options{
+		{Table: table, BlockSize: 1024},
+		{Table: table, Adaptive: true},
+		{Table: table, LZ: true},
+		{Table: table, Seekable: true},
+		{Table: table, MaxCodeLength: 12},
+	} {
+		zw := NewWriter(io.Discard, opts)
+		if err := zw.Close(); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("Close() with %+v error = %v, want %v", opts, err, ErrInvalidOptions)
+		}
+	}
+}
+
+func TestTrainTable(t *testing.T) {
+	table := trainTable(t, jsonLogs(100))
+	codeLengths := table.CodeLengths()
+	if len(codeLengths) != 256 {
+		t.Fatalf("CodeLengths() has %d codes, want 256", len(codeLengths))
+	}
+	if codeLengths['"'] >= codeLengths[0] {
+		t.Errorf("code length of '\"' = %d, of 0x00 = %d, want the frequent byte shorter", codeLengths['"'], codeLengths[0])
+	}
+	for symbol, length := range codeLengths {
+		if length > compressutils.DefaultMaxCodeLength {
+			t.Errorf("code length of 0x%02x = %d, want at most %d", symbol, length, compressutils.DefaultMaxCodeLength)
+		}
+	}
+
+	if again := trainTable(t, jsonLogs(100)); again.ID() != table.ID() {
+		t.Errorf("ID() = %s for the same corpus, want %s", again.ID(), table.ID())
+	}
+	if other := trainTable(t, blockInput(1000)); other.ID() == table.ID() {
+		t.Error("ID() is the same for different tables")
+	}
+
+	if _, err := TrainTable(nil, 0); !errors.Is(err, ErrEmptyInput) {
+		t.Errorf("TrainTable() of nothing error = %v, want %v", err, ErrEmptyInput)
+	}
+	// 256 codes do not fit into 7 bits.
+	if _, err := TrainTable(compressutils.Frequency{'a': 1}, 7); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("TrainTable() with 7 bits error = %v, want %v", err, ErrInvalidOptions)
+	}
+}
+
+func TestTableFile(t *testing.T) {
+	table := trainTable(t, jsonLogs(100))
+	var buf bytes.Buffer
+	if _, err := table.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	file := buf.Bytes()
+
+	got, err := ReadTable(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("ReadTable() error = %v", err)
+	}
+	if got.ID() != table.ID() {
+		t.Errorf("ReadTable() ID = %s, want %s", got.ID(), table.ID())
+	}
+
+	tests := []struct {
+		name     string
+		input    []byte
+		expected error
+	}{
+		{name: "Empty", input: nil, expected: ErrNotTable},
+		{name: "Compressed file", input: compress(t, []byte("abc"), Options{}), expected: ErrNotTable},
+		{name: "Unknown version", input: append([]byte(TableMagic), TableVersion+1), expected: ErrUnsupportedVersion},
+		{name: "Truncated", input: file[:len(file)-3], expected: ErrCorruptHeader},
+		{name: "Trailing data", input: append(bytes.Clone(file), 0), expected: ErrCorruptHeader},
+		{name: "Incomplete table", input: append([]byte(TableMagic), TableVersion, 2, 'a', 1, 'b', 1), expected: ErrCorruptHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadTable(bytes.NewReader(tt.input)); !errors.Is(err, tt.expected) {
+				t.Errorf("ReadTable() error = %v, want %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTableArchive(t *testing.T) {
+	table := trainTable(t, []byte(strings.Repeat("GET /index.html 200\n", 10)))
+	archive := writeArchive(t, archiveFiles, Options{Table: table})
+
+	ar, err := OpenArchive(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("OpenArchive() error = %v", err)
+	}
+	entry := &ar.Entries[1]
+	if _, err := ar.Open(entry); !errors.Is(err, ErrUnknownTable) {
+		t.Errorf("Open() without Tables error = %v, want %v", err, ErrUnknownTable)
+	}
+
+	ar.Tables = []*Table{table}
+	zr, err := ar.Open(entry)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if string(got) != archiveFiles[1].content {
+		t.Errorf("Open(%q) = %d bytes, want %d", entry.Path, len(got), len(archiveFiles[1].content))
+	}
+}
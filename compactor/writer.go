@@ -0,0 +1,213 @@
+package compactor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+var (
+	ErrClosed         = errors.New("compactor: writer is closed")
+	ErrEmptyInput     = errors.New("compactor: nothing to compress")
+	ErrFrequencyMatch = errors.New("compactor: input does not match Options.Frequency")
+	ErrInvalidOptions = errors.New("compactor: invalid options")
+)
+
+// Options configures a Writer.
+type Options struct {
+	// Frequency is the byte frequency of the complete input. When it is
+	// set the header is written straight away and the encoded data is
+	// streamed to the underlying writer, otherwise the input is buffered in
+	// memory until Close. It cannot be combined with BlockSize.
+	Frequency compressutils.Frequency
+
+	// BlockSize switches to block mode: the input is split into blocks of
+	// this many bytes, each with its own code table, which are compressed
+	// concurrently and only ever need one block per worker in memory. Zero
+	// keeps a single code table for the whole input.
+	BlockSize int
+
+	// Concurrency is how many blocks are compressed at the same time in
+	// block mode. It defaults to runtime.GOMAXPROCS(0).
+	Concurrency int
+
+	// Adaptive selects adaptive Huffman coding: the code tree is updated
+	// after every byte on both sides, so the input is read once, nothing is
+	// buffered and no table is stored. It is slower than the static modes
+	// and cannot be combined with Frequency or BlockSize.
+	Adaptive bool
+
+	// LZ runs every block through an LZ77 match finder before Huffman
+	// coding, which shrinks repetitive data such as logs or JSON far more
+	// than Huffman coding alone. It implies block mode, with
+	// DefaultBlockSize unless BlockSize is set, and cannot be combined with
+	// Frequency or Adaptive.
+	LZ bool
+
+	// MaxCodeLength limits the Huffman codes of the static modes to this
+	// many bits, computed with the package-merge algorithm when the Huffman
+	// tree is deeper. Zero selects compressutils.DefaultMaxCodeLength. Inputs
+	// with more distinct bytes than codes of that length can tell apart
+	// fail. It cannot be combined with Adaptive.
+	MaxCodeLength int
+
+	// Seekable appends an index of the blocks to the output, which lets a
+	// SeekableReader decode any byte range by reading only the blocks that
+	// hold it. It implies block mode, with DefaultBlockSize unless BlockSize
+	// is set, and cannot be combined with Frequency or Adaptive.
+	Seekable bool
+
+	// Table encodes the input with a table trained on similar data, see
+	// TrainTable, instead of one built for it. The output only references
+	// the table by its ID, which for small files saves more than the
+	// trained code loses, and the Reader needs the same table. Like
+	// the default mode it buffers the input unless Frequency is set. It
+	// cannot be combined with BlockSize, Adaptive, LZ, Seekable or
+	// MaxCodeLength.
+	Table *Table
+
+	// AutoPreset compares the code table built for the input with every
+	// built-in table, see Presets, and encodes with whichever gives the
+	// smallest output. A preset is stored as a one byte ID, so tiny inputs
+	// of a common kind of content go without any table overhead. It has the
+	// same restrictions as Table and cannot be combined with it.
+	AutoPreset bool
+}
+
+// bodyWriter encodes the container body for one mode: the header and the
+// encoded data, up to but excluding the checksum trailer.
+type bodyWriter interface {
+	write(p []byte) error
+	close() error
+}
+
+// Writer is an io.WriteCloser that Huffman encodes everything written to it.
+// Close must be called to flush the final byte and padding.
+//
+// All encoding state lives in the Writer, so any number of Writers can run in
+// parallel goroutines. A single Writer is not safe for concurrent use.
+type Writer struct {
+	w    io.Writer
+	opts Options
+
+	body     bodyWriter
+	checksum hash.Hash32
+
+	closed bool
+	err    error
+}
+
+// NewWriter returns a Writer that writes compressed data to w.
+func NewWriter(w io.Writer, opts Options) *Writer {
+	return &Writer{
+		w:        w,
+		opts:     opts,
+		checksum: crc32.NewIEEE(),
+	}
+}
+
+func generateHuffmanCodes(frequency compressutils.Frequency, maxCodeLength int) (compressutils.HuffmanCodeTable, error) {
+	if len(frequency) == 0 {
+		return nil, ErrEmptyInput
+	}
+	codeLengths, err := compressutils.CodeLengthsForFrequency(frequency, maxCodeLength)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+	}
+	// Only the code lengths are taken from the tree, the codes themselves are
+	// canonical so the decoder can rebuild them from the lengths.
+	return compressutils.GenerateCanonicalHuffmanCodes(codeLengths)
+}
+
+// init picks the body writer for the configured mode.
+func (z *Writer) init() error {
+	if z.body != nil {
+		return nil
+	}
+
+	maxCodeLength := z.opts.MaxCodeLength
+	if maxCodeLength == 0 {
+		maxCodeLength = compressutils.DefaultMaxCodeLength
+	}
+
+	switch opts := z.opts; {
+	case opts.MaxCodeLength < 0 || opts.MaxCodeLength > compressutils.MaxCodeLength:
+		return fmt.Errorf("%w: maximum code length %d is not between 1 and %d", ErrInvalidOptions, opts.MaxCodeLength, compressutils.MaxCodeLength)
+	case opts.Adaptive && opts.MaxCodeLength != 0:
+		return fmt.Errorf("%w: MaxCodeLength cannot be used with Adaptive", ErrInvalidOptions)
+	case opts.BlockSize < 0 || opts.BlockSize > MaxBlockSize:
+		return fmt.Errorf("%w: block size %d is not between 1 and %d", ErrInvalidOptions, opts.BlockSize, MaxBlockSize)
+	case opts.BlockSize > 0 && opts.Frequency != nil:
+		return fmt.Errorf("%w: Frequency cannot be used with BlockSize", ErrInvalidOptions)
+	case (opts.Table != nil || opts.AutoPreset) && (opts.BlockSize > 0 || opts.Adaptive || opts.LZ || opts.Seekable || opts.MaxCodeLength != 0):
+		return fmt.Errorf("%w: Table and AutoPreset cannot be used with BlockSize, Adaptive, LZ, Seekable or MaxCodeLength", ErrInvalidOptions)
+	case opts.Table != nil && opts.AutoPreset:
+		return fmt.Errorf("%w: Table cannot be used with AutoPreset", ErrInvalidOptions)
+	case opts.LZ && (opts.Adaptive || opts.Frequency != nil):
+		return fmt.Errorf("%w: LZ cannot be used with Frequency or Adaptive", ErrInvalidOptions)
+	case opts.Seekable && (opts.Adaptive || opts.Frequency != nil):
+		return fmt.Errorf("%w: Seekable cannot be used with Frequency or Adaptive", ErrInvalidOptions)
+	case opts.LZ || opts.Seekable:
+		blockSize := opts.BlockSize
+		if blockSize == 0 {
+			blockSize = DefaultBlockSize
+		}
+		z.body = newBlockWriter(z.w, blockSize, opts.Concurrency, maxCodeLength, opts.LZ, opts.Seekable)
+	case opts.Adaptive && (opts.BlockSize > 0 || opts.Frequency != nil):
+		return fmt.Errorf("%w: Adaptive cannot be used with Frequency or BlockSize", ErrInvalidOptions)
+	case opts.Adaptive:
+		z.body = newAdaptiveWriter(z.w)
+	case opts.BlockSize > 0:
+		z.body = newBlockWriter(z.w, opts.BlockSize, opts.Concurrency, maxCodeLength, false, false)
+	default:
+		z.body = newSingleTableWriter(z.w, opts.Frequency, maxCodeLength, opts.Table, opts.AutoPreset)
+	}
+	return nil
+}
+
+// Write compresses p. Depending on the mode the data may only be buffered
+// and the actual encoding happens in a later Write or in Close.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, ErrClosed
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+	if z.err = z.init(); z.err != nil {
+		return 0, z.err
+	}
+
+	if z.err = z.body.write(p); z.err != nil {
+		return 0, z.err
+	}
+	z.checksum.Write(p)
+	return len(p), nil
+}
+
+// Close flushes any pending data, including the zero padding of the last
+// byte, and writes the checksum trailer. It does not close the underlying
+// writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	z.closed = true
+	if z.err != nil {
+		return z.err
+	}
+	if z.err = z.init(); z.err != nil {
+		return z.err
+	}
+
+	if z.err = z.body.close(); z.err != nil {
+		return z.err
+	}
+	_, z.err = z.w.Write(binary.BigEndian.AppendUint32(nil, z.checksum.Sum32()))
+	return z.err
+}
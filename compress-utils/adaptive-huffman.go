@@ -0,0 +1,219 @@
+package compressutils
+
+import "io"
+
+const (
+	// adaptiveEndSymbol follows the byte values in the adaptive alphabet and
+	// marks the end of the stream.
+	adaptiveEndSymbol = 256
+	adaptiveSymbols   = 257
+	// adaptiveSymbolBits is the width of a symbol sent the first time it
+	// occurs.
+	adaptiveSymbolBits = 9
+	// adaptiveMaxNodes counts a leaf for every symbol plus the NYT node and
+	// the internal nodes joining them.
+	adaptiveMaxNodes = 2*adaptiveSymbols + 1
+	noNode           = -1
+)
+
+type adaptiveNode struct {
+	weight int
+	parent int
+	left   int
+	right  int
+	// symbol is the symbol of a leaf, noNode for internal nodes and the NYT
+	// node.
+	symbol int
+}
+
+// AdaptiveCoder implements adaptive Huffman coding with the FGK algorithm.
+// Encoder and decoder start from the same tree that only holds the NYT ("not
+// yet transmitted") node and update it after every symbol, so no code table
+// or frequency pass is needed and the input is read exactly once.
+//
+// A symbol that has not occurred before is sent as the code of the NYT node
+// followed by its 9 bit value. The extra ninth bit makes room for the end of
+// stream symbol.
+//
+// An AdaptiveCoder either encodes or decodes a single stream.
+type AdaptiveCoder struct {
+	// nodes is indexed by the node number of the sibling property: weights
+	// never decrease with the number and the root has the highest one.
+	nodes []adaptiveNode
+	leaf  [adaptiveSymbols]int
+	nyt   int
+
+	path []uint8
+	done bool
+}
+
+func NewAdaptiveCoder() *AdaptiveCoder {
+	coder := &AdaptiveCoder{
+		nodes: make([]adaptiveNode, adaptiveMaxNodes),
+		nyt:   adaptiveMaxNodes - 1,
+	}
+	for i := range coder.leaf {
+		coder.leaf[i] = noNode
+	}
+	coder.nodes[coder.nyt] = adaptiveNode{parent: noNode, left: noNode, right: noNode, symbol: noNode}
+	return coder
+}
+
+func (coder *AdaptiveCoder) root() int {
+	return len(coder.nodes) - 1
+}
+
+// writeCode writes the code of node, which is the path from the root.
+func (coder *AdaptiveCoder) writeCode(bw *BitWriter, node int) {
+	coder.path = coder.path[:0]
+	for node != coder.root() {
+		parent := coder.nodes[node].parent
+		bit := uint8(0)
+		if coder.nodes[parent].right == node {
+			bit = 1
+		}
+		coder.path = append(coder.path, bit)
+		node = parent
+	}
+
+	// The path was collected leaf first, write it root first in chunks that
+	// fit the BitWriter.
+	var bits uint64
+	var n uint
+	for i := len(coder.path) - 1; i >= 0; i-- {
+		bits = bits<<1 | uint64(coder.path[i])
+		n++
+		if n == 56 {
+			bw.WriteBits(bits, n)
+			bits, n = 0, 0
+		}
+	}
+	bw.WriteBits(bits, n)
+}
+
+func (coder *AdaptiveCoder) encodeSymbol(bw *BitWriter, symbol int) {
+	if node := coder.leaf[symbol]; node != noNode {
+		coder.writeCode(bw, node)
+	} else {
+		coder.writeCode(bw, coder.nyt)
+		bw.WriteBits(uint64(symbol), adaptiveSymbolBits)
+	}
+	coder.update(symbol)
+}
+
+// Encode writes the codes of all bytes of data, updating the tree after each
+// one.
+func (coder *AdaptiveCoder) Encode(bw *BitWriter, data []byte) error {
+	for _, b := range data {
+		coder.encodeSymbol(bw, int(b))
+	}
+	return bw.Err()
+}
+
+// EncodeEnd writes the end of stream symbol. It does not flush bw.
+func (coder *AdaptiveCoder) EncodeEnd(bw *BitWriter) error {
+	coder.encodeSymbol(bw, adaptiveEndSymbol)
+	return bw.Err()
+}
+
+func (coder *AdaptiveCoder) decodeSymbol(br *BitReader) (int, error) {
+	node := coder.root()
+	for coder.nodes[node].left != noNode {
+		bit, err := br.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit == 0 {
+			node = coder.nodes[node].left
+		} else {
+			node = coder.nodes[node].right
+		}
+	}
+
+	symbol := coder.nodes[node].symbol
+	if node == coder.nyt {
+		value, err := br.ReadBits(adaptiveSymbolBits)
+		if err != nil {
+			return 0, err
+		}
+		symbol = int(value)
+		if symbol >= adaptiveSymbols || coder.leaf[symbol] != noNode {
+			// A symbol is only sent in full the first time it occurs.
+			return 0, ErrInvalidCode
+		}
+	}
+	coder.update(symbol)
+	return symbol, nil
+}
+
+// DecodeTo fills dst with decoded bytes and returns how many it decoded. Once
+// the end of stream symbol is read it returns io.EOF.
+func (coder *AdaptiveCoder) DecodeTo(br *BitReader, dst []byte) (int, error) {
+	if coder.done {
+		return 0, io.EOF
+	}
+	for i := range dst {
+		symbol, err := coder.decodeSymbol(br)
+		if err != nil {
+			return i, err
+		}
+		if symbol == adaptiveEndSymbol {
+			coder.done = true
+			return i, io.EOF
+		}
+		dst[i] = byte(symbol)
+	}
+	return len(dst), nil
+}
+
+// update adds one occurrence of symbol to the tree. A new symbol splits the
+// NYT node into a new NYT node and the leaf of the symbol. Then, from the leaf
+// up to the root, every node is swapped with the highest numbered node of its
+// weight before its weight is incremented, which keeps the sibling property.
+func (coder *AdaptiveCoder) update(symbol int) {
+	node := coder.leaf[symbol]
+	if node == noNode {
+		parent := coder.nyt
+		coder.nyt = parent - 2
+		node = parent - 1
+
+		coder.nodes[coder.nyt] = adaptiveNode{parent: parent, left: noNode, right: noNode, symbol: noNode}
+		coder.nodes[node] = adaptiveNode{parent: parent, left: noNode, right: noNode, symbol: symbol}
+		coder.nodes[parent].left = coder.nyt
+		coder.nodes[parent].right = node
+		coder.leaf[symbol] = node
+	}
+
+	for node != coder.root() {
+		leader := node
+		weight := coder.nodes[node].weight
+		for leader+1 < coder.root() && coder.nodes[leader+1].weight == weight {
+			leader++
+		}
+		if leader != node && leader != coder.nodes[node].parent {
+			coder.swap(node, leader)
+			node = leader
+		}
+		coder.nodes[node].weight++
+		node = coder.nodes[node].parent
+	}
+	coder.nodes[node].weight++
+}
+
+// swap exchanges the subtrees at node numbers a and b. The parent links stay
+// with the numbers, everything else moves. The NYT node weighs nothing and is
+// never swapped.
+func (coder *AdaptiveCoder) swap(a, b int) {
+	nodes := coder.nodes
+	nodes[a].parent, nodes[b].parent = nodes[b].parent, nodes[a].parent
+	nodes[a], nodes[b] = nodes[b], nodes[a]
+
+	for _, i := range [2]int{a, b} {
+		if nodes[i].left != noNode {
+			nodes[nodes[i].left].parent = i
+			nodes[nodes[i].right].parent = i
+		} else {
+			coder.leaf[nodes[i].symbol] = i
+		}
+	}
+}
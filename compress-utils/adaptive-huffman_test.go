@@ -0,0 +1,141 @@
+package compressutils
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func adaptiveEncode(t testing.TB, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf)
+	coder := NewAdaptiveCoder()
+	if err := coder.Encode(bw, data); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	if err := coder.EncodeEnd(bw); err != nil {
+		t.Fatalf("EncodeEnd() error = %v", err)
+	}
+	if err := bw.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func adaptiveDecode(encoded []byte, size int) ([]byte, error) {
+	br := NewBitReader(bytes.NewReader(encoded))
+	coder := NewAdaptiveCoder()
+	decoded := make([]byte, size+1)
+	n, err := coder.DecodeTo(br, decoded)
+	return decoded[:n], err
+}
+
+// checkSiblingProperty verifies the invariant FGK maintains: every internal
+// node weighs as much as its children and weights never decrease with the
+// node number.
+func checkSiblingProperty(t *testing.T, coder *AdaptiveCoder) {
+	t.Helper()
+
+	for i := coder.nyt; i <= coder.root(); i++ {
+		node := coder.nodes[i]
+		if i > coder.nyt && node.weight < coder.nodes[i-1].weight {
+			t.Fatalf("node %d weighs %d, less than node %d with %d", i, node.weight, i-1, coder.nodes[i-1].weight)
+		}
+		if node.left != noNode {
+			if sum := coder.nodes[node.left].weight + coder.nodes[node.right].weight; node.weight != sum {
+				t.Fatalf("node %d weighs %d, its children %d", i, node.weight, sum)
+			}
+		}
+	}
+}
+
+func TestAdaptiveRoundTrip(t *testing.T) {
+	allBytes := make([]byte, 256*3)
+	for i := range allBytes {
+		allBytes[i] = byte(i)
+	}
+	random := make([]byte, 20000)
+	rand.New(rand.NewSource(1)).Read(random)
+	for i := range random[:15000] {
+		random[i] %= 7
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: nil},
+		{name: "Single byte", input: []byte("a")},
+		{name: "One symbol repeated", input: bytes.Repeat([]byte("z"), 1000)},
+		{name: "Sentence", input: []byte("the quick brown fox jumps over the lazy dog")},
+		{name: "All byte values", input: allBytes},
+		{name: "Skewed random", input: random},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := adaptiveDecode(adaptiveEncode(t, tt.input), len(tt.input))
+			if err != io.EOF {
+				t.Fatalf("DecodeTo() error = %v, want %v", err, io.EOF)
+			}
+			if !bytes.Equal(decoded, tt.input) {
+				t.Errorf("round trip = %q, want %q", decoded, tt.input)
+			}
+		})
+	}
+}
+
+func TestAdaptiveSiblingProperty(t *testing.T) {
+	coder := NewAdaptiveCoder()
+	bw := NewBitWriter(io.Discard)
+	random := rand.New(rand.NewSource(2))
+	for i := 0; i < 5000; i++ {
+		// Squaring skews the distribution so the tree keeps changing shape.
+		coder.Encode(bw, []byte{byte(random.Intn(16) * random.Intn(16))})
+		checkSiblingProperty(t, coder)
+	}
+}
+
+func TestAdaptiveCompresses(t *testing.T) {
+	input := bytes.Repeat([]byte("aaaaaaabbbc"), 1000)
+	if encoded := adaptiveEncode(t, input); len(encoded) > len(input)/4 {
+		t.Errorf("encoded %d bytes into %d, expected under 2 bits per byte", len(input), len(encoded))
+	}
+}
+
+func TestAdaptiveDecodeErrors(t *testing.T) {
+	encoded := adaptiveEncode(t, []byte("truncated adaptive stream"))
+	if _, err := adaptiveDecode(encoded[:len(encoded)/2], 100); err != io.ErrUnexpectedEOF {
+		t.Errorf("DecodeTo() on truncated input error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// Two bytes of ones send symbol 511 through the initial NYT root.
+	if _, err := adaptiveDecode([]byte{0xff, 0xff}, 10); err != ErrInvalidCode {
+		t.Errorf("DecodeTo() on an out of range symbol error = %v, want %v", err, ErrInvalidCode)
+	}
+}
+
+func BenchmarkAdaptiveEncode(b *testing.B) {
+	text := benchmarkText()
+	bw := NewBitWriter(io.Discard)
+	b.SetBytes(int64(len(text)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewAdaptiveCoder().Encode(bw, text)
+	}
+}
+
+func BenchmarkAdaptiveDecode(b *testing.B) {
+	text := benchmarkText()
+	encoded := adaptiveEncode(b, text)
+	b.SetBytes(int64(len(text)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		adaptiveDecode(encoded, len(text))
+	}
+}
@@ -1,8 +1,13 @@
 package compressutils
 
+import (
+	"container/heap"
+	"sort"
+)
+
 type leafMethods interface {
 	IsLeaf() bool
-	Char() rune
+	Char() byte
 }
 
 type internalMethods interface {
@@ -16,8 +21,11 @@ type node interface {
 }
 
 type leafNode struct {
-	Character rune
+	Character byte
 	Freq      int
+	// symbol identifies the leaf. It equals Character for byte alphabets and
+	// allows larger alphabets in SymbolCodeLengths.
+	symbol int
 }
 
 func (n *leafNode) IsLeaf() bool {
@@ -28,7 +36,7 @@ func (n *leafNode) Frequency() int {
 	return n.Freq
 }
 
-func (n *leafNode) Char() rune {
+func (n *leafNode) Char() byte {
 	return n.Character
 }
 
@@ -49,7 +57,7 @@ func (n *internalNode) Frequency() int {
 	return n.Freq
 }
 
-func (n *internalNode) Char() rune {
+func (n *internalNode) Char() byte {
 	return '/'
 }
 
@@ -57,99 +65,85 @@ func (n *internalNode) Child() []*node {
 	return n.Children
 }
 
-type PriorityQueue []*node
-
-func (pq *PriorityQueue) Len() int {
-	return len(*pq)
+// queuedNode is a tree in the nodeQueue. order breaks ties between trees of
+// the same frequency: leaves are numbered by symbol and merged trees after
+// them in the order they are built, so equal frequencies always come out in
+// the same order and leaves before merged trees.
+type queuedNode struct {
+	node  *node
+	order int
 }
 
-func (pq *PriorityQueue) Push(n *node) {
-	*pq = append(*pq, n)
-}
+// nodeQueue is a min-heap of trees by frequency for container/heap.
+type nodeQueue []queuedNode
 
-func (pq *PriorityQueue) IsEmpty() bool {
-	return (*pq).Len() == 0
+func (q nodeQueue) Len() int {
+	return len(q)
 }
 
-func (pq *PriorityQueue) Pop() *node {
-	if pq.IsEmpty() {
-		return nil
+func (q nodeQueue) Less(i, j int) bool {
+	fi, fj := (*q[i].node).Frequency(), (*q[j].node).Frequency()
+	if fi != fj {
+		return fi < fj
 	}
-	item := (*pq)[0]
-	*pq = (*pq)[1:]
-	return item
-}
-
-func (pq *PriorityQueue) Less(i, j int) bool {
-	nodeI := (*pq)[i]
-	nodeJ := (*pq)[j]
-	return (*nodeI).Frequency() >= (*nodeJ).Frequency()
+	return q[i].order < q[j].order
 }
 
-func (pq *PriorityQueue) Swap(i, j int) {
-	queue := (*pq)
-	queue[i], queue[j] = queue[j], queue[i]
+func (q nodeQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
 }
 
-// Use Binary search for getting the correct insert point
-func findCorrectInsertIndex(freq int, pq *PriorityQueue) int {
-	low, high := 0, pq.Len()-1
-
-	if low == high {
-		return 0 // Insert at the beginning if there's only one element
-	}
-
-	for low <= high {
-		mid := (low + high) / 2
-		midNode := (*pq)[mid]
-
-		if (*midNode).Frequency() < freq {
-			// Move right to find the correct insertion point in ascending order
-			low = mid + 1
-		} else if (*midNode).Frequency() > freq {
-			// Move left if freq should come before midNode
-			high = mid - 1
-		} else {
-			// If midNode.Frequency() == freq, we want to move right to insert at the last occurrence of equal frequencies
-			low = mid + 1
-		}
-	}
-
-	return low // `low` now points to the correct position for insertion
+func (q *nodeQueue) Push(x any) {
+	*q = append(*q, x.(queuedNode))
 }
 
-func addNewInternalLeaf(pq *PriorityQueue, node *node) {
-	indexToInsert := findCorrectInsertIndex((*node).Frequency(), pq)
-
-	// Extend slice by 1 element
-	*pq = append(*pq, nil)
-
-	// Shift the nodes to the right
-	copy((*pq)[indexToInsert+1:], (*pq)[indexToInsert:])
-	// Insert the new node at correct position
-	(*pq)[indexToInsert] = node
+func (q *nodeQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
 }
 
 func CreateBTreeFromFrequency(frequency Frequency) *node {
-	pq := &PriorityQueue{}
-
 	if len(frequency) <= 0 {
 		return nil
 	}
 
+	leaves := make([]*leafNode, 0, len(frequency))
 	for char, freq := range frequency {
-		leaf := &leafNode{
+		leaves = append(leaves, &leafNode{
 			Character: char,
 			Freq:      freq,
+			symbol:    int(char),
+		})
+	}
+	return buildTree(leaves)
+}
+
+func buildTree(leaves []*leafNode) *node {
+	if len(leaves) == 0 {
+		return nil
+	}
+
+	// Ties are broken on the symbol so the same input always builds the same
+	// tree. Sorted leaves already form a valid heap.
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].Freq != leaves[j].Freq {
+			return leaves[i].Freq < leaves[j].Freq
 		}
+		return leaves[i].symbol < leaves[j].symbol
+	})
+	queue := make(nodeQueue, len(leaves))
+	for i, leaf := range leaves {
 		node := node(leaf)
-		pq.Push(&node)
+		queue[i] = queuedNode{node: &node, order: i}
 	}
+	heap.Init(&queue)
 
-	// Create internal Nodes for the PriorityQueue
-	for pq.Len() > 1 {
-		minLeaf := pq.Pop()
-		secondMinLeaf := pq.Pop()
+	// Merge the two least frequent trees until one is left.
+	for order := len(leaves); queue.Len() > 1; order++ {
+		minLeaf := heap.Pop(&queue).(queuedNode).node
+		secondMinLeaf := heap.Pop(&queue).(queuedNode).node
 
 		newInternalLeaf := &internalNode{
 			Children: []*node{
@@ -159,8 +153,7 @@ func CreateBTreeFromFrequency(frequency Frequency) *node {
 			Freq: (*minLeaf).Frequency() + (*secondMinLeaf).Frequency(),
 		}
 		node := node(newInternalLeaf)
-
-		addNewInternalLeaf(pq, &node)
+		heap.Push(&queue, queuedNode{node: &node, order: order})
 	}
-	return pq.Pop()
+	return queue[0].node
 }
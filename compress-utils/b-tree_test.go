@@ -1,6 +1,7 @@
 package compressutils
 
 import (
+	"container/heap"
 	"reflect"
 	"testing"
 )
@@ -47,116 +48,73 @@ func TestInternalNode(t *testing.T) {
 	}
 }
 
-func TestPriorityQueue(t *testing.T) {
-	pq := &PriorityQueue{}
-
-	// Test empty queue
-	if !pq.IsEmpty() {
-		t.Error("New PriorityQueue should be empty")
-	}
-
-	if pq.Len() != 0 {
-		t.Errorf("New PriorityQueue length = %d; want 0", pq.Len())
-	}
-
-	if pq.Pop() != nil {
-		t.Error("Pop() on empty queue should return nil")
-	}
-
-	// Test pushing nodes (nodes should be sorted by frequency in ascending order)
-	node1 := node(&leafNode{Character: 'a', Freq: 1})
-	node2 := node(&leafNode{Character: 'b', Freq: 2})
-	node3 := node(&leafNode{Character: 'c', Freq: 4})
-	node4 := node(&leafNode{Character: 'd', Freq: 5})
-
-	pq.Push(&node1)
-	pq.Push(&node2)
-	pq.Push(&node3)
-	pq.Push(&node4)
-
-	if pq.Len() != 4 {
-		t.Errorf("PriorityQueue length = %d; want 4", pq.Len())
+func TestNodeQueue(t *testing.T) {
+	queue := &nodeQueue{}
+	push := func(char byte, freq, order int) {
+		n := node(&leafNode{Character: char, Freq: freq, symbol: int(char)})
+		heap.Push(queue, queuedNode{node: &n, order: order})
 	}
+	push('d', 5, 3)
+	push('b', 2, 1)
+	push('c', 2, 2)
+	push('e', 1, 4)
+	push('a', 2, 0)
 
-	if pq.IsEmpty() {
-		t.Error("PriorityQueue should not be empty after pushing")
+	if queue.Len() != 5 {
+		t.Errorf("nodeQueue length = %d; want 5", queue.Len())
 	}
 
-	// Test that elements are popped in ascending order of frequency
-	popped := pq.Pop()
-	if (*popped).Frequency() != 1 {
-		t.Errorf("First Pop() frequency = %d; want 1", (*popped).Frequency())
+	// Ascending frequency, ties in ascending order.
+	var got []byte
+	for queue.Len() > 0 {
+		got = append(got, (*heap.Pop(queue).(queuedNode).node).Char())
 	}
-
-	popped = pq.Pop()
-	if (*popped).Frequency() != 2 {
-		t.Errorf("Second Pop() frequency = %d; want 2", (*popped).Frequency())
+	if string(got) != "eabcd" {
+		t.Errorf("pop order = %q; want %q", got, "eabcd")
 	}
+}
 
-	popped = pq.Pop()
-	if (*popped).Frequency() != 4 {
-		t.Errorf("Third Pop() frequency = %d; want 4", (*popped).Frequency())
+func TestCreateBTreeIsDeterministic(t *testing.T) {
+	// All frequencies equal, so the tree depends only on tie-breaking.
+	frequency := Frequency{}
+	for i := 0; i < 256; i++ {
+		frequency[byte(i)] = 7
 	}
 
-	popped = pq.Pop()
-	if (*popped).Frequency() != 5 {
-		t.Errorf("Fourth Pop() frequency = %d; want 5", (*popped).Frequency())
+	first := CreateBTreeFromFrequency(frequency)
+	for i := 0; i < 10; i++ {
+		if again := CreateBTreeFromFrequency(frequency); !reflect.DeepEqual(again, first) {
+			t.Fatal("building the tree twice gave different trees")
+		}
 	}
-
-	if !pq.IsEmpty() {
-		t.Error("PriorityQueue should be empty after popping all elements")
+	if depth := getTreeDepth(first); depth != 9 {
+		t.Errorf("Tree depth = %d, want 9", depth)
 	}
 }
 
-func TestFindCorrectInsertIndex(t *testing.T) {
-	pq := &PriorityQueue{}
-	node1 := node(&leafNode{Character: 'a', Freq: 1})
-	node2 := node(&leafNode{Character: 'b', Freq: 3})
-	node3 := node(&leafNode{Character: 'c', Freq: 5})
-
-	pq.Push(&node1)
-	pq.Push(&node2)
-	pq.Push(&node3)
-
-	tests := []struct {
-		freq     int
-		expected int
-	}{
-		{0, 0}, // Insert at the end
-		{2, 1}, // Insert between 3 and 1
-		{3, 2}, // Insert at the same frequency
-		{6, 3}, // Insert at the last index
+func TestBuildTreeTieBreaking(t *testing.T) {
+	// a, b and c tie, so a and b are merged first and c is paired with them.
+	root := CreateBTreeFromFrequency(Frequency{'c': 1, 'b': 1, 'a': 1})
+	children := (*root).Child()
+	if !(*children[0]).IsLeaf() || (*children[0]).Char() != 'c' {
+		t.Fatalf("first child of the root = %c, want the leaf c", (*children[0]).Char())
 	}
-
-	for _, tt := range tests {
-		result := findCorrectInsertIndex(tt.freq, pq)
-
-		if result != tt.expected {
-			t.Errorf("findCorrectInsertIndex(%d) = %d; want %d", tt.freq, result, tt.expected)
-		}
+	merged := (*children[1]).Child()
+	if (*merged[0]).Char() != 'a' || (*merged[1]).Char() != 'b' {
+		t.Errorf("merged children = %c %c, want a b", (*merged[0]).Char(), (*merged[1]).Char())
 	}
 }
 
-func TestAddNewInternalLeaf(t *testing.T) {
-	pq := &PriorityQueue{}
-	node1 := node(&leafNode{Character: 'a', Freq: 1})
-	node2 := node(&leafNode{Character: 'b', Freq: 3})
-	node3 := node(&leafNode{Character: 'c', Freq: 5})
-
-	pq.Push(&node1)
-	pq.Push(&node2)
-	pq.Push(&node3)
-
-	newNode := node(&leafNode{Character: 'd', Freq: 2})
-	addNewInternalLeaf(pq, &newNode)
+func BenchmarkSymbolCodeLengths(b *testing.B) {
+	// A large alphabet, where sorted slice insertion would be quadratic.
+	frequencies := make([]int, 1<<16)
+	for i := range frequencies {
+		frequencies[i] = 1 + i*7919%1000
+	}
 
-	expected := &PriorityQueue{}
-	expected.Push(&node1)
-	expected.Push(&newNode)
-	expected.Push(&node2)
-	expected.Push(&node3)
-	if !reflect.DeepEqual(*pq, *expected) {
-		t.Errorf("After AddNewInternalLeaf, pq = %v; want %v", pq, expected)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SymbolCodeLengths(frequencies)
 	}
 }
 
@@ -246,7 +204,7 @@ func getTreeDepth(node *node) int {
 	return maxDepth + 1
 }
 
-func containsChar(node *node, char rune) bool {
+func containsChar(node *node, char byte) bool {
 	if (*node).IsLeaf() {
 		return (*node).Char() == char
 	}
@@ -0,0 +1,211 @@
+package compressutils
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const bitWriterBufferSize = 32 * 1024
+
+// BitWriter packs variable length bit strings MSB first into bytes, using a
+// uint64 accumulator and an output buffer so the underlying writer only sees
+// large writes.
+type BitWriter struct {
+	w     io.Writer
+	acc   uint64
+	nbits uint
+	buf   []byte
+	err   error
+}
+
+func NewBitWriter(w io.Writer) *BitWriter {
+	return &BitWriter{
+		w:   w,
+		buf: make([]byte, 0, bitWriterBufferSize+8),
+	}
+}
+
+// WriteBits appends the low n bits of value, most significant bit first.
+// n must not exceed 64. Errors from the underlying writer are sticky and
+// reported by Err and Flush.
+func (bw *BitWriter) WriteBits(value uint64, n uint) {
+	if n > 32 {
+		bw.WriteBits(value>>32, n-32)
+		n = 32
+	}
+	// The accumulator holds fewer than 32 bits here, so up to 32 more fit.
+	bw.acc = bw.acc<<n | value&(1<<n-1)
+	bw.nbits += n
+	if bw.nbits >= 32 {
+		bw.nbits -= 32
+		bw.buf = binary.BigEndian.AppendUint32(bw.buf, uint32(bw.acc>>bw.nbits))
+		if len(bw.buf) >= bitWriterBufferSize {
+			bw.flushBuffer()
+		}
+	}
+}
+
+// Align pads the current byte with zero bits and returns how many were added.
+func (bw *BitWriter) Align() uint {
+	padding := (8 - bw.nbits%8) % 8
+	bw.WriteBits(0, padding)
+	for bw.nbits >= 8 {
+		bw.nbits -= 8
+		bw.buf = append(bw.buf, byte(bw.acc>>bw.nbits))
+	}
+	return padding
+}
+
+func (bw *BitWriter) flushBuffer() {
+	if bw.err == nil && len(bw.buf) > 0 {
+		_, bw.err = bw.w.Write(bw.buf)
+	}
+	bw.buf = bw.buf[:0]
+}
+
+// Flush aligns to a byte boundary and writes all buffered bytes.
+func (bw *BitWriter) Flush() error {
+	bw.Align()
+	bw.flushBuffer()
+	return bw.err
+}
+
+func (bw *BitWriter) Err() error {
+	return bw.err
+}
+
+const bitReaderBufferSize = 32 * 1024
+
+// BitReader reads bits MSB first. It reads ahead of the current position into
+// its own buffer, so once the bit stream is done the caller should keep
+// reading through the BitReader (ReadBits, ReadByte) rather than the
+// underlying reader.
+type BitReader struct {
+	r   io.Reader
+	buf []byte
+	pos int
+	end int
+	// acc holds the buffered bits left aligned: the next bit is bit 63.
+	acc   uint64
+	nbits uint
+	err   error
+}
+
+func NewBitReader(r io.Reader) *BitReader {
+	return &BitReader{
+		r:   r,
+		buf: make([]byte, bitReaderBufferSize),
+	}
+}
+
+func (br *BitReader) fillBuffer() {
+	copy(br.buf, br.buf[br.pos:br.end])
+	br.end -= br.pos
+	br.pos = 0
+	for br.end < len(br.buf) && br.err == nil {
+		n, err := br.r.Read(br.buf[br.end:])
+		br.end += n
+		br.err = err
+		if n > 0 {
+			return
+		}
+	}
+}
+
+func (br *BitReader) refill() {
+	for br.nbits <= 56 {
+		if br.end-br.pos < 8 && br.err == nil {
+			br.fillBuffer()
+		}
+		if br.end-br.pos >= 8 {
+			// Load eight bytes at once and keep as many whole bytes as fit.
+			take := (64 - br.nbits) / 8
+			keep := br.nbits + take*8
+			next := binary.BigEndian.Uint64(br.buf[br.pos:])
+			br.acc = (br.acc | next>>br.nbits) &^ (^uint64(0) >> keep)
+			br.pos += int(take)
+			br.nbits = keep
+			return
+		}
+		if br.pos == br.end {
+			return
+		}
+		br.acc |= uint64(br.buf[br.pos]) << (56 - br.nbits)
+		br.pos++
+		br.nbits += 8
+	}
+}
+
+func (br *BitReader) shortRead() error {
+	if br.err == nil || br.err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return br.err
+}
+
+// PeekBits returns the next n bits (n <= 56) without consuming them. Near the
+// end of the input fewer bits may be available, the missing ones read as
+// zero and avail reports how many are real.
+func (br *BitReader) PeekBits(n uint) (value uint64, avail uint) {
+	if br.nbits < n {
+		br.refill()
+	}
+	return br.acc >> (64 - n), min(n, br.nbits)
+}
+
+// Consume drops n bits that were previously returned by PeekBits.
+func (br *BitReader) Consume(n uint) {
+	br.acc <<= n
+	br.nbits -= n
+}
+
+// ReadBits reads n bits (n <= 56) and returns them in the low bits of value.
+func (br *BitReader) ReadBits(n uint) (uint64, error) {
+	value, avail := br.PeekBits(n)
+	if avail < n {
+		return 0, br.shortRead()
+	}
+	br.Consume(n)
+	return value, nil
+}
+
+func (br *BitReader) ReadBit() (uint64, error) {
+	if br.nbits == 0 {
+		br.refill()
+		if br.nbits == 0 {
+			return 0, br.shortRead()
+		}
+	}
+	bit := br.acc >> 63
+	br.acc <<= 1
+	br.nbits--
+	return bit, nil
+}
+
+// Align skips the remaining bits of the current byte.
+func (br *BitReader) Align() {
+	br.Consume(br.nbits % 8)
+}
+
+// ReadByte reads the next 8 bits, which is a whole byte of the input once the
+// reader is aligned. It lets a BitReader be used as an io.ByteReader.
+func (br *BitReader) ReadByte() (byte, error) {
+	value, err := br.ReadBits(8)
+	if err == io.ErrUnexpectedEOF && br.nbits == 0 {
+		err = io.EOF
+	}
+	return byte(value), err
+}
+
+// Read reads whole bytes through ReadByte, so the input that follows an
+// aligned bit stream can be handed to code expecting an io.Reader.
+func (br *BitReader) Read(p []byte) (int, error) {
+	for i := range p {
+		b, err := br.ReadByte()
+		if err != nil {
+			return i, err
+		}
+		p[i] = b
+	}
+	return len(p), nil
+}
@@ -0,0 +1,174 @@
+package compressutils
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestBitWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		bits     []Code
+		expected []byte
+	}{
+		{
+			name:     "Single bit is padded",
+			bits:     []Code{{Bits: 1, Length: 1}},
+			expected: []byte{0x80},
+		},
+		{
+			name:     "Bits cross a byte boundary",
+			bits:     []Code{{Bits: 0b101, Length: 3}, {Bits: 0b11110000, Length: 8}},
+			expected: []byte{0xbe, 0x00},
+		},
+		{
+			name:     "Only the low bits are used",
+			bits:     []Code{{Bits: 0xff, Length: 4}},
+			expected: []byte{0xf0},
+		},
+		{
+			name:     "Wide value",
+			bits:     []Code{{Bits: 0x0123456789abcdef, Length: 64}},
+			expected: []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			bw := NewBitWriter(&buf)
+			for _, code := range tt.bits {
+				bw.WriteBits(code.Bits, code.Length)
+			}
+			if err := bw.Flush(); err != nil {
+				t.Fatalf("Flush() error = %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.expected) {
+				t.Errorf("BitWriter wrote %08b, want %08b", buf.Bytes(), tt.expected)
+			}
+		})
+	}
+}
+
+func TestBitWriterAlign(t *testing.T) {
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf)
+	bw.WriteBits(0b11, 2)
+	if padding := bw.Align(); padding != 6 {
+		t.Errorf("Align() = %d, want 6", padding)
+	}
+	if padding := bw.Align(); padding != 0 {
+		t.Errorf("Align() on aligned writer = %d, want 0", padding)
+	}
+	bw.WriteBits(0xab, 8)
+	bw.Flush()
+
+	if expected := []byte{0xc0, 0xab}; !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("BitWriter wrote %x, want %x", buf.Bytes(), expected)
+	}
+}
+
+func TestBitReaderRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	codes := make([]Code, 10000)
+	for i := range codes {
+		length := uint(random.Intn(56) + 1)
+		codes[i] = Code{Bits: random.Uint64() & (1<<length - 1), Length: length}
+	}
+
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf)
+	for _, code := range codes {
+		bw.WriteBits(code.Bits, code.Length)
+	}
+	bw.Flush()
+
+	br := NewBitReader(&buf)
+	for i, code := range codes {
+		value, err := br.ReadBits(code.Length)
+		if err != nil {
+			t.Fatalf("ReadBits(%d) at %d error = %v", code.Length, i, err)
+		}
+		if value != code.Bits {
+			t.Fatalf("ReadBits(%d) at %d = %b, want %b", code.Length, i, value, code.Bits)
+		}
+	}
+}
+
+func TestBitReader(t *testing.T) {
+	br := NewBitReader(bytes.NewReader([]byte{0b10110000, 0xab, 0xcd}))
+
+	for _, expected := range []uint64{1, 0, 1, 1} {
+		if bit, err := br.ReadBit(); err != nil || bit != expected {
+			t.Fatalf("ReadBit() = %d, %v; want %d", bit, err, expected)
+		}
+	}
+
+	br.Align()
+	if b, err := br.ReadByte(); err != nil || b != 0xab {
+		t.Errorf("ReadByte() after Align = %x, %v; want ab", b, err)
+	}
+
+	if value, avail := br.PeekBits(16); avail != 8 || value != 0xcd00 {
+		t.Errorf("PeekBits(16) = %x, %d; want cd00, 8", value, avail)
+	}
+	if _, err := br.ReadBits(16); err != io.ErrUnexpectedEOF {
+		t.Errorf("ReadBits(16) past the end error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	if b, err := br.ReadByte(); err != nil || b != 0xcd {
+		t.Errorf("ReadByte() = %x, %v; want cd", b, err)
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("ReadByte() at the end error = %v, want %v", err, io.EOF)
+	}
+}
+
+func benchmarkCodes() []Code {
+	random := rand.New(rand.NewSource(1))
+	codes := make([]Code, 1<<16)
+	for i := range codes {
+		length := uint(random.Intn(12) + 1)
+		codes[i] = Code{Bits: random.Uint64() & (1<<length - 1), Length: length}
+	}
+	return codes
+}
+
+func BenchmarkBitWriter(b *testing.B) {
+	codes := benchmarkCodes()
+	totalBits := 0
+	for _, code := range codes {
+		totalBits += int(code.Length)
+	}
+	b.SetBytes(int64(totalBits / 8))
+
+	bw := NewBitWriter(io.Discard)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, code := range codes {
+			bw.WriteBits(code.Bits, code.Length)
+		}
+	}
+	bw.Flush()
+}
+
+func BenchmarkBitReader(b *testing.B) {
+	codes := benchmarkCodes()
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf)
+	for _, code := range codes {
+		bw.WriteBits(code.Bits, code.Length)
+	}
+	bw.Flush()
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		br := NewBitReader(bytes.NewReader(data))
+		for _, code := range codes {
+			br.ReadBits(code.Length)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package compressutils
+
+import (
+	"errors"
+	"sort"
+)
+
+// CodeLengthTable holds the length in bits of the Huffman code for every
+// byte value. It is all that is needed to rebuild canonical codes.
+type CodeLengthTable map[byte]int
+
+func GetCodeLengths(huffmanCodes HuffmanCodeTable) CodeLengthTable {
+	codeLengths := make(CodeLengthTable, len(huffmanCodes))
+	for char, code := range huffmanCodes {
+		codeLengths[char] = len(code)
+	}
+	return codeLengths
+}
+
+// SortedSymbols returns the characters ordered by code length and then by
+// character value, which is the order canonical codes are assigned in.
+func (codeLengths CodeLengthTable) SortedSymbols() []byte {
+	symbols := make([]byte, 0, len(codeLengths))
+	for char := range codeLengths {
+		symbols = append(symbols, char)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		li, lj := codeLengths[symbols[i]], codeLengths[symbols[j]]
+		if li != lj {
+			return li < lj
+		}
+		return symbols[i] < symbols[j]
+	})
+	return symbols
+}
+
+// incrementCode adds one to a binary code string and reports whether the
+// addition overflowed.
+func incrementCode(code []byte) bool {
+	for i := len(code) - 1; i >= 0; i-- {
+		if code[i] == '0' {
+			code[i] = '1'
+			return false
+		}
+		code[i] = '0'
+	}
+	return true
+}
+
+// GenerateCanonicalHuffmanCodes assigns canonical codes from the code lengths:
+// shorter codes come first, characters of equal length are numbered
+// consecutively in character order, so the table is fully described by the
+// lengths alone.
+func GenerateCanonicalHuffmanCodes(codeLengths CodeLengthTable) (HuffmanCodeTable, error) {
+	huffmanCodes := make(HuffmanCodeTable, len(codeLengths))
+
+	var code []byte
+	for i, char := range codeLengths.SortedSymbols() {
+		length := codeLengths[char]
+		if length <= 0 {
+			return nil, errors.New("invalid code length: must be positive")
+		}
+		if i > 0 && incrementCode(code) {
+			return nil, errors.New("invalid code lengths: more codes than the lengths allow")
+		}
+		for len(code) < length {
+			code = append(code, '0')
+		}
+		huffmanCodes[char] = string(code)
+	}
+
+	return huffmanCodes, nil
+}
+
+// CanonicalCodes is GenerateCanonicalHuffmanCodes for code lengths indexed by
+// symbol, with zero meaning no code. It assigns the same codes in the same
+// order, which is also the order RFC 1951 uses, and fails if the lengths
+// describe more codes than fit.
+func CanonicalCodes(lengths []int) ([]Code, error) {
+	var count [MaxCodeLength + 1]uint64
+	for _, length := range lengths {
+		if length < 0 || length > MaxCodeLength {
+			return nil, errors.New("invalid code length: out of range")
+		}
+		count[length]++
+	}
+	count[0] = 0
+
+	var next [MaxCodeLength + 1]uint64
+	var code uint64
+	for length := 1; length <= MaxCodeLength; length++ {
+		code = (code + count[length-1]) << 1
+		if count[length] > 0 && length < MaxCodeLength && code+count[length] > 1<<length {
+			return nil, errors.New("invalid code lengths: more codes than the lengths allow")
+		}
+		next[length] = code
+	}
+
+	codes := make([]Code, len(lengths))
+	for symbol, length := range lengths {
+		if length > 0 {
+			codes[symbol] = Code{Bits: next[length], Length: uint(length)}
+			next[length]++
+		}
+	}
+	return codes, nil
+}
@@ -0,0 +1,128 @@
+package compressutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetCodeLengths(t *testing.T) {
+	huffmanCodes := HuffmanCodeTable{'a': "0", 'b': "10", 'c': "11"}
+	expected := CodeLengthTable{'a': 1, 'b': 2, 'c': 2}
+
+	if result := GetCodeLengths(huffmanCodes); !reflect.DeepEqual(result, expected) {
+		t.Errorf("GetCodeLengths() = %v, want %v", result, expected)
+	}
+}
+
+func TestSortedSymbols(t *testing.T) {
+	codeLengths := CodeLengthTable{'d': 1, 'c': 3, 'a': 3, 'b': 2}
+	expected := []byte{'d', 'b', 'a', 'c'}
+
+	if result := codeLengths.SortedSymbols(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("SortedSymbols() = %q, want %q", result, expected)
+	}
+}
+
+func TestGenerateCanonicalHuffmanCodes(t *testing.T) {
+	tests := []struct {
+		codeLengths CodeLengthTable
+		expected    HuffmanCodeTable
+		name        string
+		expectedErr bool
+	}{
+		{
+			name:        "Two characters",
+			codeLengths: CodeLengthTable{'b': 1, 'a': 1},
+			expected:    HuffmanCodeTable{'a': "0", 'b': "1"},
+		},
+		{
+			name:        "Mixed lengths",
+			codeLengths: CodeLengthTable{'a': 2, 'b': 1, 'c': 3, 'd': 3},
+			expected:    HuffmanCodeTable{'b': "0", 'a': "10", 'c': "110", 'd': "111"},
+		},
+		{
+			name:        "Gap in lengths",
+			codeLengths: CodeLengthTable{'a': 1, 'b': 3, 'c': 3, 'd': 3, 'e': 3},
+			expected:    HuffmanCodeTable{'a': "0", 'b': "100", 'c': "101", 'd': "110", 'e': "111"},
+		},
+		{
+			name:        "Oversubscribed lengths",
+			codeLengths: CodeLengthTable{'a': 1, 'b': 1, 'c': 1},
+			expectedErr: true,
+		},
+		{
+			name:        "Zero length",
+			codeLengths: CodeLengthTable{'a': 0, 'b': 1},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := GenerateCanonicalHuffmanCodes(tt.codeLengths)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("GenerateCanonicalHuffmanCodes() error = %v, expectedErr %v", err, tt.expectedErr)
+			}
+			if !tt.expectedErr && !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("GenerateCanonicalHuffmanCodes() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCanonicalCodesKeepTreeLengths(t *testing.T) {
+	frequency := Frequency{'a': 45, 'b': 13, 'c': 12, 'd': 16, 'e': 9, 'f': 5}
+	rootNode := CreateBTreeFromFrequency(frequency)
+	treeCodes, err := TraverseBTreeToGenerateHuffmanCodes(rootNode, len(frequency))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canonicalCodes, err := GenerateCanonicalHuffmanCodes(GetCodeLengths(treeCodes))
+	if err != nil {
+		t.Fatalf("GenerateCanonicalHuffmanCodes() error = %v", err)
+	}
+	if !reflect.DeepEqual(GetCodeLengths(canonicalCodes), GetCodeLengths(treeCodes)) {
+		t.Errorf("canonical code lengths %v differ from tree lengths %v", GetCodeLengths(canonicalCodes), GetCodeLengths(treeCodes))
+	}
+}
+
+func TestCanonicalCodes(t *testing.T) {
+	// The example of RFC 1951 section 3.2.2: lengths (3, 3, 3, 3, 3, 2, 4, 4)
+	// for the symbols A to H.
+	codes, err := CanonicalCodes([]int{3, 3, 3, 3, 3, 2, 4, 4})
+	if err != nil {
+		t.Fatalf("CanonicalCodes() error = %v", err)
+	}
+	expected := []Code{
+		{Bits: 0b010, Length: 3}, {Bits: 0b011, Length: 3}, {Bits: 0b100, Length: 3},
+		{Bits: 0b101, Length: 3}, {Bits: 0b110, Length: 3}, {Bits: 0b00, Length: 2},
+		{Bits: 0b1110, Length: 4}, {Bits: 0b1111, Length: 4},
+	}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Errorf("CanonicalCodes() = %v, want %v", codes, expected)
+	}
+
+	// Symbols without a code keep a zero Code and the rest match the byte
+	// oriented generator.
+	lengths := CodeLengthTable{'a': 1, 'c': 2, 'd': 3, 'z': 3}
+	huffmanCodes, _ := GenerateCanonicalHuffmanCodes(lengths)
+	bySymbol := make([]int, 256)
+	for char, length := range lengths {
+		bySymbol[char] = length
+	}
+	codes, err = CanonicalCodes(bySymbol)
+	if err != nil {
+		t.Fatalf("CanonicalCodes() error = %v", err)
+	}
+	table, _ := NewCodeTable(huffmanCodes)
+	for symbol, code := range codes {
+		if code != table[symbol] {
+			t.Errorf("CanonicalCodes()[%q] = %v, want %v", symbol, code, table[symbol])
+		}
+	}
+
+	if _, err := CanonicalCodes([]int{1, 1, 1}); err == nil {
+		t.Error("CanonicalCodes() expected an error for over-subscribed lengths")
+	}
+}
@@ -1,8 +1,8 @@
 package compressutils
 
 import (
-	"fmt"
 	"io"
+	"math"
 	"os"
 	"sort"
 	"sync"
@@ -13,14 +13,14 @@ const (
 	maxGoroutines = 10
 )
 
-var FrequencyProgress = 0
+// Frequency counts how often every byte value occurs. The alphabet is the 256
+// byte values, not runes, so any file can be compressed whatever its encoding.
+type Frequency map[byte]int
 
-type Frequency map[rune]int
-
-func getFrequencyCount(data string) Frequency {
+func getFrequencyCount(data []byte) Frequency {
 	freq := make(Frequency)
-	for _, char := range data {
-		freq[char]++
+	for _, b := range data {
+		freq[b]++
 	}
 	return freq
 }
@@ -29,20 +29,20 @@ func processBatches(freqCh chan Frequency, taskCh chan []byte, wg *sync.WaitGrou
 	defer wg.Done()
 
 	for fileChunk := range taskCh {
-		freq := getFrequencyCount(string(fileChunk))
+		freq := getFrequencyCount(fileChunk)
 		freqCh <- freq
 	}
 }
 
 func sortFrequencyInAscending(freq Frequency) Frequency {
-	type RuneFreq struct {
-		Key   rune
+	type ByteFreq struct {
+		Key   byte
 		Value int
 	}
-	// Step 1: Convert the Frequency map to a slice of RuneFreq pairs
-	var freqSlice []RuneFreq
+	// Step 1: Convert the Frequency map to a slice of ByteFreq pairs
+	var freqSlice []ByteFreq
 	for key, value := range freq {
-		freqSlice = append(freqSlice, RuneFreq{Key: key, Value: value})
+		freqSlice = append(freqSlice, ByteFreq{Key: key, Value: value})
 	}
 
 	// Step 2: Sort the slice based on the frequency values
@@ -59,6 +59,42 @@ func sortFrequencyInAscending(freq Frequency) Frequency {
 	return sortedFreq
 }
 
+// GetFrequencyForBytes counts the bytes of data in the calling goroutine. It
+// suits callers that already split their input and parallelize themselves,
+// like the block mode of the compactor package.
+func GetFrequencyForBytes(data []byte) Frequency {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	freq := make(Frequency)
+	for b, count := range counts {
+		if count > 0 {
+			freq[byte(b)] = count
+		}
+	}
+	return freq
+}
+
+// Entropy returns the Shannon entropy of the byte distribution in bits per
+// byte, the least any code that looks at one byte at a time can average.
+func Entropy(frequency Frequency) float64 {
+	total := 0
+	for _, count := range frequency {
+		total += count
+	}
+
+	entropy := 0.0
+	for _, count := range frequency {
+		if count > 0 {
+			p := float64(count) / float64(total)
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
 func GetFrequencyForFile(filePath string) (*Frequency, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -66,6 +102,10 @@ func GetFrequencyForFile(filePath string) (*Frequency, error) {
 	}
 	defer file.Close()
 
+	return GetFrequencyForReader(file)
+}
+
+func GetFrequencyForReader(reader io.Reader) (*Frequency, error) {
 	var wg sync.WaitGroup
 	freqCh := make(chan Frequency)
 	taskCh := make(chan []byte, maxGoroutines)
@@ -80,23 +120,21 @@ func GetFrequencyForFile(filePath string) (*Frequency, error) {
 		close(freqCh)
 	}()
 
+	var readErr error
 	go func() {
 		defer close(taskCh)
 
 		for {
 			buffer := make([]byte, batchSize)
-			byteRead, err := file.Read(buffer)
+			byteRead, err := reader.Read(buffer)
+			if byteRead > 0 {
+				taskCh <- buffer[:byteRead]
+			}
 			if err != nil {
 				if err != io.EOF {
-					fmt.Println("Error reading file:", err)
-					return
-				} else {
-					break
+					readErr = err
 				}
-			}
-
-			if byteRead > 0 {
-				taskCh <- buffer[:byteRead]
+				return
 			}
 		}
 	}()
@@ -107,6 +145,9 @@ func GetFrequencyForFile(filePath string) (*Frequency, error) {
 			totalFreq[char] += count
 		}
 	}
+	if readErr != nil {
+		return nil, readErr
+	}
 
 	// Sort Frequency
 	totalFreq = sortFrequencyInAscending(totalFreq)
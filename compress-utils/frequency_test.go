@@ -1,6 +1,7 @@
 package compressutils
 
 import (
+	"math"
 	"os"
 	"reflect"
 	"sync"
@@ -43,10 +44,15 @@ func TestGetFrequencyCount(t *testing.T) {
 			input:    "!@#$$%^&*()",
 			expected: Frequency{'!': 1, '@': 1, '#': 1, '$': 2, '%': 1, '^': 1, '&': 1, '*': 1, '(': 1, ')': 1},
 		},
-		// Edge case: String with emojis or multi-byte characters
+		// Edge case: Multi-byte characters are counted per byte
 		{
 			input:    "😀😀😁😂",
-			expected: Frequency{'😀': 2, '😁': 1, '😂': 1},
+			expected: Frequency{0xf0: 4, 0x9f: 4, 0x98: 4, 0x80: 2, 0x81: 1, 0x82: 1},
+		},
+		// Edge case: Binary data that is not valid UTF-8
+		{
+			input:    "\x00\xff\xfe\x00",
+			expected: Frequency{0x00: 2, 0xff: 1, 0xfe: 1},
 		},
 		// Edge case: String with a single character repeated many times
 		{
@@ -56,10 +62,13 @@ func TestGetFrequencyCount(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		result := getFrequencyCount(test.input)
+		result := getFrequencyCount([]byte(test.input))
 		if !reflect.DeepEqual(result, test.expected) {
 			t.Errorf("For input '%s', expected %v, but got %v", test.input, test.expected, result)
 		}
+		if result := GetFrequencyForBytes([]byte(test.input)); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("GetFrequencyForBytes(%q) = %v, want %v", test.input, result, test.expected)
+		}
 	}
 }
 
@@ -134,3 +143,31 @@ func TestGetFrequencyForFile(t *testing.T) {
 		t.Errorf("GetFrequencyForFile() = %v, want %v", result, expected)
 	}
 }
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		name      string
+		frequency Frequency
+		expected  float64
+	}{
+		{name: "Empty", frequency: Frequency{}, expected: 0},
+		{name: "Single symbol", frequency: Frequency{'a': 10}, expected: 0},
+		{name: "Two equal symbols", frequency: Frequency{'a': 5, 'b': 5}, expected: 1},
+		{name: "Skewed", frequency: Frequency{'a': 2, 'b': 1, 'c': 1}, expected: 1.5},
+		{name: "All bytes", frequency: func() Frequency {
+			freq := make(Frequency)
+			for b := 0; b < 256; b++ {
+				freq[byte(b)] = 3
+			}
+			return freq
+		}(), expected: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Entropy(tt.frequency); math.Abs(got-tt.expected) > 1e-9 {
+				t.Errorf("Entropy() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
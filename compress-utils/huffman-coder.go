@@ -0,0 +1,253 @@
+package compressutils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxCodeLength is the longest code the encoder and decoder can handle, as a
+// code has to fit into the 64 bit accumulator of the BitWriter.
+const MaxCodeLength = 64
+
+var ErrInvalidCode = errors.New("invalid Huffman code in bit stream")
+
+// Code is a Huffman code stored as an integer, the first bit being the most
+// significant of the Length low bits.
+type Code struct {
+	Bits   uint64
+	Length uint
+}
+
+// CodeTable is the encoding table indexed by byte value. Bytes that do not
+// occur have a zero Length.
+type CodeTable [256]Code
+
+func NewCodeTable(huffmanCodes HuffmanCodeTable) (*CodeTable, error) {
+	table := &CodeTable{}
+	for char, code := range huffmanCodes {
+		if len(code) == 0 || len(code) > MaxCodeLength {
+			return nil, fmt.Errorf("code length %d for byte 0x%02x is out of range", len(code), char)
+		}
+		var bits uint64
+		for _, bit := range code {
+			bits <<= 1
+			if bit == '1' {
+				bits |= 1
+			}
+		}
+		table[char] = Code{Bits: bits, Length: uint(len(code))}
+	}
+	return table, nil
+}
+
+// Encode writes the code of every byte of data. It fails on a byte that has
+// no code.
+func (table *CodeTable) Encode(bw *BitWriter, data []byte) error {
+	for _, b := range data {
+		code := table[b]
+		if code.Length == 0 {
+			return fmt.Errorf("no Huffman code for byte 0x%02x", b)
+		}
+		bw.WriteBits(code.Bits, code.Length)
+	}
+	return bw.Err()
+}
+
+const (
+	// primaryTableBits is how many bits the first lookup resolves. Longer
+	// codes continue in a secondary table of their prefix.
+	primaryTableBits = 10
+	// maxSecondaryTableBits bounds the size of a secondary table. Codes that
+	// are longer still fall back to decoding one bit at a time.
+	maxSecondaryTableBits = 12
+)
+
+const (
+	entryInvalid uint8 = iota
+	entrySymbol
+	entrySecondary
+	entrySlow
+)
+
+type decodeEntry struct {
+	kind   uint8
+	symbol byte
+	// length is the code length for entrySymbol and the number of index bits
+	// of the secondary table for entrySecondary.
+	length uint8
+	offset uint32
+}
+
+// Decoder decodes canonical Huffman codes with lookup tables: it peeks
+// primaryTableBits bits and a single lookup yields the symbol and its code
+// length. Codes longer than that take one more lookup in a secondary table.
+type Decoder struct {
+	primary     []decodeEntry
+	primaryBits uint
+	secondary   []decodeEntry
+
+	// Canonical codes of the same length are consecutive integers, so the
+	// slow path resolves a code by comparing it with the first code of its
+	// length.
+	firstCode [MaxCodeLength + 1]uint64
+	count     [MaxCodeLength + 1]uint64
+	offset    [MaxCodeLength + 1]int
+	symbols   []byte
+	maxLength int
+}
+
+func NewDecoder(codeLengths CodeLengthTable) (*Decoder, error) {
+	decoder := &Decoder{symbols: codeLengths.SortedSymbols()}
+
+	for _, length := range codeLengths {
+		if length <= 0 || length > MaxCodeLength {
+			return nil, fmt.Errorf("code length %d is out of range", length)
+		}
+		decoder.count[length]++
+		decoder.maxLength = max(decoder.maxLength, length)
+	}
+
+	var code uint64
+	offset := 0
+	for length := 1; length <= decoder.maxLength; length++ {
+		decoder.firstCode[length] = code
+		decoder.offset[length] = offset
+		code += decoder.count[length]
+		offset += int(decoder.count[length])
+		if length < MaxCodeLength && code > 1<<length {
+			return nil, errors.New("invalid code lengths: more codes than the lengths allow")
+		}
+		code <<= 1
+	}
+
+	decoder.buildTables()
+	return decoder, nil
+}
+
+// canonicalCode returns the code of the i-th symbol in canonical order.
+func (decoder *Decoder) canonicalCode(i int) (uint64, uint) {
+	length := 1
+	for length < decoder.maxLength && decoder.offset[length]+int(decoder.count[length]) <= i {
+		length++
+	}
+	return decoder.firstCode[length] + uint64(i-decoder.offset[length]), uint(length)
+}
+
+func (decoder *Decoder) buildTables() {
+	primaryBits := uint(min(decoder.maxLength, primaryTableBits))
+	decoder.primaryBits = primaryBits
+	decoder.primary = make([]decodeEntry, 1<<primaryBits)
+
+	// Symbols are in canonical order, so codes sharing a primary prefix are
+	// next to each other and the longest of them comes last.
+	longest := make(map[uint64]uint)
+	for i := range decoder.symbols {
+		code, length := decoder.canonicalCode(i)
+		if length > primaryBits {
+			longest[code>>(length-primaryBits)] = length
+		}
+	}
+
+	for i, char := range decoder.symbols {
+		code, length := decoder.canonicalCode(i)
+		if length <= primaryBits {
+			shift := primaryBits - length
+			for index := code << shift; index < (code+1)<<shift; index++ {
+				decoder.primary[index] = decodeEntry{kind: entrySymbol, symbol: char, length: uint8(length)}
+			}
+			continue
+		}
+
+		prefix := code >> (length - primaryBits)
+		subBits := longest[prefix] - primaryBits
+		if subBits > maxSecondaryTableBits {
+			decoder.primary[prefix] = decodeEntry{kind: entrySlow}
+			continue
+		}
+		entry := &decoder.primary[prefix]
+		if entry.kind != entrySecondary {
+			*entry = decodeEntry{kind: entrySecondary, length: uint8(subBits), offset: uint32(len(decoder.secondary))}
+			decoder.secondary = append(decoder.secondary, make([]decodeEntry, 1<<subBits)...)
+		}
+
+		// Index the secondary table with the subBits bits after the prefix.
+		shift := primaryBits + subBits - length
+		rest := code & (1<<(length-primaryBits) - 1)
+		for index := rest << shift; index < (rest+1)<<shift; index++ {
+			decoder.secondary[uint64(entry.offset)+index] = decodeEntry{kind: entrySymbol, symbol: char, length: uint8(length)}
+		}
+	}
+}
+
+// Decode reads one code from br and returns its byte.
+func (decoder *Decoder) Decode(br *BitReader) (byte, error) {
+	peek, avail := br.PeekBits(decoder.primaryBits)
+	entry := decoder.primary[peek]
+
+	switch entry.kind {
+	case entrySecondary:
+		bits := decoder.primaryBits + uint(entry.length)
+		peek, avail = br.PeekBits(bits)
+		entry = decoder.secondary[uint64(entry.offset)+peek&(1<<entry.length-1)]
+	case entrySlow:
+		return decoder.decodeSlow(br)
+	}
+
+	if avail == 0 {
+		return 0, br.shortRead()
+	}
+	if entry.kind != entrySymbol {
+		return 0, ErrInvalidCode
+	}
+	if uint(entry.length) > avail {
+		return 0, br.shortRead()
+	}
+	br.Consume(uint(entry.length))
+	return entry.symbol, nil
+}
+
+// DecodeTo fills dst with decoded bytes and returns how many it decoded
+// before an error. It is the fast path for decoding long runs of symbols.
+func (decoder *Decoder) DecodeTo(br *BitReader, dst []byte) (int, error) {
+	primaryBits := decoder.primaryBits
+	for i := range dst {
+		// As long as enough bits are buffered the primary lookup needs no
+		// bounds or end of input checks.
+		if br.nbits < primaryBits {
+			br.refill()
+		}
+		if br.nbits >= primaryBits {
+			entry := decoder.primary[br.acc>>(64-primaryBits)]
+			if entry.kind == entrySymbol {
+				br.acc <<= entry.length
+				br.nbits -= uint(entry.length)
+				dst[i] = entry.symbol
+				continue
+			}
+		}
+
+		char, err := decoder.Decode(br)
+		if err != nil {
+			return i, err
+		}
+		dst[i] = char
+	}
+	return len(dst), nil
+}
+
+// decodeSlow reads a code one bit at a time, for codes too long for the
+// lookup tables.
+func (decoder *Decoder) decodeSlow(br *BitReader) (byte, error) {
+	var code uint64
+	for length := 1; length <= decoder.maxLength; length++ {
+		bit, err := br.ReadBit()
+		if err != nil {
+			return 0, err
+		}
+		code = code<<1 | bit
+		if index := code - decoder.firstCode[length]; index < decoder.count[length] {
+			return decoder.symbols[decoder.offset[length]+int(index)], nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
@@ -0,0 +1,179 @@
+package compressutils
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func codesForText(t testing.TB, text []byte) (HuffmanCodeTable, CodeLengthTable) {
+	t.Helper()
+
+	frequency := getFrequencyCount(text)
+	rootNode := CreateBTreeFromFrequency(frequency)
+	treeCodes, err := TraverseBTreeToGenerateHuffmanCodes(rootNode, len(frequency))
+	if err != nil {
+		t.Fatal(err)
+	}
+	codeLengths := GetCodeLengths(treeCodes)
+	huffmanCodes, err := GenerateCanonicalHuffmanCodes(codeLengths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return huffmanCodes, codeLengths
+}
+
+func TestNewCodeTable(t *testing.T) {
+	table, err := NewCodeTable(HuffmanCodeTable{'a': "0", 'b': "10", 'c': "11"})
+	if err != nil {
+		t.Fatalf("NewCodeTable() error = %v", err)
+	}
+
+	expected := map[byte]Code{
+		'a': {Bits: 0b0, Length: 1},
+		'b': {Bits: 0b10, Length: 2},
+		'c': {Bits: 0b11, Length: 2},
+		'd': {},
+	}
+	for char, code := range expected {
+		if table[char] != code {
+			t.Errorf("table[%q] = %+v, want %+v", char, table[char], code)
+		}
+	}
+
+	if _, err := NewCodeTable(HuffmanCodeTable{'a': strings.Repeat("1", MaxCodeLength+1)}); err == nil {
+		t.Error("NewCodeTable() expected an error for an overlong code")
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	text := []byte("the quick brown fox jumps over the lazy dog \x00\xff")
+	huffmanCodes, codeLengths := codesForText(t, text)
+
+	table, err := NewCodeTable(huffmanCodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf)
+	if err := table.Encode(bw, text); err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+	bw.Flush()
+
+	decoder, err := NewDecoder(codeLengths)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+	br := NewBitReader(&buf)
+	for i, expected := range text {
+		char, err := decoder.Decode(br)
+		if err != nil {
+			t.Fatalf("Decode() at %d error = %v", i, err)
+		}
+		if char != expected {
+			t.Fatalf("Decode() at %d = %q, want %q", i, char, expected)
+		}
+	}
+
+	if err := table.Encode(bw, []byte("~")); err == nil {
+		t.Error("Encode() expected an error for a byte without a code")
+	}
+}
+
+func TestDecodeLongCodes(t *testing.T) {
+	// Fibonacci frequencies give the most skewed tree, with code lengths
+	// running past both the primary and the secondary lookup tables.
+	for _, symbols := range []int{8, 16, 30} {
+		var text []byte
+		a, b := 1, 1
+		for i := 0; i < symbols; i++ {
+			text = append(text, bytes.Repeat([]byte{byte('A' + i)}, a)...)
+			a, b = b, a+b
+		}
+		huffmanCodes, codeLengths := codesForText(t, text)
+		table, err := NewCodeTable(huffmanCodes)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		bw := NewBitWriter(&buf)
+		table.Encode(bw, text)
+		bw.Flush()
+
+		decoder, err := NewDecoder(codeLengths)
+		if err != nil {
+			t.Fatalf("NewDecoder() error = %v", err)
+		}
+		decoded := make([]byte, len(text))
+		if n, err := decoder.DecodeTo(NewBitReader(&buf), decoded); err != nil {
+			t.Fatalf("%d symbols: DecodeTo() error = %v after %d bytes", symbols, err, n)
+		}
+		if !bytes.Equal(decoded, text) {
+			t.Errorf("%d symbols: DecodeTo() did not round trip", symbols)
+		}
+	}
+}
+
+func TestDecoderErrors(t *testing.T) {
+	if _, err := NewDecoder(CodeLengthTable{'a': 1, 'b': 1, 'c': 1}); err == nil {
+		t.Error("NewDecoder() expected an error for oversubscribed lengths")
+	}
+
+	// 'a' = 0 and 'b' = 10, so 11 is not a valid code.
+	decoder, err := NewDecoder(CodeLengthTable{'a': 1, 'b': 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decoder.Decode(NewBitReader(bytes.NewReader([]byte{0xff}))); err != ErrInvalidCode {
+		t.Errorf("Decode() error = %v, want %v", err, ErrInvalidCode)
+	}
+	if _, err := decoder.Decode(NewBitReader(bytes.NewReader(nil))); err != io.ErrUnexpectedEOF {
+		t.Errorf("Decode() on empty input error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func benchmarkText() []byte {
+	return []byte(strings.Repeat("It was the best of times, it was the worst of times, it was the age of wisdom, "+
+		"it was the age of foolishness, it was the epoch of belief, it was the epoch of incredulity.\n", 4096))
+}
+
+func BenchmarkEncode(b *testing.B) {
+	text := benchmarkText()
+	huffmanCodes, _ := codesForText(b, text)
+	table, err := NewCodeTable(huffmanCodes)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(text)))
+
+	bw := NewBitWriter(io.Discard)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		table.Encode(bw, text)
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	text := benchmarkText()
+	huffmanCodes, codeLengths := codesForText(b, text)
+	table, _ := NewCodeTable(huffmanCodes)
+	var buf bytes.Buffer
+	bw := NewBitWriter(&buf)
+	table.Encode(bw, text)
+	bw.Flush()
+	decoder, err := NewDecoder(codeLengths)
+	if err != nil {
+		b.Fatal(err)
+	}
+	decoded := make([]byte, len(text))
+	b.SetBytes(int64(len(text)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		br := NewBitReader(bytes.NewReader(buf.Bytes()))
+		decoder.DecodeTo(br, decoded)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"sync"
 )
 
-type HuffmanCodeTable map[rune]string
+type HuffmanCodeTable map[byte]string
 
 type NodePath struct {
 	Node *node
@@ -15,7 +15,7 @@ type NodePath struct {
 
 type HuffmanCodeChannel struct {
 	Path string
-	Char rune
+	Char byte
 }
 
 func handleLeafNode(node *node, path string, huffmanCh chan<- HuffmanCodeChannel) {
@@ -45,10 +45,18 @@ func handleNodes(nodeCh chan NodePath, huffmanCh chan<- HuffmanCodeChannel, wg *
 	}
 }
 
+// TraverseBTreeToGenerateHuffmanCodes returns the code of every leaf, the
+// path from the root with 0 for the first child and 1 for the second. A tree
+// of a single leaf gives its symbol the code "0", as it has no path but
+// still needs a code to be written. An empty tree has no codes to give and
+// is an error.
 func TraverseBTreeToGenerateHuffmanCodes(rootNode *node, totalCodeCount int) (HuffmanCodeTable, error) {
+	if rootNode == nil || *rootNode == nil {
+		return nil, errors.New("invalid root node: the tree is empty")
+	}
 	node := *rootNode
-	if len(node.Child()) == 0 && node.IsLeaf() {
-		return nil, errors.New("invalid root node: has no child and not a leaf node")
+	if node.IsLeaf() {
+		return HuffmanCodeTable{node.Char(): "0"}, nil
 	}
 
 	nodeCh := make(chan NodePath, 1000)
@@ -86,3 +94,38 @@ func TraverseBTreeToGenerateHuffmanCodes(rootNode *node, totalCodeCount int) (Hu
 
 	return huffmanCodes, nil
 }
+
+// SymbolCodeLengths builds the Huffman tree for an alphabet that may be
+// larger than a byte, such as the literal/length alphabet of DEFLATE, and
+// returns the code length of every symbol. frequencies is indexed by symbol,
+// symbols with a zero frequency get no code and a lone symbol gets a one bit
+// code.
+func SymbolCodeLengths(frequencies []int) []int {
+	lengths := make([]int, len(frequencies))
+	var leaves []*leafNode
+	for symbol, freq := range frequencies {
+		if freq > 0 {
+			leaves = append(leaves, &leafNode{Character: byte(symbol), Freq: freq, symbol: symbol})
+		}
+	}
+	switch len(leaves) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[leaves[0].symbol] = 1
+		return lengths
+	}
+
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		if leaf, ok := (*n).(*leafNode); ok {
+			lengths[leaf.symbol] = depth
+			return
+		}
+		for _, child := range (*n).Child() {
+			walk(child, depth+1)
+		}
+	}
+	walk(buildTree(leaves), 0)
+	return lengths
+}
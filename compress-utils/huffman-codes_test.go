@@ -155,8 +155,15 @@ func TestTraverseBTree(t *testing.T) {
 			expectedErr:        false,
 		},
 		{
-			name:               "Invalid root node (leaf node)",
+			name:               "Single leaf",
 			rootNode:           &leafNode{Character: 'a', Freq: 1},
+			expected:           HuffmanCodeTable{'a': "0"},
+			codeToBeCalculated: 1,
+			expectedErr:        false,
+		},
+		{
+			name:               "Empty tree",
+			rootNode:           nil,
 			expected:           nil,
 			codeToBeCalculated: 0,
 			expectedErr:        true,
@@ -190,3 +197,38 @@ func TestTraverseBTree(t *testing.T) {
 		})
 	}
 }
+
+func TestSymbolCodeLengths(t *testing.T) {
+	tests := []struct {
+		name        string
+		frequencies []int
+		expected    []int
+	}{
+		{name: "No symbols", frequencies: []int{0, 0}, expected: []int{0, 0}},
+		{name: "One symbol", frequencies: []int{0, 7, 0}, expected: []int{0, 1, 0}},
+		{name: "Skewed", frequencies: []int{8, 4, 0, 2, 1, 1}, expected: []int{1, 2, 0, 3, 4, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SymbolCodeLengths(tt.frequencies); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("SymbolCodeLengths(%v) = %v, want %v", tt.frequencies, got, tt.expected)
+			}
+		})
+	}
+
+	// Symbols beyond the byte range get codes too.
+	frequencies := make([]int, 300)
+	for i := range frequencies {
+		frequencies[i] = i%7 + 1
+	}
+	lengths := SymbolCodeLengths(frequencies)
+	if _, err := CanonicalCodes(lengths); err != nil {
+		t.Errorf("CanonicalCodes(SymbolCodeLengths()) error = %v", err)
+	}
+	for symbol, length := range lengths {
+		if length == 0 {
+			t.Errorf("symbol %d got no code", symbol)
+		}
+	}
+}
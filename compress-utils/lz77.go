@@ -0,0 +1,181 @@
+package compressutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+const (
+	// LZMinMatch is the shortest match worth replacing with a reference.
+	LZMinMatch = 4
+	// LZMaxOffset is the furthest back a match can reach, so an offset fits
+	// into two bytes.
+	LZMaxOffset = 1<<16 - 1
+
+	lzWindowSize = 1 << 16
+	lzHashBits   = 16
+	// lzMaxChain bounds how many earlier positions with the same hash are
+	// compared, trading ratio for speed on highly repetitive input.
+	lzMaxChain = 48
+	// lzLazyLimit is the match length from which the parser stops looking
+	// for a longer match at the next byte.
+	lzLazyLimit = 32
+)
+
+var ErrInvalidMatch = errors.New("LZ77 match reaches before the start of the output")
+
+// LZSequence is a run of literal bytes followed by a match that repeats
+// MatchLength bytes starting Offset bytes back in the output.
+type LZSequence struct {
+	LiteralLength int
+	MatchLength   int
+	Offset        int
+}
+
+// lzMatcher finds earlier occurrences of the bytes at a position with hash
+// chains over a sliding window. head holds the latest position of every hash
+// and prev links each position to the previous one with the same hash, both
+// stored as position+1 so zero means none.
+type lzMatcher struct {
+	data      []byte
+	head      []int32
+	prev      []int32
+	maxOffset int
+}
+
+func newLZMatcher(data []byte) *lzMatcher {
+	return &lzMatcher{
+		data:      data,
+		head:      make([]int32, 1<<lzHashBits),
+		prev:      make([]int32, lzWindowSize),
+		maxOffset: LZMaxOffset,
+	}
+}
+
+func (m *lzMatcher) hash(pos int) uint32 {
+	return binary.LittleEndian.Uint32(m.data[pos:]) * 2654435761 >> (32 - lzHashBits)
+}
+
+func (m *lzMatcher) insert(pos int) {
+	h := m.hash(pos)
+	m.prev[pos%lzWindowSize] = m.head[h]
+	m.head[h] = int32(pos + 1)
+}
+
+// longestMatch returns the longest earlier match for the bytes at pos, or a
+// length below LZMinMatch if there is none.
+func (m *lzMatcher) longestMatch(pos int) (length, offset int) {
+	candidate := int(m.head[m.hash(pos)]) - 1
+	for chain := 0; candidate >= 0 && chain < lzMaxChain; chain++ {
+		if pos-candidate > m.maxOffset {
+			break
+		}
+		// Only a candidate that beats the best so far at its last byte can
+		// be longer, which skips most of them with one comparison.
+		if length == 0 || (pos+length < len(m.data) && m.data[candidate+length] == m.data[pos+length]) {
+			if n := commonPrefixLength(m.data[candidate:], m.data[pos:]); n > length {
+				length, offset = n, pos-candidate
+			}
+		}
+
+		// prev is a ring, once the chain leaves the window a slot may hold
+		// a newer position.
+		next := int(m.prev[candidate%lzWindowSize]) - 1
+		if next >= candidate {
+			break
+		}
+		candidate = next
+	}
+	return length, offset
+}
+
+func commonPrefixLength(a, b []byte) int {
+	n := 0
+	for len(a) >= n+8 && len(b) >= n+8 {
+		if diff := binary.LittleEndian.Uint64(a[n:]) ^ binary.LittleEndian.Uint64(b[n:]); diff != 0 {
+			return n + bits.TrailingZeros64(diff)/8
+		}
+		n += 8
+	}
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// LZParse splits data into literals and matches with greedy parsing and one
+// step of lazy evaluation: a match is postponed by a byte when the next
+// position has a longer one. Bytes after the last sequence are trailing
+// literals and are not part of any sequence. Positions are kept as int32, so
+// data must be shorter than 2 GiB.
+func LZParse(data []byte) []LZSequence {
+	return LZParseWindow(data, 0, LZMaxOffset, len(data))
+}
+
+// LZParseWindow parses data[start:] like LZParse, with data[:start] as
+// history that matches may refer back to. Offsets are at most maxOffset,
+// which must not exceed LZMaxOffset, and matches at most maxMatch bytes long,
+// as formats like DEFLATE require.
+func LZParseWindow(data []byte, start, maxOffset, maxMatch int) []LZSequence {
+	var sequences []LZSequence
+	if len(data)-start < LZMinMatch {
+		return sequences
+	}
+
+	matcher := newLZMatcher(data)
+	matcher.maxOffset = maxOffset
+	// Hashing reads four bytes, so no match starts after lastHash.
+	lastHash := len(data) - LZMinMatch
+	for pos := max(0, start-maxOffset); pos < start; pos++ {
+		matcher.insert(pos)
+	}
+
+	literalStart := start
+	pos := start
+	for pos <= lastHash {
+		length, offset := matcher.longestMatch(pos)
+		length = min(length, maxMatch)
+		matcher.insert(pos)
+		if length < LZMinMatch {
+			pos++
+			continue
+		}
+		if length < lzLazyLimit && pos+1 <= lastHash {
+			if nextLength, _ := matcher.longestMatch(pos + 1); min(nextLength, maxMatch) > length {
+				pos++
+				continue
+			}
+		}
+
+		sequences = append(sequences, LZSequence{
+			LiteralLength: pos - literalStart,
+			MatchLength:   length,
+			Offset:        offset,
+		})
+		end := pos + length
+		for pos++; pos < end; pos++ {
+			if pos <= lastHash {
+				matcher.insert(pos)
+			}
+		}
+		literalStart = pos
+	}
+	return sequences
+}
+
+// AppendLZMatch appends length bytes copied from offset bytes back in dst.
+// The match may overlap the bytes it produces, which repeats them.
+func AppendLZMatch(dst []byte, offset, length int) ([]byte, error) {
+	if offset <= 0 || offset > len(dst) {
+		return dst, ErrInvalidMatch
+	}
+	start := len(dst) - offset
+	if offset >= length {
+		return append(dst, dst[start:start+length]...), nil
+	}
+	for i := 0; i < length; i++ {
+		dst = append(dst, dst[start+i])
+	}
+	return dst, nil
+}
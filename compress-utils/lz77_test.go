@@ -0,0 +1,135 @@
+package compressutils
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func lzReplay(t *testing.T, data []byte, sequences []LZSequence) []byte {
+	t.Helper()
+
+	var out []byte
+	pos := 0
+	for _, seq := range sequences {
+		if seq.MatchLength < LZMinMatch || seq.Offset > LZMaxOffset {
+			t.Fatalf("sequence %+v out of range", seq)
+		}
+		out = append(out, data[pos:pos+seq.LiteralLength]...)
+		var err error
+		if out, err = AppendLZMatch(out, seq.Offset, seq.MatchLength); err != nil {
+			t.Fatalf("AppendLZMatch(%d, %d) error = %v", seq.Offset, seq.MatchLength, err)
+		}
+		pos += seq.LiteralLength + seq.MatchLength
+	}
+	return append(out, data[pos:]...)
+}
+
+func TestLZParse(t *testing.T) {
+	random := make([]byte, 50000)
+	rand.New(rand.NewSource(1)).Read(random)
+	// Far repeats reach past the window and must not be referenced.
+	farRepeat := append(bytes.Clone(random[:1000]), make([]byte, LZMaxOffset)...)
+	farRepeat = append(farRepeat, random[:1000]...)
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: nil},
+		{name: "Shorter than a match", input: []byte("abc")},
+		{name: "No repeats", input: []byte("abcdefghijklmnopqrstuvwxyz")},
+		{name: "Run of one byte", input: bytes.Repeat([]byte("a"), 1000)},
+		{name: "Repeated phrase", input: []byte(strings.Repeat(`{"level":"info","msg":"served"}`+"\n", 500))},
+		{name: "Random", input: random},
+		{name: "Beyond the window", input: farRepeat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sequences := LZParse(tt.input)
+			if got := lzReplay(t, tt.input, sequences); !bytes.Equal(got, tt.input) {
+				t.Errorf("replaying %d sequences does not give back the input", len(sequences))
+			}
+		})
+	}
+}
+
+func TestLZParseWindow(t *testing.T) {
+	history := []byte(strings.Repeat("history that the next chunk repeats. ", 50))
+	chunk := []byte(strings.Repeat("history that the next chunk repeats. ", 3) + "and a new tail")
+	data := append(bytes.Clone(history), chunk...)
+
+	const maxOffset, maxMatch = 1000, 20
+	sequences := LZParseWindow(data, len(history), maxOffset, maxMatch)
+	if len(sequences) == 0 || sequences[0].LiteralLength != 0 {
+		t.Fatalf("LZParseWindow() = %+v, want the chunk to start with a match into the history", sequences)
+	}
+
+	out := bytes.Clone(history)
+	pos := len(history)
+	for _, seq := range sequences {
+		if seq.Offset > maxOffset || seq.MatchLength > maxMatch {
+			t.Fatalf("sequence %+v exceeds the limits", seq)
+		}
+		out = append(out, data[pos:pos+seq.LiteralLength]...)
+		out, _ = AppendLZMatch(out, seq.Offset, seq.MatchLength)
+		pos += seq.LiteralLength + seq.MatchLength
+	}
+	out = append(out, data[pos:]...)
+	if !bytes.Equal(out, data) {
+		t.Error("replaying the sequences after the history does not give back the chunk")
+	}
+}
+
+func TestLZParseFindsMatches(t *testing.T) {
+	input := []byte(strings.Repeat("the same line again and again\n", 100))
+	sequences := LZParse(input)
+
+	matched := 0
+	for _, seq := range sequences {
+		matched += seq.MatchLength
+	}
+	if matched < len(input)*9/10 {
+		t.Errorf("matched %d of %d bytes, want at least 90%%", matched, len(input))
+	}
+}
+
+func TestAppendLZMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		dst      string
+		offset   int
+		length   int
+		expected string
+		err      error
+	}{
+		{name: "Copy", dst: "abcdef", offset: 6, length: 3, expected: "abcdefabc"},
+		{name: "Overlapping run", dst: "xab", offset: 2, length: 5, expected: "xabababa"},
+		{name: "Offset too far", dst: "abc", offset: 4, length: 4, err: ErrInvalidMatch},
+		{name: "Zero offset", dst: "abc", offset: 0, length: 4, err: ErrInvalidMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AppendLZMatch([]byte(tt.dst), tt.offset, tt.length)
+			if err != tt.err {
+				t.Fatalf("AppendLZMatch() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && string(got) != tt.expected {
+				t.Errorf("AppendLZMatch() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func BenchmarkLZParse(b *testing.B) {
+	text := benchmarkText()
+	b.SetBytes(int64(len(text)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		LZParse(text)
+	}
+}
@@ -0,0 +1,136 @@
+package compressutils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultMaxCodeLength is the code length limit used unless another one is
+// given. Codes of at most 15 bits fit any table decoder and the bit buffers
+// of DEFLATE.
+const DefaultMaxCodeLength = 15
+
+var ErrCodeLengthLimit = errors.New("too many symbols for the code length limit")
+
+// LimitedCodeLengths returns optimal code lengths of at most maxLength bits
+// for frequencies indexed by symbol. Symbols with a zero frequency get no
+// code and a lone symbol gets a one bit code. When the Huffman tree is
+// shallow enough its lengths are used as they are, otherwise they are
+// computed with the package-merge algorithm. It fails if more symbols occur
+// than codes of maxLength bits can tell apart.
+func LimitedCodeLengths(frequencies []int, maxLength int) ([]int, error) {
+	symbols := 0
+	for _, freq := range frequencies {
+		if freq > 0 {
+			symbols++
+		}
+	}
+	if maxLength < 1 || maxLength > MaxCodeLength || (maxLength < 62 && symbols > 1<<maxLength) {
+		return nil, fmt.Errorf("%w: %d symbols, %d bits", ErrCodeLengthLimit, symbols, maxLength)
+	}
+
+	lengths := SymbolCodeLengths(frequencies)
+	for _, length := range lengths {
+		if length > maxLength {
+			return packageMerge(frequencies, maxLength), nil
+		}
+	}
+	return lengths, nil
+}
+
+// CodeLengthsForFrequency is LimitedCodeLengths for a byte frequency.
+func CodeLengthsForFrequency(frequency Frequency, maxLength int) (CodeLengthTable, error) {
+	frequencies := make([]int, 256)
+	for char, freq := range frequency {
+		frequencies[char] = freq
+	}
+	lengths, err := LimitedCodeLengths(frequencies, maxLength)
+	if err != nil {
+		return nil, err
+	}
+
+	codeLengths := make(CodeLengthTable, len(frequency))
+	for char := range frequency {
+		if lengths[char] > 0 {
+			codeLengths[char] = lengths[char]
+		}
+	}
+	return codeLengths, nil
+}
+
+// coin is an item of package-merge: a symbol, or a package of two cheaper
+// items from the level below.
+type coin struct {
+	weight      int
+	symbol      int
+	left, right *coin
+}
+
+// packageMerge solves the length-limited coding problem as a coin
+// collector's problem. Every symbol is a coin on each of the maxLength
+// levels. Going from the deepest level up, the coins of a level are paired
+// into packages that join the symbol coins of the next level. The cheapest
+// 2n-2 items of the top level form the solution, and the code length of a
+// symbol is how many of its coins they contain. Needs at least two symbols.
+func packageMerge(frequencies []int, maxLength int) []int {
+	var leaves []*coin
+	for symbol, freq := range frequencies {
+		if freq > 0 {
+			leaves = append(leaves, &coin{weight: freq, symbol: symbol})
+		}
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		if leaves[i].weight != leaves[j].weight {
+			return leaves[i].weight < leaves[j].weight
+		}
+		return leaves[i].symbol < leaves[j].symbol
+	})
+
+	var merged []*coin
+	var packages []*coin
+	for level := 0; level < maxLength; level++ {
+		merged = mergeCoins(leaves, packages)
+		packages = packages[:0:0]
+		for i := 0; i+1 < len(merged); i += 2 {
+			packages = append(packages, &coin{
+				weight: merged[i].weight + merged[i+1].weight,
+				symbol: -1,
+				left:   merged[i],
+				right:  merged[i+1],
+			})
+		}
+	}
+
+	lengths := make([]int, len(frequencies))
+	var count func(c *coin)
+	count = func(c *coin) {
+		if c.left == nil {
+			lengths[c.symbol]++
+			return
+		}
+		count(c.left)
+		count(c.right)
+	}
+	for _, c := range merged[:2*len(leaves)-2] {
+		count(c)
+	}
+	return lengths
+}
+
+// mergeCoins merges two lists sorted by weight. Symbols go before packages of
+// the same weight, which keeps the codes as short as possible.
+func mergeCoins(leaves, packages []*coin) []*coin {
+	merged := make([]*coin, 0, len(leaves)+len(packages))
+	for len(leaves) > 0 && len(packages) > 0 {
+		if packages[0].weight < leaves[0].weight {
+			merged = append(merged, packages[0])
+			packages = packages[1:]
+		} else {
+			merged = append(merged, leaves[0])
+			leaves = leaves[1:]
+		}
+	}
+	merged = append(merged, leaves...)
+	return append(merged, packages...)
+}
@@ -0,0 +1,155 @@
+package compressutils
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func fibonacciFrequencies(n int) []int {
+	frequencies := make([]int, n)
+	frequencies[0], frequencies[1] = 1, 1
+	for i := 2; i < n; i++ {
+		frequencies[i] = frequencies[i-1] + frequencies[i-2]
+	}
+	return frequencies
+}
+
+func codeCost(frequencies, lengths []int) int {
+	cost := 0
+	for symbol, freq := range frequencies {
+		cost += freq * lengths[symbol]
+	}
+	return cost
+}
+
+// kraftSum returns the Kraft sum of lengths in units of 2^-maxLength, a
+// complete code sums to exactly 1<<maxLength.
+func kraftSum(lengths []int, maxLength int) int {
+	sum := 0
+	for _, length := range lengths {
+		if length > 0 {
+			sum += 1 << (maxLength - length)
+		}
+	}
+	return sum
+}
+
+func TestLimitedCodeLengths(t *testing.T) {
+	tests := []struct {
+		name        string
+		frequencies []int
+		maxLength   int
+		expected    []int
+	}{
+		{name: "No symbols", frequencies: []int{0, 0}, maxLength: 15, expected: []int{0, 0}},
+		{name: "One symbol", frequencies: []int{0, 3}, maxLength: 1, expected: []int{0, 1}},
+		{name: "Within the limit", frequencies: []int{8, 4, 0, 2, 1, 1}, maxLength: 4, expected: []int{1, 2, 0, 3, 4, 4}},
+		{name: "Limited", frequencies: []int{8, 4, 0, 2, 1, 1}, maxLength: 3, expected: []int{1, 3, 0, 3, 3, 3}},
+		{name: "Fibonacci limited to 3 bits", frequencies: fibonacciFrequencies(6), maxLength: 3, expected: []int{3, 3, 3, 3, 2, 2}},
+		{name: "Flat", frequencies: []int{1, 1, 1, 1}, maxLength: 2, expected: []int{2, 2, 2, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LimitedCodeLengths(tt.frequencies, tt.maxLength)
+			if err != nil {
+				t.Fatalf("LimitedCodeLengths() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("LimitedCodeLengths(%v, %d) = %v, want %v", tt.frequencies, tt.maxLength, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLimitedCodeLengthsFibonacci(t *testing.T) {
+	// Fibonacci frequencies build a tree as deep as there are symbols.
+	frequencies := fibonacciFrequencies(40)
+	if got := maxCodeLength(SymbolCodeLengths(frequencies)); got != 39 {
+		t.Fatalf("Huffman tree depth = %d, want 39", got)
+	}
+
+	previousCost := 0
+	for _, maxLength := range []int{6, 8, 12, 15, 20, 39} {
+		lengths, err := LimitedCodeLengths(frequencies, maxLength)
+		if err != nil {
+			t.Fatalf("LimitedCodeLengths(%d) error = %v", maxLength, err)
+		}
+		if got := maxCodeLength(lengths); got > maxLength {
+			t.Errorf("LimitedCodeLengths(%d) longest code = %d bits", maxLength, got)
+		}
+		if got := kraftSum(lengths, maxLength); got != 1<<maxLength {
+			t.Errorf("LimitedCodeLengths(%d) is not a complete code", maxLength)
+		}
+		if _, err := CanonicalCodes(lengths); err != nil {
+			t.Errorf("CanonicalCodes(LimitedCodeLengths(%d)) error = %v", maxLength, err)
+		}
+
+		// A looser limit can only lower the cost.
+		cost := codeCost(frequencies, lengths)
+		if previousCost != 0 && cost > previousCost {
+			t.Errorf("LimitedCodeLengths(%d) costs %d bits, more than %d with a tighter limit", maxLength, cost, previousCost)
+		}
+		previousCost = cost
+	}
+}
+
+func TestPackageMergeIsOptimal(t *testing.T) {
+	// Without a binding limit package-merge has to match the cost of the
+	// Huffman code.
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		frequencies := make([]int, 2+random.Intn(40))
+		for symbol := range frequencies {
+			frequencies[symbol] = random.Intn(1000)
+		}
+		frequencies[0], frequencies[1] = 1+frequencies[0], 1+frequencies[1]
+
+		huffman := codeCost(frequencies, SymbolCodeLengths(frequencies))
+		if got := codeCost(frequencies, packageMerge(frequencies, len(frequencies))); got != huffman {
+			t.Fatalf("packageMerge(%v) costs %d bits, Huffman %d", frequencies, got, huffman)
+		}
+	}
+}
+
+func TestLimitedCodeLengthsErrors(t *testing.T) {
+	for _, maxLength := range []int{0, 2, MaxCodeLength + 1} {
+		if _, err := LimitedCodeLengths([]int{1, 1, 1, 1, 1}, maxLength); !errors.Is(err, ErrCodeLengthLimit) {
+			t.Errorf("LimitedCodeLengths(%d) error = %v, want %v", maxLength, err, ErrCodeLengthLimit)
+		}
+	}
+}
+
+func TestCodeLengthsForFrequency(t *testing.T) {
+	frequency := Frequency{}
+	for i, freq := range fibonacciFrequencies(30) {
+		frequency[byte('a'+i)] = freq
+	}
+
+	codeLengths, err := CodeLengthsForFrequency(frequency, DefaultMaxCodeLength)
+	if err != nil {
+		t.Fatalf("CodeLengthsForFrequency() error = %v", err)
+	}
+	if len(codeLengths) != len(frequency) {
+		t.Errorf("CodeLengthsForFrequency() has %d codes, want %d", len(codeLengths), len(frequency))
+	}
+	codes, err := GenerateCanonicalHuffmanCodes(codeLengths)
+	if err != nil {
+		t.Fatalf("GenerateCanonicalHuffmanCodes() error = %v", err)
+	}
+	for char, code := range codes {
+		if len(code) > DefaultMaxCodeLength {
+			t.Errorf("code of %q is %d bits long", char, len(code))
+		}
+	}
+}
+
+func maxCodeLength(lengths []int) int {
+	longest := 0
+	for _, length := range lengths {
+		longest = max(longest, length)
+	}
+	return longest
+}
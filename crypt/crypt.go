@@ -0,0 +1,253 @@
+// Package crypt encrypts and authenticates compactor files. A random file key
+// encrypts the data, and the header carries that key wrapped once for every
+// recipient, so a file can be opened with any of several passwords or keys
+// without encrypting the data more than once. Recipients are passwords,
+// stretched with scrypt, or X25519 public keys.
+//
+//	zw, err := crypt.Encrypt(&buf, crypt.NewScryptRecipient(password))
+//	zw.Write(compressed)
+//	zw.Close()
+//
+//	zr, err := crypt.Decrypt(&buf, crypt.NewScryptIdentity(password))
+//	io.Copy(os.Stdout, zr)
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// File layout:
+//
+//	magic           4 bytes "CPTE"
+//	version         1 byte
+//	stanza count    uvarint
+//	stanzas         per recipient: type (1 byte), body length (uvarint),
+//	                body, which holds the file key wrapped for the recipient
+//	header MAC      32 bytes HMAC-SHA-256 of everything above, keyed with
+//	                HKDF-SHA-256(file key, info "header")
+//	payload nonce   16 random bytes
+//	payload         the data in authenticated chunks, see stream.go
+//
+// The MAC makes any change to the stanzas fail even for an identity that
+// could unwrap the file key from an untouched stanza.
+const (
+	Magic   = "CPTE"
+	Version = 1
+
+	fileKeySize      = 32
+	headerMACSize    = sha256.Size
+	payloadNonceSize = 16
+	// maxStanzas bounds the header a reader accepts.
+	maxStanzas    = 1 << 10
+	maxStanzaBody = 1 << 12
+)
+
+var (
+	ErrNotEncrypted   = errors.New("crypt: not an encrypted compactor file")
+	ErrIncorrectKey   = errors.New("crypt: wrong password or key, no identity can decrypt the file")
+	ErrAuthentication = errors.New("crypt: authentication failed, the file was modified or is truncated")
+	ErrCorruptHeader  = errors.New("crypt: corrupt header")
+)
+
+// Stanza types.
+const (
+	stanzaScrypt uint8 = 1 + iota
+	stanzaX25519
+)
+
+// stanza is the file key wrapped for one recipient.
+type stanza struct {
+	kind uint8
+	body []byte
+}
+
+// Recipient wraps a file key so that the matching Identity can unwrap it.
+type Recipient interface {
+	wrap(fileKey []byte) (*stanza, error)
+}
+
+// Identity unwraps the file key from a stanza it matches. It returns
+// errNoMatch for stanzas of other recipients.
+type Identity interface {
+	unwrap(s *stanza) ([]byte, error)
+}
+
+var errNoMatch = errors.New("crypt: identity does not match")
+
+// IsEncrypted reports whether data starts like an encrypted file.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(Magic))
+}
+
+func deriveKey(fileKey, salt []byte, info string) []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, fileKey, salt, []byte(info)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func headerMAC(fileKey, header []byte) []byte {
+	mac := hmac.New(sha256.New, deriveKey(fileKey, nil, "header"))
+	mac.Write(header)
+	return mac.Sum(nil)
+}
+
+// Encrypt writes the header to w and returns a WriteCloser whose input is
+// encrypted to w. Close must be called to write the final chunk, it does not
+// close w.
+func Encrypt(w io.Writer, recipients ...Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("crypt: no recipients")
+	}
+
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte(Magic), Version)
+	header = binary.AppendUvarint(header, uint64(len(recipients)))
+	for _, recipient := range recipients {
+		s, err := recipient.wrap(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, s.kind)
+		header = binary.AppendUvarint(header, uint64(len(s.body)))
+		header = append(header, s.body...)
+	}
+	header = append(header, headerMAC(fileKey, header)...)
+
+	nonce := make([]byte, payloadNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(header, nonce...)); err != nil {
+		return nil, err
+	}
+	return newStreamWriter(w, deriveKey(fileKey, nonce, "payload"))
+}
+
+// Decrypt reads the header from r, unwraps the file key with the first
+// identity that matches a stanza and returns a Reader for the decrypted data.
+// Reads fail with ErrAuthentication when the data was modified.
+func Decrypt(r io.Reader, identities ...Identity) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	// header collects the bytes the MAC covers.
+	var header bytes.Buffer
+	tr := io.TeeReader(br, &header)
+
+	magic := make([]byte, len(Magic)+1)
+	if _, err := io.ReadFull(tr, magic); err != nil || !IsEncrypted(magic) {
+		return nil, ErrNotEncrypted
+	}
+	if magic[len(Magic)] != Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrCorruptHeader, magic[len(Magic)])
+	}
+
+	stanzas, err := readStanzas(byteReader{tr})
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey, err := unwrapFileKey(stanzas, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := make([]byte, headerMACSize)
+	if _, err := io.ReadFull(br, mac); err != nil {
+		return nil, headerError(err)
+	}
+	if !hmac.Equal(mac, headerMAC(fileKey, header.Bytes())) {
+		return nil, fmt.Errorf("%w: header MAC mismatch", ErrAuthentication)
+	}
+
+	nonce := make([]byte, payloadNonceSize)
+	if _, err := io.ReadFull(br, nonce); err != nil {
+		return nil, headerError(err)
+	}
+	return newStreamReader(br, deriveKey(fileKey, nonce, "payload"))
+}
+
+// byteReader adds io.ByteReader to the tee of the header.
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r, b[:])
+	return b[0], err
+}
+
+func readStanzas(r byteReader) ([]*stanza, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, headerError(err)
+	}
+	if count == 0 || count > maxStanzas {
+		return nil, fmt.Errorf("%w: %d recipients", ErrCorruptHeader, count)
+	}
+
+	stanzas := make([]*stanza, count)
+	for i := range stanzas {
+		s := &stanza{}
+		if s.kind, err = r.ReadByte(); err != nil {
+			return nil, headerError(err)
+		}
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, headerError(err)
+		}
+		if length > maxStanzaBody {
+			return nil, fmt.Errorf("%w: stanza of %d bytes", ErrCorruptHeader, length)
+		}
+		s.body = make([]byte, length)
+		if _, err := io.ReadFull(r, s.body); err != nil {
+			return nil, headerError(err)
+		}
+		stanzas[i] = s
+	}
+	return stanzas, nil
+}
+
+// unwrapFileKey tries every identity on every stanza. Errors other than a
+// mismatch, like a work factor above the limit, are reported if nothing
+// matches.
+func unwrapFileKey(stanzas []*stanza, identities []Identity) ([]byte, error) {
+	var firstErr error
+	for _, identity := range identities {
+		for _, s := range stanzas {
+			fileKey, err := identity.unwrap(s)
+			if err == nil {
+				return fileKey, nil
+			}
+			if err != errNoMatch && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, ErrIncorrectKey
+}
+
+func headerError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: unexpected end of header", ErrCorruptHeader)
+	}
+	return err
+}
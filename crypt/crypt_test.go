@@ -0,0 +1,129 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testRecipient is a fast password recipient for tests.
+func testRecipient(password string) Recipient {
+	r := NewScryptRecipient([]byte(password))
+	r.SetWorkFactor(4)
+	return r
+}
+
+func encrypt(t *testing.T, data []byte, recipients ...Recipient) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw, err := Encrypt(&buf, recipients...)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decrypt(encrypted []byte, identities ...Identity) ([]byte, error) {
+	zr, err := Decrypt(bytes.NewReader(encrypted), identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{name: "Empty", input: nil},
+		{name: "Short", input: []byte("compactor")},
+		{name: "Exactly one chunk", input: bytes.Repeat([]byte{'a'}, chunkSize)},
+		{name: "Several chunks", input: []byte(strings.Repeat("0123456789", 3*chunkSize/10+7))},
+		{name: "Exactly two chunks", input: bytes.Repeat([]byte{'b'}, 2*chunkSize)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted := encrypt(t, tt.input, testRecipient("secret"))
+			if !IsEncrypted(encrypted) {
+				t.Error("IsEncrypted() = false, want true")
+			}
+			if len(tt.input) > 16 && bytes.Contains(encrypted, tt.input[:16]) {
+				t.Error("the encrypted file contains the plaintext")
+			}
+
+			got, err := decrypt(encrypted, NewScryptIdentity([]byte("secret")))
+			if err != nil {
+				t.Fatalf("decrypt() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.input) {
+				t.Errorf("round trip of %d bytes mismatch", len(tt.input))
+			}
+		})
+	}
+}
+
+func TestMultipleRecipients(t *testing.T) {
+	encrypted := encrypt(t, []byte("shared"), testRecipient("alice"), testRecipient("bob"))
+	for _, password := range []string{"alice", "bob"} {
+		got, err := decrypt(encrypted, NewScryptIdentity([]byte(password)))
+		if err != nil || string(got) != "shared" {
+			t.Errorf("decrypt() with %q = %q, %v, want %q", password, got, err, "shared")
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	input := bytes.Repeat([]byte("tamper evident "), chunkSize/8)
+	valid := encrypt(t, input, testRecipient("secret"))
+	headerEnd := len(Magic) + 1 + 1 + 1 + 1 + scryptSaltSize + 1 + fileKeySize + tagSize
+
+	flip := func(i int) []byte {
+		data := bytes.Clone(valid)
+		data[i] ^= 1
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		password string
+		wantErr  error
+	}{
+		{name: "Wrong password", data: valid, password: "guess", wantErr: ErrIncorrectKey},
+		{name: "Not encrypted", data: []byte("CPTR plain"), password: "secret", wantErr: ErrNotEncrypted},
+		{name: "Truncated header", data: valid[:10], password: "secret", wantErr: ErrCorruptHeader},
+		{name: "Work factor changed", data: flip(len(Magic) + 1 + 1 + 1 + 1 + scryptSaltSize), password: "secret", wantErr: ErrIncorrectKey},
+		{name: "Header MAC changed", data: flip(headerEnd), password: "secret", wantErr: ErrAuthentication},
+		{name: "Payload changed", data: flip(len(valid) - 100), password: "secret", wantErr: ErrAuthentication},
+		{name: "First chunk changed", data: flip(headerEnd + headerMACSize + payloadNonceSize + 5), password: "secret", wantErr: ErrAuthentication},
+		{name: "Truncated at a chunk boundary", data: valid[:headerEnd+headerMACSize+payloadNonceSize+chunkSize+tagSize], password: "secret", wantErr: ErrAuthentication},
+		{name: "Truncated in a chunk", data: valid[:len(valid)-1], password: "secret", wantErr: ErrAuthentication},
+		{name: "Appended data", data: append(bytes.Clone(valid), 0), password: "secret", wantErr: ErrAuthentication},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decrypt(tt.data, NewScryptIdentity([]byte(tt.password)))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptWithoutRecipients(t *testing.T) {
+	if _, err := Encrypt(io.Discard); err == nil {
+		t.Error("Encrypt() error = nil, want an error")
+	}
+}
@@ -0,0 +1,119 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Scrypt stanza body:
+//
+//	salt            16 bytes
+//	work factor     1 byte, log2 of the scrypt cost parameter N
+//	wrapped key     the file key sealed with ChaCha20-Poly1305 under
+//	                scrypt(password, label+salt, N, r=8, p=1) and a zero nonce
+//
+// Every stanza has its own salt, so the wrapping key is never reused.
+const (
+	scryptLabel    = "compactor/scrypt\x00"
+	scryptSaltSize = 16
+	scryptR        = 8
+	scryptP        = 1
+
+	// DefaultScryptWorkFactor takes about a second on a current machine and
+	// 256 MiB of memory.
+	DefaultScryptWorkFactor = 18
+	// MaxScryptWorkFactor is the highest work factor a ScryptIdentity
+	// accepts by default, which keeps a crafted file from taking hours.
+	MaxScryptWorkFactor = 22
+)
+
+// ScryptRecipient encrypts with a password.
+type ScryptRecipient struct {
+	password   []byte
+	workFactor int
+}
+
+func NewScryptRecipient(password []byte) *ScryptRecipient {
+	return &ScryptRecipient{password: password, workFactor: DefaultScryptWorkFactor}
+}
+
+// SetWorkFactor sets log2 of the scrypt cost parameter, between 1 and 30.
+// Every step doubles the time and memory needed to try a password.
+func (r *ScryptRecipient) SetWorkFactor(logN int) {
+	if logN < 1 || logN > 30 {
+		panic("crypt: scrypt work factor out of range")
+	}
+	r.workFactor = logN
+}
+
+func scryptWrappingKey(password, salt []byte, logN int) ([]byte, error) {
+	return scrypt.Key(password, append([]byte(scryptLabel), salt...), 1<<logN, scryptR, scryptP, chacha20poly1305.KeySize)
+}
+
+func (r *ScryptRecipient) wrap(fileKey []byte) (*stanza, error) {
+	salt := make([]byte, scryptSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key, err := scryptWrappingKey(r.password, salt, r.workFactor)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	body := append(salt, byte(r.workFactor))
+	body = aead.Seal(body, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+	return &stanza{kind: stanzaScrypt, body: body}, nil
+}
+
+// ScryptIdentity decrypts files encrypted with a password.
+type ScryptIdentity struct {
+	password      []byte
+	maxWorkFactor int
+}
+
+func NewScryptIdentity(password []byte) *ScryptIdentity {
+	return &ScryptIdentity{password: password, maxWorkFactor: MaxScryptWorkFactor}
+}
+
+// SetMaxWorkFactor sets the highest work factor the identity tries.
+func (i *ScryptIdentity) SetMaxWorkFactor(logN int) {
+	i.maxWorkFactor = logN
+}
+
+func (i *ScryptIdentity) unwrap(s *stanza) ([]byte, error) {
+	if s.kind != stanzaScrypt {
+		return nil, errNoMatch
+	}
+	if len(s.body) != scryptSaltSize+1+fileKeySize+tagSize {
+		return nil, fmt.Errorf("%w: invalid scrypt stanza", ErrCorruptHeader)
+	}
+	salt, logN, wrapped := s.body[:scryptSaltSize], int(s.body[scryptSaltSize]), s.body[scryptSaltSize+1:]
+	if logN < 1 || logN > 30 {
+		return nil, fmt.Errorf("%w: scrypt work factor %d", ErrCorruptHeader, logN)
+	}
+	if logN > i.maxWorkFactor {
+		return nil, fmt.Errorf("crypt: scrypt work factor %d is above the limit of %d", logN, i.maxWorkFactor)
+	}
+
+	key, err := scryptWrappingKey(i.password, salt, logN)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+	if err != nil {
+		// A wrong password is just another identity that does not match.
+		return nil, errNoMatch
+	}
+	return fileKey, nil
+}
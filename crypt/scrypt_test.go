@@ -0,0 +1,64 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestScryptWrap(t *testing.T) {
+	fileKey := bytes.Repeat([]byte{7}, fileKeySize)
+	r := NewScryptRecipient([]byte("password"))
+	r.SetWorkFactor(4)
+
+	s, err := r.wrap(fileKey)
+	if err != nil {
+		t.Fatalf("wrap() error = %v", err)
+	}
+	if s.kind != stanzaScrypt || int(s.body[scryptSaltSize]) != 4 {
+		t.Errorf("wrap() = kind %d, work factor %d, want %d, %d", s.kind, s.body[scryptSaltSize], stanzaScrypt, 4)
+	}
+
+	// The salt is random, wrapping twice gives different stanzas.
+	other, _ := r.wrap(fileKey)
+	if bytes.Equal(s.body, other.body) {
+		t.Error("wrap() returned the same stanza twice")
+	}
+
+	tests := []struct {
+		name     string
+		identity *ScryptIdentity
+		stanza   *stanza
+		wantErr  error
+		// fails is set for errors that have no sentinel.
+		fails bool
+	}{
+		{name: "Right password", identity: NewScryptIdentity([]byte("password")), stanza: s},
+		{name: "Wrong password", identity: NewScryptIdentity([]byte("passw0rd")), stanza: s, wantErr: errNoMatch},
+		{name: "Other stanza type", identity: NewScryptIdentity([]byte("password")), stanza: &stanza{kind: 99, body: s.body}, wantErr: errNoMatch},
+		{name: "Short body", identity: NewScryptIdentity([]byte("password")), stanza: &stanza{kind: stanzaScrypt, body: s.body[:20]}, wantErr: ErrCorruptHeader},
+		{name: "Work factor above limit", identity: func() *ScryptIdentity {
+			identity := NewScryptIdentity([]byte("password"))
+			identity.SetMaxWorkFactor(3)
+			return identity
+		}(), stanza: s, fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.identity.unwrap(tt.stanza)
+			if tt.fails {
+				if err == nil {
+					t.Error("unwrap() error = nil, want an error")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unwrap() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, fileKey) {
+				t.Errorf("unwrap() = %x, want %x", got, fileKey)
+			}
+		})
+	}
+}
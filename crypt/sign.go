@@ -0,0 +1,315 @@
+package crypt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Signature trailer, appended to a file of any kind by NewSigner:
+//
+//	public key      32 bytes, the Ed25519 key of the signer
+//	signature       64 bytes Ed25519ph over the SHA-512 of everything
+//	                before the trailer, with context signatureContext
+//	version         1 byte
+//	magic           4 bytes "CPTS"
+//
+// The trailer has a fixed size at the very end, so it is found without
+// parsing the file and a stream can be signed as it is written. The public
+// key only says which key to check with, a signature counts only if that
+// key is trusted.
+const (
+	SignatureMagic   = "CPTS"
+	SignatureVersion = 1
+	// SignatureSize is the length of the trailer.
+	SignatureSize = ed25519.PublicKeySize + ed25519.SignatureSize + 1 + len(SignatureMagic)
+
+	signatureContext = "compactor/signature"
+
+	VerifyingKeyPrefix = "compactor-sign-pub-"
+	SigningKeyPrefix   = "COMPACTOR-SIGN-SECRET-KEY-"
+)
+
+var (
+	ErrNotSigned        = errors.New("crypt: the file is not signed")
+	ErrUntrustedSigner  = errors.New("crypt: the file is signed by a key that is not trusted")
+	ErrInvalidSignature = errors.New("crypt: invalid signature, the file was modified after signing")
+)
+
+var signatureOptions = &ed25519.Options{Hash: crypto.SHA512, Context: signatureContext}
+
+// SigningKey signs files.
+type SigningKey struct {
+	privateKey ed25519.PrivateKey
+}
+
+// GenerateSigningKey creates a new random signing key.
+func GenerateSigningKey() (*SigningKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SigningKey{privateKey: privateKey}, nil
+}
+
+// ParseSigningKey parses a signing key as returned by String.
+func ParseSigningKey(s string) (*SigningKey, error) {
+	seed, err := parseKey(s, SigningKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: invalid signing key: %w", err)
+	}
+	return &SigningKey{privateKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// String returns the key in the form ParseSigningKey accepts.
+func (k *SigningKey) String() string {
+	return SigningKeyPrefix + keyEncoding.EncodeToString(k.privateKey.Seed())
+}
+
+// VerifyingKey returns the public key that checks the signatures of k.
+func (k *SigningKey) VerifyingKey() *VerifyingKey {
+	return &VerifyingKey{publicKey: k.privateKey.Public().(ed25519.PublicKey)}
+}
+
+// VerifyingKey checks signatures.
+type VerifyingKey struct {
+	publicKey ed25519.PublicKey
+}
+
+// ParseVerifyingKey parses a public key as returned by String.
+func ParseVerifyingKey(s string) (*VerifyingKey, error) {
+	key, err := parseKey(s, VerifyingKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: invalid verifying key: %w", err)
+	}
+	return &VerifyingKey{publicKey: key}, nil
+}
+
+// String returns the key in the form ParseVerifyingKey accepts.
+func (k *VerifyingKey) String() string {
+	return VerifyingKeyPrefix + keyEncoding.EncodeToString(k.publicKey)
+}
+
+// ParseSigningKeys reads signing keys in the format of ParseRecipients.
+func ParseSigningKeys(r io.Reader) ([]*SigningKey, error) {
+	var keys []*SigningKey
+	err := parseKeyLines(r, func(line string) error {
+		key, err := ParseSigningKey(line)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("crypt: no signing keys found")
+	}
+	return keys, nil
+}
+
+// ParseVerifyingKeys reads public keys in the format of ParseRecipients.
+func ParseVerifyingKeys(r io.Reader) ([]*VerifyingKey, error) {
+	var keys []*VerifyingKey
+	err := parseKeyLines(r, func(line string) error {
+		key, err := ParseVerifyingKey(line)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("crypt: no verifying keys found")
+	}
+	return keys, nil
+}
+
+type signer struct {
+	w      io.Writer
+	key    *SigningKey
+	digest hash.Hash
+	closed bool
+}
+
+// NewSigner returns a WriteCloser that passes its input through to w and
+// appends the signature trailer on Close. It does not close w.
+func NewSigner(w io.Writer, key *SigningKey) io.WriteCloser {
+	return &signer{w: w, key: key, digest: sha512.New()}
+}
+
+func (s *signer) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, ErrClosed
+	}
+	n, err := s.w.Write(p)
+	s.digest.Write(p[:n])
+	return n, err
+}
+
+func (s *signer) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	signature, err := s.key.privateKey.Sign(nil, s.digest.Sum(nil), signatureOptions)
+	if err != nil {
+		return err
+	}
+	trailer := make([]byte, 0, SignatureSize)
+	trailer = append(trailer, s.key.privateKey.Public().(ed25519.PublicKey)...)
+	trailer = append(trailer, signature...)
+	trailer = append(trailer, SignatureVersion)
+	trailer = append(trailer, SignatureMagic...)
+	_, err = s.w.Write(trailer)
+	return err
+}
+
+// Signature is a parsed signature trailer.
+type Signature struct {
+	publicKey ed25519.PublicKey
+	signature []byte
+}
+
+// parseSignature returns the signature in trailer, the last SignatureSize
+// bytes of a file. Only the magic together with the version marks a
+// trailer, anything else is the end of an unsigned file and ErrNotSigned.
+func parseSignature(trailer []byte) (*Signature, error) {
+	if len(trailer) != SignatureSize || !bytes.HasSuffix(trailer, []byte(SignatureMagic)) ||
+		trailer[ed25519.PublicKeySize+ed25519.SignatureSize] != SignatureVersion {
+		return nil, ErrNotSigned
+	}
+	return &Signature{
+		publicKey: bytes.Clone(trailer[:ed25519.PublicKeySize]),
+		signature: bytes.Clone(trailer[ed25519.PublicKeySize : ed25519.PublicKeySize+ed25519.SignatureSize]),
+	}, nil
+}
+
+// ReadSignature reads the signature trailer of the size bytes in r. It
+// fails with ErrNotSigned when there is none.
+func ReadSignature(r io.ReaderAt, size int64) (*Signature, error) {
+	if size < int64(SignatureSize) {
+		return nil, ErrNotSigned
+	}
+	trailer := make([]byte, SignatureSize)
+	if _, err := r.ReadAt(trailer, size-int64(SignatureSize)); err != nil {
+		return nil, err
+	}
+	return parseSignature(trailer)
+}
+
+// Signer returns the key the file claims to be signed with. It is only
+// vouched for once Verify succeeds with it among the trusted keys.
+func (s *Signature) Signer() *VerifyingKey {
+	return &VerifyingKey{publicKey: s.publicKey}
+}
+
+// Verify checks the signature over content, the file without its trailer,
+// and that it was made by one of the trusted keys.
+func (s *Signature) Verify(content io.Reader, trusted ...*VerifyingKey) error {
+	digest := sha512.New()
+	if _, err := io.Copy(digest, content); err != nil {
+		return err
+	}
+	return s.verifyDigest(digest.Sum(nil), trusted)
+}
+
+func (s *Signature) verifyDigest(digest []byte, trusted []*VerifyingKey) error {
+	trustedKey := false
+	for _, key := range trusted {
+		if key.publicKey.Equal(s.publicKey) {
+			trustedKey = true
+			break
+		}
+	}
+	if !trustedKey {
+		return fmt.Errorf("%w: %s", ErrUntrustedSigner, s.Signer())
+	}
+	if err := ed25519.VerifyWithOptions(s.publicKey, digest, s.signature, signatureOptions); err != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// SignedReader reads a file that may end in a signature trailer, from a
+// stream that cannot seek. It holds back the last SignatureSize bytes read,
+// and at the end drops them if they are a trailer, so Read returns the file
+// without its signature.
+type SignedReader struct {
+	r      io.Reader
+	digest hash.Hash
+	data   []byte
+	// buf is the part of data read but not yet returned. Until the end of r
+	// it is refilled before it gets down to SignatureSize bytes.
+	buf       []byte
+	eof       bool
+	signature *Signature
+}
+
+func NewSignedReader(r io.Reader) *SignedReader {
+	return &SignedReader{r: r, digest: sha512.New(), data: make([]byte, chunkSize)}
+}
+
+func (sr *SignedReader) Read(p []byte) (int, error) {
+	if !sr.eof && len(sr.buf) <= SignatureSize {
+		sr.buf = sr.data[:copy(sr.data, sr.buf)]
+		for !sr.eof && len(sr.buf) <= SignatureSize {
+			n, err := sr.r.Read(sr.data[len(sr.buf):])
+			sr.buf = sr.data[:len(sr.buf)+n]
+			if err == io.EOF {
+				sr.eof = true
+				sr.stripSignature()
+			} else if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	available := len(sr.buf)
+	if !sr.eof {
+		available -= SignatureSize
+	}
+	if available == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, sr.buf[:available])
+	sr.digest.Write(p[:n])
+	sr.buf = sr.buf[n:]
+	return n, nil
+}
+
+// stripSignature drops the trailer from the end of buf, if there is one.
+func (sr *SignedReader) stripSignature() {
+	if len(sr.buf) < SignatureSize {
+		return
+	}
+	signature, err := parseSignature(sr.buf[len(sr.buf)-SignatureSize:])
+	if err != nil {
+		return
+	}
+	sr.signature = signature
+	sr.buf = sr.buf[:len(sr.buf)-SignatureSize]
+}
+
+// Verify checks the signature like Signature.Verify. It can only be called
+// once Read returned io.EOF.
+func (sr *SignedReader) Verify(trusted ...*VerifyingKey) error {
+	if !sr.eof || len(sr.buf) > 0 {
+		return errors.New("crypt: Verify called before the end of the file")
+	}
+	if sr.signature == nil {
+		return ErrNotSigned
+	}
+	return sr.signature.verifyDigest(sr.digest.Sum(nil), trusted)
+}
@@ -0,0 +1,156 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func sign(t *testing.T, data []byte, key *SigningKey) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	sw := NewSigner(&buf, key)
+	if _, err := sw.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSigningKeyStrings(t *testing.T) {
+	key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+
+	parsed, err := ParseSigningKey(key.String())
+	if err != nil {
+		t.Fatalf("ParseSigningKey() error = %v", err)
+	}
+	if !parsed.privateKey.Equal(key.privateKey) {
+		t.Error("ParseSigningKey() does not return the key String() encoded")
+	}
+
+	verifyingKey, err := ParseVerifyingKey(key.VerifyingKey().String())
+	if err != nil {
+		t.Fatalf("ParseVerifyingKey() error = %v", err)
+	}
+	if !verifyingKey.publicKey.Equal(key.VerifyingKey().publicKey) {
+		t.Error("ParseVerifyingKey() does not return the key String() encoded")
+	}
+
+	// Encryption and signing keys are not interchangeable.
+	identity, _ := GenerateX25519Identity()
+	if _, err := ParseVerifyingKey(identity.Recipient().String()); err == nil {
+		t.Error("ParseVerifyingKey() of an X25519 key error = nil, want an error")
+	}
+	if _, err := ParseSigningKey(identity.String()); err == nil {
+		t.Error("ParseSigningKey() of an X25519 key error = nil, want an error")
+	}
+}
+
+func TestSignatureVerify(t *testing.T) {
+	key, _ := GenerateSigningKey()
+	other, _ := GenerateSigningKey()
+	data := []byte(strings.Repeat("release artifact ", 1000))
+	signed := sign(t, data, key)
+
+	if len(signed) != len(data)+SignatureSize || !bytes.HasPrefix(signed, data) {
+		t.Fatalf("NewSigner() wrote %d bytes, want the %d input bytes and the trailer", len(signed), len(data))
+	}
+
+	flip := func(i int) []byte {
+		data := bytes.Clone(signed)
+		data[i] ^= 1
+		return data
+	}
+
+	tests := []struct {
+		name    string
+		file    []byte
+		trusted []*VerifyingKey
+		wantErr error
+	}{
+		{name: "Valid", file: signed, trusted: []*VerifyingKey{key.VerifyingKey()}},
+		{name: "One of several trusted keys", file: signed, trusted: []*VerifyingKey{other.VerifyingKey(), key.VerifyingKey()}},
+		{name: "Untrusted signer", file: signed, trusted: []*VerifyingKey{other.VerifyingKey()}, wantErr: ErrUntrustedSigner},
+		{name: "No trusted keys", file: signed, wantErr: ErrUntrustedSigner},
+		{name: "Content changed", file: flip(10), trusted: []*VerifyingKey{key.VerifyingKey()}, wantErr: ErrInvalidSignature},
+		{name: "Signature changed", file: flip(len(data) + 40), trusted: []*VerifyingKey{key.VerifyingKey()}, wantErr: ErrInvalidSignature},
+		{name: "Signer replaced", file: func() []byte {
+			file := bytes.Clone(signed)
+			copy(file[len(data):], other.VerifyingKey().publicKey)
+			return file
+		}(), trusted: []*VerifyingKey{key.VerifyingKey(), other.VerifyingKey()}, wantErr: ErrInvalidSignature},
+		{name: "Not signed", file: data, trusted: []*VerifyingKey{key.VerifyingKey()}, wantErr: ErrNotSigned},
+		{name: "Shorter than a trailer", file: []byte("CPTS"), trusted: []*VerifyingKey{key.VerifyingKey()}, wantErr: ErrNotSigned},
+		// An unsigned file can end in the magic by chance, only the version
+		// next to it makes a trailer.
+		{name: "Ends in the magic", file: append(bytes.Clone(data), SignatureVersion+1, 'C', 'P', 'T', 'S'), trusted: []*VerifyingKey{key.VerifyingKey()}, wantErr: ErrNotSigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := int64(len(tt.file))
+			signature, err := ReadSignature(bytes.NewReader(tt.file), size)
+			if err == nil {
+				err = signature.Verify(io.NewSectionReader(bytes.NewReader(tt.file), 0, size-int64(SignatureSize)), tt.trusted...)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// The streaming reader comes to the same result.
+			sr := NewSignedReader(iotest.HalfReader(bytes.NewReader(tt.file)))
+			content, err := io.ReadAll(sr)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if tt.wantErr != ErrNotSigned && !bytes.Equal(content, tt.file[:len(tt.file)-SignatureSize]) {
+				t.Error("SignedReader does not strip the trailer")
+			}
+			if tt.wantErr == ErrNotSigned && !bytes.Equal(content, tt.file) {
+				t.Error("SignedReader changed an unsigned file")
+			}
+			if err := sr.Verify(tt.trusted...); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SignedReader.Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignedReaderSizes(t *testing.T) {
+	key, _ := GenerateSigningKey()
+	for _, size := range []int{0, 1, SignatureSize - 1, SignatureSize, SignatureSize + 1, chunkSize - SignatureSize, chunkSize, 3*chunkSize + 5} {
+		data := bytes.Repeat([]byte{0xa5}, size)
+		for _, file := range [][]byte{data, sign(t, data, key)} {
+			sr := NewSignedReader(iotest.OneByteReader(bytes.NewReader(file)))
+			got, err := io.ReadAll(sr)
+			if err != nil {
+				t.Fatalf("ReadAll() of %d bytes error = %v", len(file), err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("SignedReader of %d bytes returned %d bytes, want %d", len(file), len(got), len(data))
+			}
+		}
+	}
+
+	if err := NewSignedReader(bytes.NewReader(nil)).Verify(); err == nil {
+		t.Error("Verify() before the end error = nil, want an error")
+	}
+}
+
+func TestSignerClosed(t *testing.T) {
+	key, _ := GenerateSigningKey()
+	sw := NewSigner(io.Discard, key)
+	sw.Close()
+	if _, err := sw.Write([]byte("late")); err != ErrClosed {
+		t.Errorf("Write() after Close() error = %v, want %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,196 @@
+package crypt
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// The payload is cut into chunks of chunkSize bytes, only the last may be
+// shorter, and every chunk is sealed with ChaCha20-Poly1305. The nonce is an
+// 11 byte big-endian chunk counter followed by a byte that is 1 for the last
+// chunk and 0 otherwise, so chunks cannot be reordered, dropped or appended,
+// and cutting the file at a chunk boundary is detected. Empty data is a
+// single empty last chunk.
+const (
+	chunkSize     = 64 << 10
+	lastChunkFlag = 1
+	tagSize       = chacha20poly1305.Overhead
+)
+
+var ErrClosed = errors.New("crypt: writer is closed")
+
+// streamNonce returns the nonce of chunk counter.
+func streamNonce(nonce []byte, counter uint64, last bool) {
+	clear(nonce)
+	for i := len(nonce) - 2; counter > 0; i-- {
+		nonce[i] = byte(counter)
+		counter >>= 8
+	}
+	if last {
+		nonce[len(nonce)-1] = lastChunkFlag
+	}
+}
+
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	// buf holds the plaintext of the next chunk, with room for the tag.
+	buf []byte
+
+	closed bool
+	err    error
+}
+
+func newStreamWriter(w io.Writer, key []byte) (*streamWriter, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &streamWriter{
+		w:     w,
+		aead:  aead,
+		nonce: make([]byte, chacha20poly1305.NonceSize),
+		buf:   make([]byte, 0, chunkSize+tagSize),
+	}, nil
+}
+
+// Write encrypts p. A full chunk is only sealed once more data follows,
+// since the last chunk is sealed differently.
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, ErrClosed
+	}
+	if sw.err != nil {
+		return 0, sw.err
+	}
+
+	written := len(p)
+	for len(p) > 0 {
+		if len(sw.buf) == chunkSize {
+			if sw.err = sw.seal(false); sw.err != nil {
+				return 0, sw.err
+			}
+		}
+		n := min(len(p), chunkSize-len(sw.buf))
+		sw.buf = append(sw.buf, p[:n]...)
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (sw *streamWriter) seal(last bool) error {
+	if sw.counter == math.MaxUint64 {
+		return errors.New("crypt: too much data for one file")
+	}
+	streamNonce(sw.nonce, sw.counter, last)
+	sealed := sw.aead.Seal(sw.buf[:0], sw.nonce, sw.buf, nil)
+	sw.counter++
+	sw.buf = sw.buf[:0]
+	_, err := sw.w.Write(sealed)
+	return err
+}
+
+// Close seals the last chunk. It does not close the underlying writer.
+func (sw *streamWriter) Close() error {
+	if sw.closed {
+		return sw.err
+	}
+	sw.closed = true
+	if sw.err != nil {
+		return sw.err
+	}
+	sw.err = sw.seal(true)
+	return sw.err
+}
+
+type streamReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	// buf holds sealed input, plain the opened chunk not yet returned.
+	buf   []byte
+	plain []byte
+	last  bool
+	err   error
+}
+
+func newStreamReader(r io.Reader, key []byte) (*streamReader, error) {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{
+		r:     r,
+		aead:  aead,
+		nonce: make([]byte, chacha20poly1305.NonceSize),
+		buf:   make([]byte, chunkSize+tagSize),
+	}, nil
+}
+
+func (sr *streamReader) Read(p []byte) (int, error) {
+	for len(sr.plain) == 0 {
+		if sr.err != nil {
+			return 0, sr.err
+		}
+		if sr.last {
+			return 0, io.EOF
+		}
+		sr.err = sr.open()
+	}
+
+	n := copy(p, sr.plain)
+	sr.plain = sr.plain[n:]
+	return n, nil
+}
+
+// open reads and opens the next chunk. A full chunk may be the last one,
+// which only the nonce tells, so both are tried.
+func (sr *streamReader) open() error {
+	n, err := io.ReadFull(sr.r, sr.buf)
+	switch {
+	case err == io.EOF:
+		return fmt.Errorf("%w: missing last chunk", ErrAuthentication)
+	case err == io.ErrUnexpectedEOF:
+		if n < tagSize {
+			return fmt.Errorf("%w: truncated chunk", ErrAuthentication)
+		}
+	case err != nil:
+		return err
+	}
+	sealed := sr.buf[:n]
+
+	if n == len(sr.buf) {
+		streamNonce(sr.nonce, sr.counter, false)
+		if plain, err := sr.aead.Open(sealed[:0:0], sr.nonce, sealed, nil); err == nil {
+			sr.plain = plain
+			sr.counter++
+			return nil
+		}
+	}
+
+	streamNonce(sr.nonce, sr.counter, true)
+	plain, err := sr.aead.Open(sealed[:0:0], sr.nonce, sealed, nil)
+	if err != nil {
+		return fmt.Errorf("%w: chunk %d", ErrAuthentication, sr.counter)
+	}
+	// Only empty data has an empty last chunk, anything after the last
+	// chunk was appended.
+	if len(plain) == 0 && sr.counter > 0 {
+		return fmt.Errorf("%w: empty last chunk", ErrAuthentication)
+	}
+	var extra [1]byte
+	if n, _ := io.ReadFull(sr.r, extra[:]); n > 0 {
+		return fmt.Errorf("%w: data after the last chunk", ErrAuthentication)
+	}
+	sr.plain = plain
+	sr.last = true
+	return nil
+}
@@ -0,0 +1,82 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestStreamNonce(t *testing.T) {
+	tests := []struct {
+		name     string
+		counter  uint64
+		last     bool
+		expected []byte
+	}{
+		{name: "First", counter: 0, expected: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "First and last", counter: 0, last: true, expected: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}},
+		{name: "Counter", counter: 0x0102, expected: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce := bytes.Repeat([]byte{0xff}, 12)
+			streamNonce(nonce, tt.counter, tt.last)
+			if !bytes.Equal(nonce, tt.expected) {
+				t.Errorf("streamNonce(%d, %v) = %v, want %v", tt.counter, tt.last, nonce, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStreamChunkLayout(t *testing.T) {
+	key := make([]byte, 32)
+	tests := []struct {
+		name   string
+		size   int
+		chunks int
+	}{
+		{name: "Empty", size: 0, chunks: 1},
+		{name: "Partial chunk", size: 100, chunks: 1},
+		{name: "Full chunk", size: chunkSize, chunks: 1},
+		{name: "Full chunk and one byte", size: chunkSize + 1, chunks: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			sw, err := newStreamWriter(&buf, key)
+			if err != nil {
+				t.Fatalf("newStreamWriter() error = %v", err)
+			}
+			sw.Write(make([]byte, tt.size))
+			if err := sw.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+			if want := tt.size + tt.chunks*tagSize; buf.Len() != want {
+				t.Errorf("sealed size = %d, want %d", buf.Len(), want)
+			}
+
+			sr, err := newStreamReader(&buf, key)
+			if err != nil {
+				t.Fatalf("newStreamReader() error = %v", err)
+			}
+			got, err := io.ReadAll(sr)
+			if err != nil || len(got) != tt.size {
+				t.Errorf("ReadAll() = %d bytes, %v, want %d bytes", len(got), err, tt.size)
+			}
+		})
+	}
+}
+
+func TestStreamWriterClosed(t *testing.T) {
+	sw, err := newStreamWriter(io.Discard, make([]byte, 32))
+	if err != nil {
+		t.Fatalf("newStreamWriter() error = %v", err)
+	}
+	sw.Close()
+	if _, err := sw.Write([]byte("late")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Write() after Close() error = %v, want %v", err, ErrClosed)
+	}
+}
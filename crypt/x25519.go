@@ -0,0 +1,231 @@
+package crypt
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// X25519 stanza body:
+//
+//	ephemeral share 32 bytes, the public key of a key pair made for this
+//	                stanza only
+//	wrapped key     the file key sealed with ChaCha20-Poly1305 under
+//	                HKDF-SHA-256(shared secret, salt ephemeral share ||
+//	                recipient public key, info label) and a zero nonce
+//
+// The stanza does not name its recipient, so an identity tries every X25519
+// stanza of a file. Keys are written as their prefix followed by the 32 key
+// bytes in unpadded base64url.
+const (
+	x25519Label = "compactor/x25519"
+
+	PublicKeyPrefix = "compactor-pub-"
+	SecretKeyPrefix = "COMPACTOR-SECRET-KEY-"
+)
+
+var keyEncoding = base64.RawURLEncoding.Strict()
+
+// X25519Recipient encrypts to the holder of an X25519Identity.
+type X25519Recipient struct {
+	publicKey []byte
+}
+
+// ParseX25519Recipient parses a public key as returned by String.
+func ParseX25519Recipient(s string) (*X25519Recipient, error) {
+	key, err := parseKey(s, PublicKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: invalid public key: %w", err)
+	}
+	return &X25519Recipient{publicKey: key}, nil
+}
+
+// String returns the public key in the form ParseX25519Recipient accepts.
+func (r *X25519Recipient) String() string {
+	return PublicKeyPrefix + keyEncoding.EncodeToString(r.publicKey)
+}
+
+func parseKey(s, prefix string) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(s, prefix)
+	if !ok {
+		return nil, fmt.Errorf("missing %q prefix", prefix)
+	}
+	key, err := keyEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != curve25519.ScalarSize {
+		return nil, fmt.Errorf("%d bytes instead of %d", len(key), curve25519.ScalarSize)
+	}
+	return key, nil
+}
+
+// x25519WrappingKey derives the key the file key is sealed with from the
+// shared secret of the ephemeral and the recipient key.
+func x25519WrappingKey(sharedSecret, ephemeralShare, publicKey []byte) []byte {
+	salt := append(append([]byte{}, ephemeralShare...), publicKey...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, salt, []byte(x25519Label)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func (r *X25519Recipient) wrap(fileKey []byte) (*stanza, error) {
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(ephemeral); err != nil {
+		return nil, err
+	}
+	ephemeralShare, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	// X25519 fails for low order public keys, whose shared secret would be
+	// known to anyone.
+	sharedSecret, err := curve25519.X25519(ephemeral, r.publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: invalid public key: %w", err)
+	}
+
+	aead, err := chacha20poly1305.New(x25519WrappingKey(sharedSecret, ephemeralShare, r.publicKey))
+	if err != nil {
+		return nil, err
+	}
+	body := aead.Seal(ephemeralShare, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+	return &stanza{kind: stanzaX25519, body: body}, nil
+}
+
+// X25519Identity decrypts files encrypted to its Recipient.
+type X25519Identity struct {
+	secretKey []byte
+	publicKey []byte
+}
+
+// GenerateX25519Identity creates a new random key pair.
+func GenerateX25519Identity() (*X25519Identity, error) {
+	secretKey := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(secretKey); err != nil {
+		return nil, err
+	}
+	return newX25519Identity(secretKey)
+}
+
+// ParseX25519Identity parses a secret key as returned by String.
+func ParseX25519Identity(s string) (*X25519Identity, error) {
+	secretKey, err := parseKey(s, SecretKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: invalid secret key: %w", err)
+	}
+	return newX25519Identity(secretKey)
+}
+
+func newX25519Identity(secretKey []byte) (*X25519Identity, error) {
+	publicKey, err := curve25519.X25519(secretKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &X25519Identity{secretKey: secretKey, publicKey: publicKey}, nil
+}
+
+// Recipient returns the public key that encrypts to i.
+func (i *X25519Identity) Recipient() *X25519Recipient {
+	return &X25519Recipient{publicKey: i.publicKey}
+}
+
+// String returns the secret key in the form ParseX25519Identity accepts.
+func (i *X25519Identity) String() string {
+	return SecretKeyPrefix + keyEncoding.EncodeToString(i.secretKey)
+}
+
+func (i *X25519Identity) unwrap(s *stanza) ([]byte, error) {
+	if s.kind != stanzaX25519 {
+		return nil, errNoMatch
+	}
+	if len(s.body) != curve25519.PointSize+fileKeySize+tagSize {
+		return nil, fmt.Errorf("%w: invalid X25519 stanza", ErrCorruptHeader)
+	}
+	ephemeralShare, wrapped := s.body[:curve25519.PointSize], s.body[curve25519.PointSize:]
+
+	sharedSecret, err := curve25519.X25519(i.secretKey, ephemeralShare)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid X25519 ephemeral share", ErrCorruptHeader)
+	}
+	aead, err := chacha20poly1305.New(x25519WrappingKey(sharedSecret, ephemeralShare, i.publicKey))
+	if err != nil {
+		return nil, err
+	}
+	fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), wrapped, nil)
+	if err != nil {
+		// The stanza is for another recipient.
+		return nil, errNoMatch
+	}
+	return fileKey, nil
+}
+
+// ParseRecipients reads public keys, one per line. Empty lines and lines
+// starting with "#" are skipped.
+func ParseRecipients(r io.Reader) ([]Recipient, error) {
+	var recipients []Recipient
+	err := parseKeyLines(r, func(line string) error {
+		recipient, err := ParseX25519Recipient(line)
+		if err != nil {
+			return err
+		}
+		recipients = append(recipients, recipient)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("crypt: no public keys found")
+	}
+	return recipients, nil
+}
+
+// ParseIdentities reads secret keys in the format of ParseRecipients, such
+// as a file written by "compactor keygen".
+func ParseIdentities(r io.Reader) ([]Identity, error) {
+	var identities []Identity
+	err := parseKeyLines(r, func(line string) error {
+		identity, err := ParseX25519Identity(line)
+		if err != nil {
+			return err
+		}
+		identities = append(identities, identity)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("crypt: no secret keys found")
+	}
+	return identities, nil
+}
+
+func parseKeyLines(r io.Reader, parse func(line string) error) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := parse(line); err != nil {
+			// The line itself is not repeated, it may hold a secret key.
+			return fmt.Errorf("line %d: %w", n+1, err)
+		}
+	}
+	return nil
+}
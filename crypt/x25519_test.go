@@ -0,0 +1,173 @@
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestX25519KeyStrings(t *testing.T) {
+	identity, err := GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity() error = %v", err)
+	}
+
+	parsed, err := ParseX25519Identity(identity.String())
+	if err != nil {
+		t.Fatalf("ParseX25519Identity() error = %v", err)
+	}
+	if !bytes.Equal(parsed.secretKey, identity.secretKey) || !bytes.Equal(parsed.publicKey, identity.publicKey) {
+		t.Error("ParseX25519Identity() does not return the identity String() encoded")
+	}
+
+	recipient, err := ParseX25519Recipient(identity.Recipient().String())
+	if err != nil {
+		t.Fatalf("ParseX25519Recipient() error = %v", err)
+	}
+	if !bytes.Equal(recipient.publicKey, identity.publicKey) {
+		t.Error("ParseX25519Recipient() does not return the key String() encoded")
+	}
+
+	invalid := []string{
+		"",
+		identity.String(),
+		strings.TrimPrefix(identity.Recipient().String(), PublicKeyPrefix),
+		identity.Recipient().String()[:len(identity.Recipient().String())-2],
+		identity.Recipient().String() + "AA",
+		PublicKeyPrefix + "not*base64*at*all*not*base64*at*all*not*base",
+	}
+	for _, s := range invalid {
+		if _, err := ParseX25519Recipient(s); err == nil {
+			t.Errorf("ParseX25519Recipient(%q) error = nil, want an error", s)
+		}
+	}
+}
+
+func TestX25519RoundTrip(t *testing.T) {
+	alice, _ := GenerateX25519Identity()
+	bob, _ := GenerateX25519Identity()
+	eve, _ := GenerateX25519Identity()
+
+	encrypted := encrypt(t, []byte("for the team"), alice.Recipient(), bob.Recipient(), testRecipient("fallback"))
+
+	tests := []struct {
+		name       string
+		identities []Identity
+		wantErr    error
+	}{
+		{name: "First recipient", identities: []Identity{alice}},
+		{name: "Second recipient", identities: []Identity{bob}},
+		{name: "Password next to keys", identities: []Identity{NewScryptIdentity([]byte("fallback"))}},
+		{name: "One of several identities", identities: []Identity{eve, bob}},
+		{name: "Not a recipient", identities: []Identity{eve}, wantErr: ErrIncorrectKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decrypt(encrypted, tt.identities...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decrypt() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && string(got) != "for the team" {
+				t.Errorf("decrypt() = %q, want %q", got, "for the team")
+			}
+		})
+	}
+}
+
+func TestX25519Unwrap(t *testing.T) {
+	fileKey := bytes.Repeat([]byte{9}, fileKeySize)
+	identity, _ := GenerateX25519Identity()
+	s, err := identity.Recipient().wrap(fileKey)
+	if err != nil {
+		t.Fatalf("wrap() error = %v", err)
+	}
+
+	// A fresh ephemeral key is used every time.
+	other, _ := identity.Recipient().wrap(fileKey)
+	if bytes.Equal(s.body, other.body) {
+		t.Error("wrap() returned the same stanza twice")
+	}
+
+	tests := []struct {
+		name    string
+		stanza  *stanza
+		wantErr error
+	}{
+		{name: "Valid", stanza: s},
+		{name: "Other stanza type", stanza: &stanza{kind: stanzaScrypt, body: s.body}, wantErr: errNoMatch},
+		{name: "Short body", stanza: &stanza{kind: stanzaX25519, body: s.body[:40]}, wantErr: ErrCorruptHeader},
+		{name: "Low order share", stanza: &stanza{kind: stanzaX25519, body: append(make([]byte, 32), s.body[32:]...)}, wantErr: ErrCorruptHeader},
+		{name: "Wrapped key changed", stanza: &stanza{kind: stanzaX25519, body: append(bytes.Clone(s.body[:len(s.body)-1]), s.body[len(s.body)-1]^1)}, wantErr: errNoMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := identity.unwrap(tt.stanza)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("unwrap() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(got, fileKey) {
+				t.Errorf("unwrap() = %x, want %x", got, fileKey)
+			}
+		})
+	}
+}
+
+func TestParseRecipients(t *testing.T) {
+	alice, _ := GenerateX25519Identity()
+	bob, _ := GenerateX25519Identity()
+
+	tests := []struct {
+		name  string
+		input string
+		want  int
+		fails bool
+	}{
+		{name: "One key", input: alice.Recipient().String() + "\n", want: 1},
+		{name: "Comments and blank lines", input: "# team\n\n" + alice.Recipient().String() + "\r\n  " + bob.Recipient().String() + "  \n# end", want: 2},
+		{name: "Empty", input: "# nobody\n", fails: true},
+		{name: "Secret key", input: alice.String(), fails: true},
+		{name: "Invalid line", input: alice.Recipient().String() + "\ngarbage\n", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRecipients(strings.NewReader(tt.input))
+			if tt.fails {
+				if err == nil {
+					t.Error("ParseRecipients() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRecipients() error = %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("ParseRecipients() = %d recipients, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIdentities(t *testing.T) {
+	identity, _ := GenerateX25519Identity()
+	keyFile := "# public key: " + identity.Recipient().String() + "\n" + identity.String() + "\n"
+
+	identities, err := ParseIdentities(strings.NewReader(keyFile))
+	if err != nil {
+		t.Fatalf("ParseIdentities() error = %v", err)
+	}
+	if len(identities) != 1 {
+		t.Fatalf("ParseIdentities() = %d identities, want 1", len(identities))
+	}
+
+	_, err = ParseIdentities(strings.NewReader(identity.Recipient().String()))
+	if err == nil {
+		t.Error("ParseIdentities() of a public key error = nil, want an error")
+	}
+	if err != nil && strings.Contains(err.Error(), identity.Recipient().String()) {
+		t.Error("ParseIdentities() error repeats the key")
+	}
+}
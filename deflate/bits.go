@@ -0,0 +1,152 @@
+package deflate
+
+import (
+	"io"
+	"math/bits"
+)
+
+// DEFLATE packs bits starting at the least significant bit of every byte, the
+// opposite of the compactor format, and stores Huffman codes bit reversed so
+// they can be read one bit at a time from the low end.
+
+func reverseBits(code uint64, length uint) uint64 {
+	return bits.Reverse64(code) >> (64 - length)
+}
+
+// bitWriter packs bits LSB first.
+type bitWriter struct {
+	w     io.Writer
+	acc   uint64
+	nbits uint
+	buf   []byte
+	err   error
+}
+
+func newBitWriter(w io.Writer) *bitWriter {
+	return &bitWriter{w: w, buf: make([]byte, 0, 32*1024+8)}
+}
+
+// writeBits appends the low n bits of value, n at most 32.
+func (bw *bitWriter) writeBits(value uint64, n uint) {
+	bw.acc |= (value & (1<<n - 1)) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+	if len(bw.buf) >= 32*1024 {
+		bw.flushBuffer()
+	}
+}
+
+// writeCode writes a Huffman code, most significant bit first.
+func (bw *bitWriter) writeCode(code uint64, length uint) {
+	bw.writeBits(reverseBits(code, length), length)
+}
+
+// align pads the current byte with zero bits.
+func (bw *bitWriter) align() {
+	if bw.nbits > 0 {
+		bw.writeBits(0, 8-bw.nbits)
+	}
+}
+
+func (bw *bitWriter) writeBytes(p []byte) {
+	bw.buf = append(bw.buf, p...)
+	if len(bw.buf) >= 32*1024 {
+		bw.flushBuffer()
+	}
+}
+
+func (bw *bitWriter) flushBuffer() {
+	if bw.err == nil && len(bw.buf) > 0 {
+		_, bw.err = bw.w.Write(bw.buf)
+	}
+	bw.buf = bw.buf[:0]
+}
+
+// flush aligns to a byte and writes everything buffered.
+func (bw *bitWriter) flush() error {
+	bw.align()
+	bw.flushBuffer()
+	return bw.err
+}
+
+// bitReader reads bits LSB first. It only reads the bytes it needs from r,
+// so whatever follows the DEFLATE stream, like a gzip trailer, can be read
+// with readAlignedByte.
+type bitReader struct {
+	r     io.ByteReader
+	acc   uint64
+	nbits uint
+	err   error
+}
+
+// fill tries to buffer at least n bits and reports how many are buffered.
+func (br *bitReader) fill(n uint) uint {
+	for br.nbits < n && br.err == nil {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			br.err = err
+			break
+		}
+		br.acc |= uint64(b) << br.nbits
+		br.nbits += 8
+	}
+	return br.nbits
+}
+
+func (br *bitReader) shortRead() error {
+	if br.err == nil || br.err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return br.err
+}
+
+// readBits reads n bits, n at most 32.
+func (br *bitReader) readBits(n uint) (uint64, error) {
+	if br.fill(n) < n {
+		return 0, br.shortRead()
+	}
+	value := br.acc & (1<<n - 1)
+	br.acc >>= n
+	br.nbits -= n
+	return value, nil
+}
+
+// align drops the bits left in the current byte.
+func (br *bitReader) align() {
+	drop := br.nbits % 8
+	br.acc >>= drop
+	br.nbits -= drop
+}
+
+// readAlignedByte reads a whole byte once the reader is aligned.
+func (br *bitReader) readAlignedByte() (byte, error) {
+	if br.nbits >= 8 {
+		b := byte(br.acc)
+		br.acc >>= 8
+		br.nbits -= 8
+		return b, nil
+	}
+	if br.err != nil {
+		return 0, br.err
+	}
+	return br.r.ReadByte()
+}
+
+// readAligned fills p with whole bytes once the reader is aligned.
+func (br *bitReader) readAligned(p []byte) error {
+	for i := range p {
+		b, err := br.readAlignedByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		p[i] = b
+	}
+	return nil
+}
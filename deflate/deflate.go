@@ -0,0 +1,116 @@
+// Package deflate reads and writes RFC 1951 DEFLATE streams and RFC 1952 gzip
+// files. The encoder finds matches with the LZ77 parser of compress-utils and
+// builds its dynamic Huffman codes with the same tree builder and canonical
+// code assignment as the compactor format, so the output can be handed to
+// any tool that understands gzip.
+//
+//	zw := deflate.NewGzipWriter(&buf)
+//	zw.Write(data)
+//	zw.Close()
+//
+//	zr, err := deflate.NewGzipReader(&buf)
+//	io.Copy(os.Stdout, zr)
+package deflate
+
+import "errors"
+
+var (
+	ErrCorrupt  = errors.New("deflate: corrupt input")
+	ErrChecksum = errors.New("deflate: checksum mismatch, the file is corrupt")
+	ErrHeader   = errors.New("deflate: not a gzip file")
+	ErrClosed   = errors.New("deflate: writer is closed")
+)
+
+const (
+	// windowSize is how far back a match may reach.
+	windowSize = 1 << 15
+	// maxMatchLength is the longest match a length code can express.
+	maxMatchLength = 258
+	// maxCodeBits is the longest literal/length or distance code.
+	maxCodeBits = 15
+	// maxCodeLengthBits is the longest code of the code length alphabet.
+	maxCodeLengthBits = 7
+	// maxStoredBlock is the most bytes a stored block can hold.
+	maxStoredBlock = 1<<16 - 1
+
+	endOfBlock       = 256
+	literalLengthMax = 286
+	distanceMax      = 30
+	codeLengthMax    = 19
+)
+
+// Block types.
+const (
+	blockStored = iota
+	blockFixed
+	blockDynamic
+)
+
+// lengthBase and lengthExtra describe the length codes 257 to 285.
+var (
+	lengthBase = [29]int{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31,
+		35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258,
+	}
+	lengthExtra = [29]uint{
+		0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2,
+		3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0,
+	}
+)
+
+// distanceBase and distanceExtra describe the distance codes 0 to 29.
+var (
+	distanceBase = [30]int{
+		1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193,
+		257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577,
+	}
+	distanceExtra = [30]uint{
+		0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6,
+		7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13,
+	}
+)
+
+// codeLengthOrder is the order the code length code lengths are stored in.
+var codeLengthOrder = [codeLengthMax]int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+// fixedLiteralLengths and fixedDistanceLengths are the code lengths of fixed
+// Huffman blocks.
+var fixedLiteralLengths, fixedDistanceLengths = func() ([]int, []int) {
+	literals := make([]int, 288)
+	for symbol := range literals {
+		switch {
+		case symbol < 144:
+			literals[symbol] = 8
+		case symbol < 256:
+			literals[symbol] = 9
+		case symbol < 280:
+			literals[symbol] = 7
+		default:
+			literals[symbol] = 8
+		}
+	}
+	// Distance codes 30 and 31 never occur but take part in the code.
+	distances := make([]int, 32)
+	for symbol := range distances {
+		distances[symbol] = 5
+	}
+	return literals, distances
+}()
+
+// lengthCode returns the length code (0 based, add 257) for a match length.
+func lengthCode(length int) int {
+	code := 0
+	for code+1 < len(lengthBase) && lengthBase[code+1] <= length {
+		code++
+	}
+	return code
+}
+
+// distanceCode returns the distance code for a match distance.
+func distanceCode(distance int) int {
+	code := 0
+	for code+1 < len(distanceBase) && distanceBase[code+1] <= distance {
+		code++
+	}
+	return code
+}
@@ -0,0 +1,286 @@
+package deflate
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+)
+
+// gzip header fields, RFC 1952 section 2.3.
+const (
+	gzipID1     = 0x1f
+	gzipID2     = 0x8b
+	gzipDeflate = 8
+	// gzipUnknownOS is the OS byte for "unknown".
+	gzipUnknownOS = 255
+
+	flagText      = 1 << 0
+	flagHeaderCRC = 1 << 1
+	flagExtra     = 1 << 2
+	flagName      = 1 << 3
+	flagComment   = 1 << 4
+	flagReserved  = 0xe0
+)
+
+// GzipWriter writes a gzip file holding a single DEFLATE stream. The header
+// fields must be set before the first Write.
+type GzipWriter struct {
+	Name    string
+	Comment string
+	ModTime time.Time
+
+	w             io.Writer
+	z             *Writer
+	crc           hash.Hash32
+	size          uint32
+	headerWritten bool
+	closed        bool
+	err           error
+}
+
+func NewGzipWriter(w io.Writer) *GzipWriter {
+	return &GzipWriter{
+		w:   w,
+		crc: crc32.NewIEEE(),
+	}
+}
+
+func (zw *GzipWriter) writeHeader() error {
+	zw.headerWritten = true
+	if strings.ContainsRune(zw.Name, 0) || strings.ContainsRune(zw.Comment, 0) {
+		return ErrHeader
+	}
+
+	header := []byte{gzipID1, gzipID2, gzipDeflate, 0, 0, 0, 0, 0, 0, gzipUnknownOS}
+	if zw.Name != "" {
+		header[3] |= flagName
+	}
+	if zw.Comment != "" {
+		header[3] |= flagComment
+	}
+	if !zw.ModTime.IsZero() && zw.ModTime.Unix() > 0 {
+		binary.LittleEndian.PutUint32(header[4:8], uint32(zw.ModTime.Unix()))
+	}
+	if zw.Name != "" {
+		header = append(append(header, zw.Name...), 0)
+	}
+	if zw.Comment != "" {
+		header = append(append(header, zw.Comment...), 0)
+	}
+	if _, err := zw.w.Write(header); err != nil {
+		return err
+	}
+	zw.z = NewWriter(zw.w)
+	return nil
+}
+
+// Write compresses p.
+func (zw *GzipWriter) Write(p []byte) (int, error) {
+	if zw.closed {
+		return 0, ErrClosed
+	}
+	if zw.err != nil {
+		return 0, zw.err
+	}
+	if !zw.headerWritten {
+		if zw.err = zw.writeHeader(); zw.err != nil {
+			return 0, zw.err
+		}
+	}
+
+	zw.crc.Write(p)
+	zw.size += uint32(len(p))
+	n, err := zw.z.Write(p)
+	zw.err = err
+	return n, err
+}
+
+// Close finishes the DEFLATE stream and writes the trailer. It does not close
+// the underlying writer.
+func (zw *GzipWriter) Close() error {
+	if zw.closed {
+		return zw.err
+	}
+	if zw.err == nil && !zw.headerWritten {
+		zw.err = zw.writeHeader()
+	}
+	zw.closed = true
+	if zw.err != nil {
+		return zw.err
+	}
+
+	if zw.err = zw.z.Close(); zw.err != nil {
+		return zw.err
+	}
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[:4], zw.crc.Sum32())
+	binary.LittleEndian.PutUint32(trailer[4:], zw.size)
+	_, zw.err = zw.w.Write(trailer[:])
+	return zw.err
+}
+
+// GzipReader decompresses a gzip file. A file made of several gzip members,
+// as produced by concatenating gzip files, is read as one stream. The header
+// fields describe the first member.
+type GzipReader struct {
+	Name    string
+	Comment string
+	ModTime time.Time
+
+	br   *bitReader
+	z    *Reader
+	crc  hash.Hash32
+	size uint32
+	err  error
+}
+
+// NewGzipReader reads the gzip header from r and returns a reader for the
+// decompressed data.
+func NewGzipReader(r io.Reader) (*GzipReader, error) {
+	byteReader, ok := r.(io.ByteReader)
+	if !ok {
+		byteReader = bufio.NewReader(r)
+	}
+	zr := &GzipReader{
+		br:  &bitReader{r: byteReader},
+		crc: crc32.NewIEEE(),
+	}
+	if err := zr.readHeader(true); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+func (zr *GzipReader) readByte() (byte, error) {
+	b, err := zr.br.readAlignedByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+func (zr *GzipReader) readString(crc hash.Hash32) (string, error) {
+	var s []byte
+	for {
+		b, err := zr.readByte()
+		if err != nil {
+			return "", err
+		}
+		crc.Write([]byte{b})
+		if b == 0 {
+			return string(s), nil
+		}
+		s = append(s, b)
+	}
+}
+
+// readHeader reads a member header. Only the header of the first member sets
+// the exported fields.
+func (zr *GzipReader) readHeader(first bool) error {
+	header := make([]byte, 10)
+	if err := zr.br.readAligned(header); err != nil {
+		if first && err == io.ErrUnexpectedEOF {
+			return ErrHeader
+		}
+		return err
+	}
+	if header[0] != gzipID1 || header[1] != gzipID2 || header[2] != gzipDeflate || header[3]&flagReserved != 0 {
+		return ErrHeader
+	}
+	flags := header[3]
+	headerCRC := crc32.NewIEEE()
+	headerCRC.Write(header)
+
+	if flags&flagExtra != 0 {
+		var size [2]byte
+		if err := zr.br.readAligned(size[:]); err != nil {
+			return err
+		}
+		extra := make([]byte, binary.LittleEndian.Uint16(size[:]))
+		if err := zr.br.readAligned(extra); err != nil {
+			return err
+		}
+		headerCRC.Write(size[:])
+		headerCRC.Write(extra)
+	}
+	var name, comment string
+	var err error
+	if flags&flagName != 0 {
+		if name, err = zr.readString(headerCRC); err != nil {
+			return err
+		}
+	}
+	if flags&flagComment != 0 {
+		if comment, err = zr.readString(headerCRC); err != nil {
+			return err
+		}
+	}
+	if flags&flagHeaderCRC != 0 {
+		var sum [2]byte
+		if err := zr.br.readAligned(sum[:]); err != nil {
+			return err
+		}
+		if binary.LittleEndian.Uint16(sum[:]) != uint16(headerCRC.Sum32()) {
+			return ErrHeader
+		}
+	}
+
+	if first {
+		zr.Name, zr.Comment = name, comment
+		if mtime := binary.LittleEndian.Uint32(header[4:8]); mtime > 0 {
+			zr.ModTime = time.Unix(int64(mtime), 0)
+		}
+	}
+	zr.z = newReader(zr.br)
+	zr.crc.Reset()
+	zr.size = 0
+	return nil
+}
+
+// Read decompresses data into p.
+func (zr *GzipReader) Read(p []byte) (int, error) {
+	for zr.err == nil {
+		n, err := zr.z.Read(p)
+		zr.crc.Write(p[:n])
+		zr.size += uint32(n)
+		if err == io.EOF {
+			zr.err = zr.nextMember()
+		} else {
+			zr.err = err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+	return 0, zr.err
+}
+
+// nextMember verifies the trailer of the current member and starts the next
+// one, or returns io.EOF at the end of the input.
+func (zr *GzipReader) nextMember() error {
+	zr.br.align()
+	var trailer [8]byte
+	if err := zr.br.readAligned(trailer[:]); err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(trailer[:4]) != zr.crc.Sum32() ||
+		binary.LittleEndian.Uint32(trailer[4:]) != zr.size {
+		return ErrChecksum
+	}
+
+	// Another member may follow.
+	b, err := zr.br.readAlignedByte()
+	if err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return err
+	}
+	zr.br.acc = zr.br.acc<<8 | uint64(b)
+	zr.br.nbits += 8
+	return zr.readHeader(false)
+}
@@ -0,0 +1,138 @@
+package deflate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func gzipBytes(t testing.TB, data []byte, name string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := NewGzipWriter(&buf)
+	zw.Name = name
+	zw.ModTime = time.Unix(1700000000, 0)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := NewGzipReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(zr)
+}
+
+func TestGzipWriterDecodedByCompressGzip(t *testing.T) {
+	for _, tt := range writerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			zr, err := gzip.NewReader(bytes.NewReader(gzipBytes(t, tt.input, "input.txt")))
+			if err != nil {
+				t.Fatalf("gzip.NewReader() error = %v", err)
+			}
+			got, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("compress/gzip error = %v", err)
+			}
+			if !bytes.Equal(got, tt.input) {
+				t.Errorf("compress/gzip decoded %d bytes, want the %d written", len(got), len(tt.input))
+			}
+			if zr.Name != "input.txt" || !zr.ModTime.Equal(time.Unix(1700000000, 0)) {
+				t.Errorf("header = %q %v, want %q %v", zr.Name, zr.ModTime, "input.txt", time.Unix(1700000000, 0))
+			}
+		})
+	}
+}
+
+func TestGzipReaderDecodesCompressGzip(t *testing.T) {
+	input := textInput(3000)
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Name = "logs.txt"
+	gw.Comment = "access logs"
+	gw.Extra = []byte("extra field")
+	gw.Write(input)
+	gw.Close()
+
+	zr, err := NewGzipReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewGzipReader() error = %v", err)
+	}
+	got, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !bytes.Equal(got, input) {
+		t.Errorf("decoded %d bytes, want %d", len(got), len(input))
+	}
+	if zr.Name != "logs.txt" || zr.Comment != "access logs" {
+		t.Errorf("header = %q %q, want %q %q", zr.Name, zr.Comment, "logs.txt", "access logs")
+	}
+}
+
+func TestGzipMultipleMembers(t *testing.T) {
+	first, second := textInput(100), randomInput(5000)
+	var buf bytes.Buffer
+	buf.Write(gzipBytes(t, first, "first"))
+	gw := gzip.NewWriter(&buf)
+	gw.Write(second)
+	gw.Close()
+
+	got, err := gunzip(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if want := append(bytes.Clone(first), second...); !bytes.Equal(got, want) {
+		t.Errorf("decoded %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestGzipReaderErrors(t *testing.T) {
+	valid := gzipBytes(t, textInput(200), "")
+	badCRC := bytes.Clone(valid)
+	badCRC[len(badCRC)-8] ^= 1
+	badSize := bytes.Clone(valid)
+	badSize[len(badSize)-1] ^= 1
+	reserved := bytes.Clone(valid)
+	reserved[3] |= 0x80
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{name: "Empty", input: nil, want: ErrHeader},
+		{name: "Not gzip", input: []byte("CPTR\x03\x00 plain text"), want: ErrHeader},
+		{name: "Reserved flag", input: reserved, want: ErrHeader},
+		{name: "CRC mismatch", input: badCRC, want: ErrChecksum},
+		{name: "Size mismatch", input: badSize, want: ErrChecksum},
+		{name: "Missing trailer", input: valid[:len(valid)-8], want: io.ErrUnexpectedEOF},
+		{name: "Trailing garbage", input: append(bytes.Clone(valid), "garbage!!!!"...), want: ErrHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := gunzip(tt.input); !errors.Is(err, tt.want) {
+				t.Errorf("gunzip() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGzipWriterRejectsNulInName(t *testing.T) {
+	zw := NewGzipWriter(io.Discard)
+	zw.Name = "a\x00b"
+	if _, err := zw.Write([]byte("data")); err != ErrHeader {
+		t.Errorf("Write() error = %v, want %v", err, ErrHeader)
+	}
+}
@@ -0,0 +1,117 @@
+package deflate
+
+import (
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// buildHuffmanEncoder builds the code for frequencies with the compactor
+// tree builder, limited to the maxBits DEFLATE allows with package-merge
+// when the tree is too deep.
+func buildHuffmanEncoder(frequencies []int, maxBits int) (*huffmanEncoder, error) {
+	lengths, err := compressutils.LimitedCodeLengths(frequencies, maxBits)
+	if err != nil {
+		return nil, err
+	}
+	return newHuffmanEncoder(atLeastTwoCodes(lengths))
+}
+
+func maxLength(lengths []int) int {
+	longest := 0
+	for _, length := range lengths {
+		longest = max(longest, length)
+	}
+	return longest
+}
+
+// huffmanEncoder holds the canonical codes of one alphabet.
+type huffmanEncoder struct {
+	codes   []compressutils.Code
+	lengths []int
+}
+
+func newHuffmanEncoder(lengths []int) (*huffmanEncoder, error) {
+	codes, err := compressutils.CanonicalCodes(lengths)
+	if err != nil {
+		return nil, err
+	}
+	return &huffmanEncoder{codes: codes, lengths: lengths}, nil
+}
+
+func (enc *huffmanEncoder) write(bw *bitWriter, symbol int) {
+	code := enc.codes[symbol]
+	bw.writeCode(code.Bits, code.Length)
+}
+
+// cost returns the number of bits the symbols with frequencies take.
+func (enc *huffmanEncoder) cost(frequencies []int) int {
+	bits := 0
+	for symbol, freq := range frequencies {
+		bits += freq * enc.lengths[symbol]
+	}
+	return bits
+}
+
+// huffmanDecoder decodes with a single table indexed by the next maxBits
+// bits of input. Codes are at most 15 bits, so the table has at most 32768
+// entries, each holding symbol<<4 | code length. Unused entries are zero.
+type huffmanDecoder struct {
+	table   []uint32
+	maxBits uint
+}
+
+// newHuffmanDecoder builds the decoder for code lengths indexed by symbol. It
+// rejects over-subscribed lengths, and incomplete ones unless they hold a
+// single code, which RFC 1951 allows for one distance code. Lengths that are
+// all zero give a decoder that fails on every code.
+func newHuffmanDecoder(lengths []int) (*huffmanDecoder, error) {
+	codes, err := compressutils.CanonicalCodes(lengths)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	used := 0
+	space := 0
+	longest := maxLength(lengths)
+	for _, length := range lengths {
+		if length > 0 {
+			used++
+			space += 1 << (longest - length)
+		}
+	}
+	if used > 1 && space != 1<<longest {
+		return nil, ErrCorrupt
+	}
+
+	dec := &huffmanDecoder{
+		table:   make([]uint32, 1<<longest),
+		maxBits: uint(longest),
+	}
+	for symbol, code := range codes {
+		if code.Length == 0 {
+			continue
+		}
+		reversed := reverseBits(code.Bits, code.Length)
+		for index := reversed; index < uint64(len(dec.table)); index += 1 << code.Length {
+			dec.table[index] = uint32(symbol)<<4 | uint32(code.Length)
+		}
+	}
+	return dec, nil
+}
+
+func (dec *huffmanDecoder) decode(br *bitReader) (int, error) {
+	avail := br.fill(dec.maxBits)
+	entry := dec.table[br.acc&(1<<dec.maxBits-1)]
+	length := uint(entry & 15)
+	if length == 0 {
+		if avail < dec.maxBits {
+			return 0, br.shortRead()
+		}
+		return 0, ErrCorrupt
+	}
+	if length > avail {
+		return 0, br.shortRead()
+	}
+	br.acc >>= length
+	br.nbits -= length
+	return int(entry >> 4), nil
+}
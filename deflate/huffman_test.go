@@ -0,0 +1,61 @@
+package deflate
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuildHuffmanEncoderLimit(t *testing.T) {
+	// Fibonacci frequencies build the deepest possible tree.
+	frequencies := make([]int, 30)
+	frequencies[0], frequencies[1] = 1, 1
+	for i := 2; i < len(frequencies); i++ {
+		frequencies[i] = frequencies[i-1] + frequencies[i-2]
+	}
+
+	for _, maxBits := range []int{maxCodeLengthBits, maxCodeBits} {
+		enc, err := buildHuffmanEncoder(frequencies, maxBits)
+		if err != nil {
+			t.Fatalf("buildHuffmanEncoder(%d) error = %v", maxBits, err)
+		}
+		if got := maxLength(enc.lengths); got != maxBits {
+			t.Errorf("buildHuffmanEncoder(%d) longest code = %d bits", maxBits, got)
+		}
+		if _, err := newHuffmanDecoder(enc.lengths); err != nil {
+			t.Errorf("buildHuffmanEncoder(%d) gave an invalid code: %v", maxBits, err)
+		}
+	}
+}
+
+func TestHuffmanDecoder(t *testing.T) {
+	lengths := []int{2, 1, 3, 3, 0}
+	enc, err := newHuffmanEncoder(lengths)
+	if err != nil {
+		t.Fatalf("newHuffmanEncoder() error = %v", err)
+	}
+	symbols := []int{0, 1, 2, 3, 1, 1, 0, 3}
+
+	var buf bytes.Buffer
+	bw := newBitWriter(&buf)
+	for _, symbol := range symbols {
+		enc.write(bw, symbol)
+	}
+	bw.flush()
+
+	dec, err := newHuffmanDecoder(lengths)
+	if err != nil {
+		t.Fatalf("newHuffmanDecoder() error = %v", err)
+	}
+	br := &bitReader{r: bytes.NewReader(buf.Bytes())}
+	for i, want := range symbols {
+		if got, err := dec.decode(br); got != want || err != nil {
+			t.Errorf("decode() #%d = %d, %v, want %d", i, got, err, want)
+		}
+	}
+
+	for _, invalid := range [][]int{{1, 1, 1}, {1, 2, 0}} {
+		if _, err := newHuffmanDecoder(invalid); err != ErrCorrupt {
+			t.Errorf("newHuffmanDecoder(%v) error = %v, want %v", invalid, err, ErrCorrupt)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package deflate
+
+import (
+	"bufio"
+	"io"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// historyLimit is how much output is buffered: the window that matches can
+// refer to plus the output decoded ahead of the caller.
+const historyLimit = windowSize + 1<<16
+
+// Reader decompresses a raw DEFLATE stream.
+type Reader struct {
+	br *bitReader
+	// history holds decoded output, of which history[readPos:] has not been
+	// returned by Read yet.
+	history []byte
+	readPos int
+
+	final     bool
+	inBlock   bool
+	blockType int
+	stored    int
+	literals  *huffmanDecoder
+	distances *huffmanDecoder
+
+	err error
+}
+
+// NewReader returns a Reader that decompresses the DEFLATE stream in r. It
+// reads r through a bufio.Reader unless r is an io.ByteReader already. It may
+// read a few bytes past the end of the stream.
+func NewReader(r io.Reader) *Reader {
+	byteReader, ok := r.(io.ByteReader)
+	if !ok {
+		byteReader = bufio.NewReader(r)
+	}
+	return newReader(&bitReader{r: byteReader})
+}
+
+func newReader(br *bitReader) *Reader {
+	return &Reader{
+		br:      br,
+		history: make([]byte, 0, historyLimit+maxMatchLength),
+	}
+}
+
+// Read decompresses data into p.
+func (z *Reader) Read(p []byte) (int, error) {
+	for z.readPos == len(z.history) {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.decode()
+	}
+
+	n := copy(p, z.history[z.readPos:])
+	z.readPos += n
+	return n, nil
+}
+
+// decode decodes up to historyLimit bytes of output, or to the end of the
+// current block, and returns io.EOF after the final block.
+func (z *Reader) decode() error {
+	if len(z.history) > windowSize {
+		// Everything was read, only the window has to stay.
+		copy(z.history, z.history[len(z.history)-windowSize:])
+		z.history = z.history[:windowSize]
+		z.readPos = windowSize
+	}
+
+	if !z.inBlock {
+		if z.final {
+			return io.EOF
+		}
+		if err := z.readBlockHeader(); err != nil {
+			return err
+		}
+	}
+	if z.blockType == blockStored {
+		return z.copyStored()
+	}
+	return z.decodeHuffman()
+}
+
+func (z *Reader) readBlockHeader() error {
+	header, err := z.br.readBits(3)
+	if err != nil {
+		return err
+	}
+	z.final = header&1 == 1
+	z.blockType = int(header >> 1)
+	z.inBlock = true
+
+	switch z.blockType {
+	case blockStored:
+		z.br.align()
+		lengths, err := z.br.readBits(32)
+		if err != nil {
+			return err
+		}
+		length, complement := uint16(lengths), uint16(lengths>>16)
+		if length != ^complement {
+			return ErrCorrupt
+		}
+		z.stored = int(length)
+		z.inBlock = z.stored > 0
+		return nil
+	case blockFixed:
+		z.literals, z.distances = fixedLiteralDecoder, fixedDistanceDecoder
+		return nil
+	case blockDynamic:
+		return z.readDynamicHeader()
+	}
+	return ErrCorrupt
+}
+
+func (z *Reader) readDynamicHeader() error {
+	counts, err := z.br.readBits(14)
+	if err != nil {
+		return err
+	}
+	numLiterals := int(counts&31) + 257
+	numDistances := int(counts>>5&31) + 1
+	numCodeLengths := int(counts>>10) + 4
+	if numLiterals > literalLengthMax || numDistances > distanceMax {
+		return ErrCorrupt
+	}
+
+	codeLengthLengths := make([]int, codeLengthMax)
+	for _, symbol := range codeLengthOrder[:numCodeLengths] {
+		length, err := z.br.readBits(3)
+		if err != nil {
+			return err
+		}
+		codeLengthLengths[symbol] = int(length)
+	}
+	codeLengthDecoder, err := newHuffmanDecoder(codeLengthLengths)
+	if err != nil {
+		return err
+	}
+
+	lengths := make([]int, numLiterals+numDistances)
+	for i := 0; i < len(lengths); {
+		symbol, err := codeLengthDecoder.decode(z.br)
+		if err != nil {
+			return err
+		}
+		if symbol < 16 {
+			lengths[i] = symbol
+			i++
+			continue
+		}
+
+		repeat, err := z.br.readBits(codeLengthExtra[symbol])
+		if err != nil {
+			return err
+		}
+		value, count := 0, int(repeat)
+		switch symbol {
+		case 16:
+			if i == 0 {
+				return ErrCorrupt
+			}
+			value, count = lengths[i-1], count+3
+		case 17:
+			count += 3
+		default:
+			count += 11
+		}
+		if i+count > len(lengths) {
+			return ErrCorrupt
+		}
+		for ; count > 0; count-- {
+			lengths[i] = value
+			i++
+		}
+	}
+	if lengths[endOfBlock] == 0 {
+		return ErrCorrupt
+	}
+
+	if z.literals, err = newHuffmanDecoder(lengths[:numLiterals]); err != nil {
+		return err
+	}
+	z.distances, err = newHuffmanDecoder(lengths[numLiterals:])
+	return err
+}
+
+func (z *Reader) copyStored() error {
+	n := min(z.stored, historyLimit-len(z.history))
+	start := len(z.history)
+	z.history = z.history[:start+n]
+	if err := z.br.readAligned(z.history[start:]); err != nil {
+		z.history = z.history[:start]
+		return err
+	}
+	z.stored -= n
+	z.inBlock = z.stored > 0
+	return nil
+}
+
+func (z *Reader) decodeHuffman() error {
+	for len(z.history) < historyLimit {
+		symbol, err := z.literals.decode(z.br)
+		if err != nil {
+			return err
+		}
+		if symbol < endOfBlock {
+			z.history = append(z.history, byte(symbol))
+			continue
+		}
+		if symbol == endOfBlock {
+			z.inBlock = false
+			return nil
+		}
+
+		lc := symbol - 257
+		if lc >= len(lengthBase) {
+			return ErrCorrupt
+		}
+		extra, err := z.br.readBits(lengthExtra[lc])
+		if err != nil {
+			return err
+		}
+		length := lengthBase[lc] + int(extra)
+
+		dc, err := z.distances.decode(z.br)
+		if err != nil {
+			return err
+		}
+		if dc >= len(distanceBase) {
+			return ErrCorrupt
+		}
+		if extra, err = z.br.readBits(distanceExtra[dc]); err != nil {
+			return err
+		}
+		distance := distanceBase[dc] + int(extra)
+
+		if z.history, err = compressutils.AppendLZMatch(z.history, distance, length); err != nil {
+			return ErrCorrupt
+		}
+	}
+	return nil
+}
+
+var fixedLiteralDecoder, fixedDistanceDecoder = func() (*huffmanDecoder, *huffmanDecoder) {
+	literals, err := newHuffmanDecoder(fixedLiteralLengths)
+	if err != nil {
+		panic(err)
+	}
+	distances, err := newHuffmanDecoder(fixedDistanceLengths)
+	if err != nil {
+		panic(err)
+	}
+	return literals, distances
+}()
@@ -0,0 +1,98 @@
+package deflate
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"testing"
+)
+
+func inflate(data []byte) ([]byte, error) {
+	return io.ReadAll(NewReader(bytes.NewReader(data)))
+}
+
+func TestReaderRoundTrip(t *testing.T) {
+	for _, tt := range writerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := inflate(deflateBytes(t, tt.input))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !bytes.Equal(got, tt.input) {
+				t.Errorf("decoded %d bytes, want the %d written", len(got), len(tt.input))
+			}
+		})
+	}
+}
+
+func TestReaderDecodesCompressFlate(t *testing.T) {
+	input := append(textInput(10000), randomInput(70000)...)
+	input = append(input, bytes.Repeat([]byte("xyz"), 50000)...)
+
+	for _, level := range []int{flate.NoCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression, flate.HuffmanOnly} {
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(input)
+		fw.Close()
+
+		got, err := inflate(buf.Bytes())
+		if err != nil {
+			t.Fatalf("level %d: Read() error = %v", level, err)
+		}
+		if !bytes.Equal(got, input) {
+			t.Errorf("level %d: decoded %d bytes, want %d", level, len(got), len(input))
+		}
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	compressed := deflateBytes(t, textInput(2000))
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  error
+	}{
+		{name: "Empty", input: nil, want: io.ErrUnexpectedEOF},
+		{name: "Truncated", input: compressed[:len(compressed)/2], want: io.ErrUnexpectedEOF},
+		{name: "Reserved block type", input: []byte{0x07}, want: ErrCorrupt},
+		{name: "Stored length mismatch", input: []byte{0x01, 0x05, 0x00, 0x00, 0x00}, want: ErrCorrupt},
+		{name: "Truncated stored block", input: []byte{0x01, 0x05, 0x00, 0xfa, 0xff, 'a'}, want: io.ErrUnexpectedEOF},
+		// A fixed block with length code 286, which does not exist.
+		{name: "Invalid length code", input: []byte{0x1b, 0x03}, want: ErrCorrupt},
+		// A fixed block whose first match reaches before the output.
+		{name: "Distance too far", input: []byte{0x03, 0x02, 0x00}, want: ErrCorrupt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := inflate(tt.input); !errors.Is(err, tt.want) {
+				t.Errorf("Read() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReaderCorruptInputDoesNotPanic(t *testing.T) {
+	compressed := deflateBytes(t, textInput(500))
+	for i := 0; i < len(compressed); i += 7 {
+		corrupt := bytes.Clone(compressed)
+		corrupt[i] ^= 0x5a
+		inflate(corrupt)
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	input := textInput(20000)
+	compressed := deflateBytes(b, input)
+	b.SetBytes(int64(len(input)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		io.Copy(io.Discard, NewReader(bytes.NewReader(compressed)))
+	}
+}
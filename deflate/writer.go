@@ -0,0 +1,337 @@
+package deflate
+
+import (
+	"io"
+
+	compressutils "github.com/prashant1k99/compactor/compress-utils"
+)
+
+// blockInputSize is how much input goes into one block. Each block gets the
+// cheapest of the three block types for its content.
+const blockInputSize = 1 << 17
+
+// token is a literal byte when length is zero, otherwise a match of length
+// bytes at distance value.
+type token struct {
+	length uint16
+	value  uint16
+}
+
+// Writer compresses everything written to it into a raw DEFLATE stream.
+// Close must be called to write the final block.
+type Writer struct {
+	bw *bitWriter
+	// window holds up to windowSize bytes of history followed by the input
+	// of the next block, which starts at start.
+	window []byte
+	start  int
+
+	closed bool
+	err    error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		bw:     newBitWriter(w),
+		window: make([]byte, 0, windowSize+blockInputSize),
+	}
+}
+
+// Write compresses p. Output is written a block at a time.
+func (z *Writer) Write(p []byte) (int, error) {
+	if z.closed {
+		return 0, ErrClosed
+	}
+	if z.err != nil {
+		return 0, z.err
+	}
+
+	written := len(p)
+	for len(p) > 0 {
+		n := min(len(p), blockInputSize-(len(z.window)-z.start))
+		z.window = append(z.window, p[:n]...)
+		p = p[n:]
+
+		if len(z.window)-z.start == blockInputSize {
+			if z.err = z.writeBlock(false); z.err != nil {
+				return 0, z.err
+			}
+			z.slide()
+		}
+	}
+	return written, nil
+}
+
+// slide drops all but the last windowSize bytes, which later matches may
+// still refer to.
+func (z *Writer) slide() {
+	if keep := windowSize; len(z.window) > keep {
+		copy(z.window, z.window[len(z.window)-keep:])
+		z.window = z.window[:keep]
+	}
+	z.start = len(z.window)
+}
+
+// Close writes the final block and flushes. It does not close the underlying
+// writer.
+func (z *Writer) Close() error {
+	if z.closed {
+		return z.err
+	}
+	z.closed = true
+	if z.err != nil {
+		return z.err
+	}
+
+	if z.err = z.writeBlock(true); z.err != nil {
+		return z.err
+	}
+	z.err = z.bw.flush()
+	return z.err
+}
+
+func (z *Writer) tokenize() []token {
+	var tokens []token
+	pos := z.start
+	for _, seq := range compressutils.LZParseWindow(z.window, z.start, windowSize, maxMatchLength) {
+		for _, b := range z.window[pos : pos+seq.LiteralLength] {
+			tokens = append(tokens, token{value: uint16(b)})
+		}
+		tokens = append(tokens, token{length: uint16(seq.MatchLength), value: uint16(seq.Offset)})
+		pos += seq.LiteralLength + seq.MatchLength
+	}
+	for _, b := range z.window[pos:] {
+		tokens = append(tokens, token{value: uint16(b)})
+	}
+	return tokens
+}
+
+// writeBlock encodes the pending input as a stored, fixed or dynamic block,
+// whichever is smallest.
+func (z *Writer) writeBlock(final bool) error {
+	tokens := z.tokenize()
+
+	literalFreq := make([]int, literalLengthMax)
+	distanceFreq := make([]int, distanceMax)
+	extraBits := 0
+	literalFreq[endOfBlock] = 1
+	for _, t := range tokens {
+		if t.length == 0 {
+			literalFreq[t.value]++
+			continue
+		}
+		lc, dc := lengthCode(int(t.length)), distanceCode(int(t.value))
+		literalFreq[257+lc]++
+		distanceFreq[dc]++
+		extraBits += int(lengthExtra[lc] + distanceExtra[dc])
+	}
+
+	literalEnc, err := buildHuffmanEncoder(literalFreq, maxCodeBits)
+	if err != nil {
+		return err
+	}
+	distanceEnc, err := buildHuffmanEncoder(distanceFreq, maxCodeBits)
+	if err != nil {
+		return err
+	}
+	header, err := newDynamicHeader(literalEnc.lengths, distanceEnc.lengths)
+	if err != nil {
+		return err
+	}
+	dynamicBits := 3 + header.bits + literalEnc.cost(literalFreq) + distanceEnc.cost(distanceFreq) + extraBits
+	fixedBits := 3 + fixedLiteralEncoder.cost(literalFreq) + fixedDistanceEncoder.cost(distanceFreq) + extraBits
+
+	input := z.window[z.start:]
+	storedBlocks := max(1, (len(input)+maxStoredBlock-1)/maxStoredBlock)
+	// Every stored block has its 3 header bits, up to 7 bits of padding and
+	// the length with its complement.
+	storedBits := storedBlocks*(3+7+32) + 8*len(input)
+
+	switch {
+	case storedBits < fixedBits && storedBits < dynamicBits:
+		z.writeStored(input, final)
+	case fixedBits <= dynamicBits:
+		z.writeBlockHeader(final, blockFixed)
+		z.writeTokens(tokens, fixedLiteralEncoder, fixedDistanceEncoder)
+	default:
+		z.writeBlockHeader(final, blockDynamic)
+		header.write(z.bw)
+		z.writeTokens(tokens, literalEnc, distanceEnc)
+	}
+	return z.bw.err
+}
+
+func (z *Writer) writeBlockHeader(final bool, blockType int) {
+	finalBit := uint64(0)
+	if final {
+		finalBit = 1
+	}
+	z.bw.writeBits(finalBit, 1)
+	z.bw.writeBits(uint64(blockType), 2)
+}
+
+func (z *Writer) writeStored(input []byte, final bool) {
+	for {
+		n := min(len(input), maxStoredBlock)
+		z.writeBlockHeader(final && n == len(input), blockStored)
+		z.bw.align()
+		z.bw.writeBits(uint64(n), 16)
+		z.bw.writeBits(uint64(^uint16(n)), 16)
+		z.bw.writeBytes(input[:n])
+		input = input[n:]
+		if len(input) == 0 {
+			return
+		}
+	}
+}
+
+func (z *Writer) writeTokens(tokens []token, literals, distances *huffmanEncoder) {
+	for _, t := range tokens {
+		if t.length == 0 {
+			literals.write(z.bw, int(t.value))
+			continue
+		}
+
+		length, distance := int(t.length), int(t.value)
+		lc := lengthCode(length)
+		literals.write(z.bw, 257+lc)
+		z.bw.writeBits(uint64(length-lengthBase[lc]), lengthExtra[lc])
+
+		dc := distanceCode(distance)
+		distances.write(z.bw, dc)
+		z.bw.writeBits(uint64(distance-distanceBase[dc]), distanceExtra[dc])
+	}
+	literals.write(z.bw, endOfBlock)
+}
+
+// atLeastTwoCodes gives unused symbols a one bit code until two symbols have
+// a code. A lone code, which the tree builder makes one bit long, would be
+// incomplete and some decoders reject that, and PKZIP expects at least one
+// distance code even when no match uses it.
+func atLeastTwoCodes(lengths []int) []int {
+	used := 0
+	for _, length := range lengths {
+		if length > 0 {
+			used++
+		}
+	}
+	for symbol := 0; used < 2; symbol++ {
+		if lengths[symbol] == 0 {
+			lengths[symbol] = 1
+			used++
+		}
+	}
+	return lengths
+}
+
+var fixedLiteralEncoder, fixedDistanceEncoder = func() (*huffmanEncoder, *huffmanEncoder) {
+	literals, err := newHuffmanEncoder(fixedLiteralLengths)
+	if err != nil {
+		panic(err)
+	}
+	distances, err := newHuffmanEncoder(fixedDistanceLengths)
+	if err != nil {
+		panic(err)
+	}
+	return literals, distances
+}()
+
+// codeLengthSymbol is a symbol of the code length alphabet with the value of
+// its extra bits.
+type codeLengthSymbol struct {
+	symbol uint8
+	extra  uint8
+}
+
+var codeLengthExtra = [codeLengthMax]uint{16: 2, 17: 3, 18: 7}
+
+// dynamicHeader is the part of a dynamic block that describes its codes.
+type dynamicHeader struct {
+	numLiterals    int
+	numDistances   int
+	numCodeLengths int
+	encoder        *huffmanEncoder
+	symbols        []codeLengthSymbol
+	bits           int
+}
+
+// newDynamicHeader run length encodes the code lengths with the repeat
+// symbols 16, 17 and 18 and builds the code length code for the result.
+func newDynamicHeader(literalLengths, distanceLengths []int) (*dynamicHeader, error) {
+	h := &dynamicHeader{numLiterals: literalLengthMax, numDistances: distanceMax}
+	for h.numLiterals > 257 && literalLengths[h.numLiterals-1] == 0 {
+		h.numLiterals--
+	}
+	for h.numDistances > 1 && distanceLengths[h.numDistances-1] == 0 {
+		h.numDistances--
+	}
+
+	lengths := append(append([]int(nil), literalLengths[:h.numLiterals]...), distanceLengths[:h.numDistances]...)
+	emit := func(symbol, extra int) {
+		h.symbols = append(h.symbols, codeLengthSymbol{symbol: uint8(symbol), extra: uint8(extra)})
+	}
+	for i := 0; i < len(lengths); {
+		length := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == length {
+			run++
+		}
+		i += run
+
+		if length == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				emit(18, n-11)
+				run -= n
+			}
+			if run >= 3 {
+				emit(17, run-3)
+				run = 0
+			}
+		} else {
+			emit(length, 0)
+			run--
+			for run >= 3 {
+				n := min(run, 6)
+				emit(16, n-3)
+				run -= n
+			}
+		}
+		for ; run > 0; run-- {
+			emit(length, 0)
+		}
+	}
+
+	frequencies := make([]int, codeLengthMax)
+	for _, s := range h.symbols {
+		frequencies[s.symbol]++
+	}
+	var err error
+	if h.encoder, err = buildHuffmanEncoder(frequencies, maxCodeLengthBits); err != nil {
+		return nil, err
+	}
+
+	h.numCodeLengths = codeLengthMax
+	for h.numCodeLengths > 4 && h.encoder.lengths[codeLengthOrder[h.numCodeLengths-1]] == 0 {
+		h.numCodeLengths--
+	}
+
+	h.bits = 5 + 5 + 4 + 3*h.numCodeLengths + h.encoder.cost(frequencies)
+	for symbol, freq := range frequencies {
+		h.bits += freq * int(codeLengthExtra[symbol])
+	}
+	return h, nil
+}
+
+func (h *dynamicHeader) write(bw *bitWriter) {
+	bw.writeBits(uint64(h.numLiterals-257), 5)
+	bw.writeBits(uint64(h.numDistances-1), 5)
+	bw.writeBits(uint64(h.numCodeLengths-4), 4)
+	for _, symbol := range codeLengthOrder[:h.numCodeLengths] {
+		bw.writeBits(uint64(h.encoder.lengths[symbol]), 3)
+	}
+	for _, s := range h.symbols {
+		h.encoder.write(bw, int(s.symbol))
+		bw.writeBits(uint64(s.extra), codeLengthExtra[s.symbol])
+	}
+}
@@ -0,0 +1,141 @@
+package deflate
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func textInput(lines int) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&buf, "%d GET /api/v1/items/%d HTTP/1.1 status=%d bytes=%d\n", i, i%97, 200+i%3, i*37%1000)
+	}
+	return buf.Bytes()
+}
+
+func randomInput(size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// fibonacciInput repeats byte i as often as the i-th Fibonacci number, whose
+// Huffman tree is deeper than DEFLATE allows.
+func fibonacciInput(symbols int) []byte {
+	var input []byte
+	a, b := 1, 1
+	for i := 0; i < symbols; i++ {
+		input = append(input, bytes.Repeat([]byte{byte(i)}, a)...)
+		a, b = b, a+b
+	}
+	return input
+}
+
+func deflateBytes(t testing.TB, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+	if _, err := z.Write(data); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+var writerTests = []struct {
+	name  string
+	input []byte
+	// blockType is the type of the first block, -1 if it depends on the
+	// input.
+	blockType int
+}{
+	{name: "Empty", input: nil, blockType: blockFixed},
+	{name: "Short text", input: []byte("hello, hello, hello world"), blockType: blockFixed},
+	{name: "Text", input: textInput(2000), blockType: blockDynamic},
+	{name: "Random", input: randomInput(100000), blockType: blockStored},
+	{name: "Single repeated byte", input: bytes.Repeat([]byte("a"), 100000), blockType: -1},
+	{name: "Several blocks", input: textInput(20000), blockType: blockDynamic},
+	{name: "Fibonacci distributed", input: fibonacciInput(25), blockType: -1},
+	{name: "Random then text", input: append(randomInput(200000), textInput(5000)...), blockType: blockStored},
+}
+
+func TestWriterDecodedByCompressFlate(t *testing.T) {
+	for _, tt := range writerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := deflateBytes(t, tt.input)
+			if tt.blockType >= 0 {
+				if got := int(compressed[0] >> 1 & 3); got != tt.blockType {
+					t.Errorf("first block type = %d, want %d", got, tt.blockType)
+				}
+			}
+
+			got, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+			if err != nil {
+				t.Fatalf("compress/flate error = %v", err)
+			}
+			if !bytes.Equal(got, tt.input) {
+				t.Errorf("compress/flate decoded %d bytes, want the %d written", len(got), len(tt.input))
+			}
+		})
+	}
+}
+
+func TestWriterSmallWrites(t *testing.T) {
+	input := textInput(5000)
+	var buf bytes.Buffer
+	z := NewWriter(&buf)
+	for i := 0; i < len(input); i += 1000 {
+		if _, err := z.Write(input[i:min(i+1000, len(input))]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if !bytes.Equal(buf.Bytes(), deflateBytes(t, input)) {
+		t.Errorf("output depends on how the input was split into writes")
+	}
+}
+
+func TestWriterCompressesLikeFlate(t *testing.T) {
+	input := textInput(20000)
+	var buf bytes.Buffer
+	fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	fw.Write(input)
+	fw.Close()
+
+	// The parser differs, the result should still be in the same range.
+	if got := len(deflateBytes(t, input)); got > buf.Len()*5/4 {
+		t.Errorf("compressed to %d bytes, compress/flate to %d", got, buf.Len())
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	z := NewWriter(io.Discard)
+	if err := z.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := z.Write([]byte("late")); err != ErrClosed {
+		t.Errorf("Write() after Close() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func BenchmarkWriter(b *testing.B) {
+	input := textInput(20000)
+	b.SetBytes(int64(len(input)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		z := NewWriter(io.Discard)
+		z.Write(input)
+		z.Close()
+	}
+}
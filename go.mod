@@ -5,6 +5,8 @@ go 1.22.6
 require (
 	github.com/schollz/progressbar/v3 v3.15.0
 	github.com/spf13/cobra v1.8.1
+	golang.org/x/crypto v0.27.0
+	golang.org/x/term v0.24.0
 )
 
 require (
@@ -13,5 +15,4 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/sys v0.25.0 // indirect
-	golang.org/x/term v0.24.0 // indirect
 )